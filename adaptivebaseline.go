@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"lagbuster/stats"
+)
+
+// Baseline modes (baseline.mode)
+const (
+	baselineStatic   = "static"   // expected_baseline as configured (default)
+	baselineAdaptive = "adaptive" // Learned from each peer's healthy history in the database
+)
+
+// Defaults for adaptive baselines
+const (
+	defaultBaselineWindowDays     = 7
+	defaultBaselineUpdateInterval = 60 // minutes
+	defaultBaselineMinSamples     = 100
+)
+
+// baselineLogChange is how far (ms) a learned baseline must move to be logged and recorded
+const baselineLogChange = 0.5
+
+// BaselineConfig controls learning peer baselines from measurement history
+type BaselineConfig struct {
+	Mode           string `yaml:"mode" json:"mode"`                       // static or adaptive
+	WindowDays     int    `yaml:"window_days" json:"window_days"`         // Days of healthy samples the median is taken over
+	UpdateInterval int    `yaml:"update_interval" json:"update_interval"` // Minutes between recomputations
+	MinSamples     int    `yaml:"min_samples" json:"min_samples"`         // Healthy samples needed before the learned baseline replaces the configured one
+}
+
+// validateBaselineMode checks the baseline section
+func validateBaselineMode(config BaselineConfig) error {
+	switch config.Mode {
+	case "", baselineStatic, baselineAdaptive:
+	default:
+		return fmt.Errorf("baseline.mode must be static or adaptive, got %q", config.Mode)
+	}
+	if config.WindowDays < 0 || config.UpdateInterval < 0 || config.MinSamples < 0 {
+		return fmt.Errorf("baseline.window_days, update_interval and min_samples must not be negative")
+	}
+	return nil
+}
+
+// runBaselineLearner recomputes adaptive baselines at startup and every
+// baseline.update_interval until ctx is cancelled
+func runBaselineLearner(ctx context.Context, state *AppState) {
+	config := state.Config.Baseline
+	interval := time.Duration(config.UpdateInterval) * time.Minute
+	if interval <= 0 {
+		interval = defaultBaselineUpdateInterval * time.Minute
+	}
+
+	learnBaselines(state)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			learnBaselines(state)
+		}
+	}
+}
+
+// learnBaselines sets each peer's baseline to the median of its healthy samples over
+// baseline.window_days. Peers with fewer than baseline.min_samples keep their current
+// baseline, and so do unhealthy peers, so a baseline never drifts towards a bad path.
+func learnBaselines(state *AppState) {
+	state.mu.Lock()
+	config := state.Config.Baseline
+	peers := append([]PeerConfig(nil), state.Config.Peers...)
+	state.mu.Unlock()
+
+	windowDays := config.WindowDays
+	if windowDays <= 0 {
+		windowDays = defaultBaselineWindowDays
+	}
+	minSamples := config.MinSamples
+	if minSamples <= 0 {
+		minSamples = defaultBaselineMinSamples
+	}
+	since := time.Now().AddDate(0, 0, -windowDays)
+
+	for _, peerConfig := range peers {
+		measurements, err := state.db.GetMeasurements(peerConfig.Name, since)
+		if err != nil {
+			logger.Warn("Could not learn baseline for %s: %v", peerConfig.Name, err)
+			continue
+		}
+
+		var samples []float64
+		for _, m := range measurements {
+			if m.IsHealthy && m.Latency >= 0 {
+				samples = append(samples, m.Latency)
+			}
+		}
+		if len(samples) < minSamples {
+			logger.Debug("Peer %s has %d healthy samples in the last %d days (need %d), keeping baseline %.2fms",
+				peerConfig.Name, len(samples), windowDays, minSamples, peerConfig.ExpectedBaseline)
+			continue
+		}
+
+		state.mu.Lock()
+		applyLearnedBaseline(state, peerConfig.Name, stats.Percentile(samples, 50),
+			fmt.Sprintf("median of %d healthy samples over %d days", len(samples), windowDays))
+		state.mu.Unlock()
+	}
+}
+
+// applyLearnedBaseline makes baseline the peer's expected_baseline unless the peer is
+// unhealthy. The caller holds state.mu.
+func applyLearnedBaseline(state *AppState, name string, baseline float64, source string) {
+	peer, ok := state.Peers[name]
+	if !ok {
+		return // Removed by a reload meanwhile
+	}
+	if !peer.IsHealthy {
+		logger.Debug("Peer %s is unhealthy, keeping its baseline at %.2fms", name, peer.Config.ExpectedBaseline)
+		return
+	}
+
+	old := peer.Config.ExpectedBaseline
+	peer.Config.ExpectedBaseline = baseline
+	for i := range state.Config.Peers {
+		if state.Config.Peers[i].Name == name {
+			state.Config.Peers[i].ExpectedBaseline = baseline
+		}
+	}
+	first := !peer.baselineLearned
+	peer.baselineLearned = true
+
+	if first || math.Abs(baseline-old) >= baselineLogChange {
+		reason := fmt.Sprintf("baseline %.2fms -> %.2fms (%s)", old, baseline, source)
+		logger.Info("Peer %s learned %s", name, reason)
+		recordEvent(state, "baseline_update", &name, nil, nil, reason, nil)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"lagbuster/database"
+)
+
+// exportFlushRows is how many CSV rows are written between flushes to the client
+const exportFlushRows = 1000
+
+// handleMetricsExport serves a peer's measurements for download. format=csv streams them
+// row by row straight from the database; format=json (the default) is /api/metrics.
+func (s *Server) handleMetricsExport(w http.ResponseWriter, r *http.Request) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		s.handleMetrics(w, r)
+		return
+	case "csv":
+	default:
+		writeError(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	peerName := r.URL.Query().Get("peer")
+	rangeStr := r.URL.Query().Get("range")
+	if peerName == "" {
+		writeError(w, "peer parameter required", http.StatusBadRequest)
+		return
+	}
+
+	// Same ranges as /api/metrics
+	var since time.Time
+	switch rangeStr {
+	case "24h":
+		since = time.Now().Add(-24 * time.Hour)
+	case "7d":
+		since = time.Now().Add(-7 * 24 * time.Hour)
+	case "30d":
+		since = time.Now().Add(-30 * 24 * time.Hour)
+	default:
+		rangeStr = "1h"
+		since = time.Now().Add(-1 * time.Hour)
+	}
+
+	if s.db == nil {
+		writeError(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	filename := fmt.Sprintf("lagbuster-%s-%s.csv", peerName, rangeStr)
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+
+	flusher, _ := w.(http.Flusher)
+	out := csv.NewWriter(w)
+	rows := 0
+	flush := func() error {
+		out.Flush()
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return out.Error()
+	}
+
+	// Latency is -1 where the probe got no reply
+	out.Write([]string{"timestamp", "peer", "latency", "is_healthy", "is_primary"})
+	err := s.db.EachMeasurement(peerName, since, func(m database.Measurement) error {
+		out.Write([]string{
+			m.Timestamp.UTC().Format(time.RFC3339),
+			m.PeerName,
+			strconv.FormatFloat(m.Latency, 'f', 3, 64),
+			strconv.FormatBool(m.IsHealthy),
+			strconv.FormatBool(m.IsPrimary),
+		})
+		if rows++; rows%exportFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		// The header has gone out, so all that can be done is to cut the file short
+		s.logger.Error("Failed to export measurements for %s: %v", peerName, err)
+	}
+}
@@ -1,32 +1,99 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"lagbuster/database"
+	"lagbuster/notifications"
+	"lagbuster/stats"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 // StatusResponse represents the current system status
 type StatusResponse struct {
-	HealthyPeerCount    int                   `json:"healthy_peer_count"`
-	UnhealthyPeerCount  int                   `json:"unhealthy_peer_count"`
-	Uptime              int64                 `json:"uptime_seconds"`
-	MeasurementInterval int                   `json:"measurement_interval"`
-	Peers               map[string]PeerStatus `json:"peers"`
+	HealthyPeerCount    int                    `json:"healthy_peer_count"`
+	UnhealthyPeerCount  int                    `json:"unhealthy_peer_count"`
+	Uptime              int64                  `json:"uptime_seconds"`
+	MeasurementInterval int                    `json:"measurement_interval"`
+	Frozen              bool                   `json:"frozen"`            // Routing decisions held by the kill-switch
+	Standby             bool                   `json:"standby,omitempty"` // Following another instance, not applying routing
+	ReferenceQuorum     *ReferenceQuorumStatus `json:"reference_quorum,omitempty"`
+	DBWriteLagMs        *float64               `json:"db_write_lag_ms,omitempty"` // Latest measurement write duration
+	Peers               map[string]PeerStatus  `json:"peers"`
 }
 
 // PeerStatus represents a peer's current status
 type PeerStatus struct {
-	Name                      string  `json:"name"`
-	Hostname                  string  `json:"hostname"`
-	Latency                   float64 `json:"latency"`
-	Baseline                  float64 `json:"baseline"`
-	Degradation               float64 `json:"degradation"`
-	IsHealthy                 bool    `json:"is_healthy"`
-	ConsecutiveHealthyCount   int     `json:"consecutive_healthy_count"`
-	ConsecutiveUnhealthyCount int     `json:"consecutive_unhealthy_count"`
-	BGPSessionUp              bool    `json:"bgp_session_up"`
-	BGPSessionState           string  `json:"bgp_session_state"`
+	Name                      string   `json:"name"`
+	Hostname                  string   `json:"hostname"`
+	Latency                   float64  `json:"latency"`
+	Baseline                  float64  `json:"baseline"`
+	BaselineWindow            string   `json:"baseline_window,omitempty"` // Time-of-day window the baseline comes from (peers[].baseline_windows)
+	Degradation               float64  `json:"degradation"`
+	DegradationRatio          float64  `json:"degradation_ratio"` // current/baseline, 0 when unreachable or no baseline
+	IsHealthy                 bool     `json:"is_healthy"`
+	ConsecutiveHealthyCount   int      `json:"consecutive_healthy_count"`
+	ConsecutiveUnhealthyCount int      `json:"consecutive_unhealthy_count"`
+	BGPSessionUp              bool     `json:"bgp_session_up"`
+	BGPSessionState           string   `json:"bgp_session_state"`
+	Disabled                  bool     `json:"disabled"`                         // Taken out of ECMP by an operator (/api/peers/{name}/disable)
+	LastProbeError            string   `json:"last_probe_error,omitempty"`       // Only while the peer is unreachable
+	PacketLoss                float64  `json:"packet_loss"`                      // Fraction of the latest measurement's echoes lost (damping.probe_count)
+	ExpectedLoss              float64  `json:"expected_loss"`                    // Loss the peer normally sees (peers[].expected_loss); packet_loss_threshold applies above it
+	Jitter                    float64  `json:"jitter"`                           // Standard deviation of the latest measurement's echo RTTs in ms
+	SmoothedLatency           float64  `json:"smoothed_latency"`                 // EWMA of answered samples (damping.ewma_alpha), -1 until the first reply
+	PathAsymmetry             *float64 `json:"path_asymmetry_ms,omitempty"`      // Only for peers with an OWD responder
+	HoldRemaining             int64    `json:"hold_remaining_seconds,omitempty"` // Time left in the post-recovery hold (damping.min_active_hold)
+}
+
+// newPeerStatus converts a peer's runtime state to its API representation
+func newPeerStatus(peer *PeerState) PeerStatus {
+	probeErr := ""
+	if peer.CurrentLatency < 0 {
+		probeErr = peer.LastProbeError
+	}
+
+	var holdRemaining int64
+	if remaining := time.Until(peer.HoldUntil); remaining > 0 {
+		holdRemaining = int64(remaining.Seconds() + 0.5)
+	}
+
+	return PeerStatus{
+		Name:                      peer.Name,
+		Hostname:                  peer.Hostname,
+		Latency:                   peer.CurrentLatency,
+		Baseline:                  peer.Baseline,
+		BaselineWindow:            peer.BaselineWindow,
+		Degradation:               peer.CurrentLatency - peer.Baseline,
+		DegradationRatio:          degradationRatio(peer.CurrentLatency, peer.Baseline),
+		IsHealthy:                 peer.IsHealthy,
+		ConsecutiveHealthyCount:   peer.ConsecutiveHealthyCount,
+		ConsecutiveUnhealthyCount: peer.ConsecutiveUnhealthyCount,
+		BGPSessionUp:              peer.BGPSessionUp,
+		BGPSessionState:           peer.BGPSessionState,
+		Disabled:                  peer.Disabled,
+		LastProbeError:            probeErr,
+		PacketLoss:                peer.PacketLoss,
+		ExpectedLoss:              peer.ExpectedLoss,
+		Jitter:                    peer.Jitter,
+		SmoothedLatency:           peer.SmoothedLatency,
+		PathAsymmetry:             peer.PathAsymmetry,
+		HoldRemaining:             holdRemaining,
+	}
+}
+
+// degradationRatio returns current latency relative to baseline (e.g. 3.0 = three times baseline).
+// Unlike absolute degradation this compares fairly across peers with very different baselines.
+func degradationRatio(latency, baseline float64) float64 {
+	if latency < 0 || baseline <= 0 {
+		return 0
+	}
+	return latency / baseline
 }
 
 // handleStatus returns the current system status
@@ -51,18 +118,7 @@ func (s *Server) getCurrentStatus() StatusResponse {
 			unhealthyCount++
 		}
 
-		peers[name] = PeerStatus{
-			Name:                      peer.Name,
-			Hostname:                  peer.Hostname,
-			Latency:                   peer.CurrentLatency,
-			Baseline:                  peer.Baseline,
-			Degradation:               peer.CurrentLatency - peer.Baseline,
-			IsHealthy:                 peer.IsHealthy,
-			ConsecutiveHealthyCount:   peer.ConsecutiveHealthyCount,
-			ConsecutiveUnhealthyCount: peer.ConsecutiveUnhealthyCount,
-			BGPSessionUp:              peer.BGPSessionUp,
-			BGPSessionState:           peer.BGPSessionState,
-		}
+		peers[name] = newPeerStatus(peer)
 	}
 
 	resp := StatusResponse{
@@ -70,6 +126,10 @@ func (s *Server) getCurrentStatus() StatusResponse {
 		UnhealthyPeerCount:  unhealthyCount,
 		Uptime:              int64(time.Since(s.state.StartTime).Seconds()),
 		MeasurementInterval: s.state.Config.MeasurementInterval,
+		Frozen:              s.state.Frozen,
+		Standby:             s.state.Standby,
+		ReferenceQuorum:     s.state.ReferenceQuorum,
+		DBWriteLagMs:        s.state.DBWriteLagMs,
 		Peers:               peers,
 	}
 
@@ -83,18 +143,7 @@ func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
 
 	peers := make([]PeerStatus, 0, len(s.state.Peers))
 	for _, peer := range s.state.Peers {
-		peers = append(peers, PeerStatus{
-			Name:                      peer.Name,
-			Hostname:                  peer.Hostname,
-			Latency:                   peer.CurrentLatency,
-			Baseline:                  peer.Baseline,
-			Degradation:               peer.CurrentLatency - peer.Baseline,
-			IsHealthy:                 peer.IsHealthy,
-			ConsecutiveHealthyCount:   peer.ConsecutiveHealthyCount,
-			ConsecutiveUnhealthyCount: peer.ConsecutiveUnhealthyCount,
-			BGPSessionUp:              peer.BGPSessionUp,
-			BGPSessionState:           peer.BGPSessionState,
-		})
+		peers = append(peers, newPeerStatus(peer))
 	}
 
 	writeJSON(w, peers)
@@ -104,12 +153,40 @@ func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	peerName := r.URL.Query().Get("peer")
 	rangeStr := r.URL.Query().Get("range")
+	normalOnly := r.URL.Query().Get("normal_only") == "true"
+	smoothing := r.URL.Query().Get("smoothing")
 
 	if peerName == "" {
 		writeError(w, "peer parameter required", http.StatusBadRequest)
 		return
 	}
 
+	// Optional smoothed series: raw (none), ewma, or pNN over the measurement window
+	if smoothing == "" {
+		smoothing = smoothingRaw
+	}
+	var alpha float64 // 0 = the engine's damping.ewma_alpha
+	var percentile float64
+	switch {
+	case smoothing == smoothingRaw:
+	case smoothing == smoothingEWMA:
+		if alphaStr := r.URL.Query().Get("alpha"); alphaStr != "" {
+			parsed, err := strconv.ParseFloat(alphaStr, 64)
+			if err != nil || parsed <= 0 || parsed > 1 {
+				writeError(w, "alpha must be a number in (0, 1]", http.StatusBadRequest)
+				return
+			}
+			alpha = parsed
+		}
+	default:
+		p, err := stats.ParsePercentile(smoothing)
+		if err != nil {
+			writeError(w, "smoothing must be raw, ewma or pNN (e.g. p95)", http.StatusBadRequest)
+			return
+		}
+		percentile = p
+	}
+
 	// Parse range (1h, 24h, 7d, 30d)
 	var since time.Time
 	switch rangeStr {
@@ -125,11 +202,36 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		since = time.Now().Add(-1 * time.Hour) // Default to 1 hour
 	}
 
+	// Long ranges come back bucketed unless a resolution (or smoothing, which works on
+	// the raw samples) is asked for
+	resolutionStr := r.URL.Query().Get("resolution")
+	var resolution time.Duration
+	switch {
+	case resolutionStr == "" && smoothing == smoothingRaw:
+		resolution = defaultResolutions[rangeStr]
+	case resolutionStr == "" || resolutionStr == resolutionRaw:
+	case smoothing != smoothingRaw:
+		writeError(w, "smoothing needs resolution=raw", http.StatusBadRequest)
+		return
+	default:
+		parsed, err := time.ParseDuration(resolutionStr)
+		if err != nil || parsed < time.Minute {
+			writeError(w, "resolution must be raw or a duration of at least 1m (e.g. 5m, 1h)", http.StatusBadRequest)
+			return
+		}
+		resolution = parsed
+	}
+
 	if s.db == nil {
 		writeError(w, "database not configured", http.StatusServiceUnavailable)
 		return
 	}
 
+	if resolution > 0 {
+		s.writeAggregatedMetrics(w, peerName, rangeStr, since, resolution, normalOnly)
+		return
+	}
+
 	measurements, err := s.db.GetMeasurements(peerName, since)
 	if err != nil {
 		s.logger.Error("Failed to get measurements: %v", err)
@@ -137,29 +239,202 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert to API response format
-	type MetricPoint struct {
-		Timestamp time.Time `json:"timestamp"`
-		Latency   float64   `json:"latency"`
-		IsHealthy bool      `json:"is_healthy"`
+	// A gap is more than a few of the peer's probe intervals without a sample
+	s.state.mu.RLock()
+	interval := s.state.Config.MeasurementInterval
+	if peer, ok := s.state.Peers[peerName]; ok && peer.MeasurementInterval > 0 {
+		interval = peer.MeasurementInterval
+	}
+	window := s.state.Config.MeasurementWindow
+	if alpha == 0 {
+		alpha = s.state.Config.EWMAAlpha
+	}
+	s.state.mu.RUnlock()
+	if alpha <= 0 {
+		alpha = stats.DefaultEWMAAlpha
+	}
+
+	// Leave out frozen/dry-run/standby periods (they then show up as gaps)
+	if normalOnly {
+		kept := measurements[:0]
+		for _, m := range measurements {
+			if m.State == database.StateNormal {
+				kept = append(kept, m)
+			}
+		}
+		measurements = kept
 	}
 
-	points := make([]MetricPoint, len(measurements))
-	for i, m := range measurements {
-		points[i] = MetricPoint{
-			Timestamp: m.Timestamp,
-			Latency:   m.Latency,
-			IsHealthy: m.IsHealthy,
+	// Smooth before gap markers go in, over the samples the engine would have seen
+	var smoothed []float64
+	if smoothing != smoothingRaw {
+		latencies := make([]float64, len(measurements))
+		for i, m := range measurements {
+			latencies[i] = m.Latency
+		}
+		if smoothing == smoothingEWMA {
+			smoothed = stats.EWMASeries(latencies, alpha)
+		} else {
+			smoothed = stats.PercentileSeries(latencies, percentile, window)
 		}
 	}
 
+	points := metricPoints(measurements, smoothed, time.Duration(gapIntervals*interval)*time.Second)
+
 	writeJSON(w, map[string]interface{}{
-		"peer":   peerName,
-		"range":  rangeStr,
-		"points": points,
+		"peer":       peerName,
+		"range":      rangeStr,
+		"smoothing":  smoothing,
+		"resolution": resolutionRaw,
+		"points":     points,
+	})
+}
+
+// resolutionRaw asks /api/metrics for every measurement rather than time buckets
+const resolutionRaw = "raw"
+
+// defaultResolutions are the bucket sizes /api/metrics uses for long ranges when no
+// resolution is given; other ranges return raw measurements
+var defaultResolutions = map[string]time.Duration{
+	"7d":  15 * time.Minute,
+	"30d": time.Hour,
+}
+
+// resolutionLabel formats a bucket size without its zero trailing units: 1h0m0s is
+// "1h", 10m0s is "10m" and 1h30m0s is "1h30m"
+func resolutionLabel(resolution time.Duration) string {
+	label := resolution.String()
+	if strings.HasSuffix(label, "m0s") {
+		label = strings.TrimSuffix(label, "0s")
+	}
+	if strings.HasSuffix(label, "h0m") {
+		label = strings.TrimSuffix(label, "0m")
+	}
+	return label
+}
+
+// writeAggregatedMetrics responds to /api/metrics with one point per time bucket, its
+// latency the bucket's average and min/max/samples alongside. A bucket counts as healthy
+// when at least half its samples were; one with no answered probe reports latency -1 like
+// an unanswered raw sample. Runs of empty buckets become gap markers.
+func (s *Server) writeAggregatedMetrics(w http.ResponseWriter, peerName, rangeStr string, since time.Time, resolution time.Duration, normalOnly bool) {
+	state := ""
+	if normalOnly {
+		state = database.StateNormal
+	}
+	buckets, err := s.db.GetMeasurementsAggregated(peerName, since, resolution, state)
+	if err != nil {
+		s.logger.Error("Failed to get aggregated measurements: %v", err)
+		writeError(w, "failed to fetch measurements", http.StatusInternalServerError)
+		return
+	}
+
+	s.state.mu.RLock()
+	interval := s.state.Config.MeasurementInterval
+	if peer, ok := s.state.Peers[peerName]; ok && peer.MeasurementInterval > 0 {
+		interval = peer.MeasurementInterval
+	}
+	s.state.mu.RUnlock()
+	gapThreshold := resolution + time.Duration(gapIntervals*interval)*time.Second
+
+	points := make([]MetricPoint, 0, len(buckets))
+	for i, b := range buckets {
+		if i > 0 && b.Start.Sub(buckets[i-1].Start) > gapThreshold {
+			prevEnd := buckets[i-1].Start.Add(resolution)
+			gap := b.Start.Sub(prevEnd)
+			points = append(points, MetricPoint{
+				Timestamp:  prevEnd.Add(gap / 2),
+				Gap:        true,
+				GapSeconds: int64(gap.Seconds()),
+			})
+		}
+		latency := -1.0
+		if b.Avg != nil {
+			latency = *b.Avg
+		}
+		points = append(points, MetricPoint{
+			Timestamp:  b.Start,
+			Latency:    &latency,
+			IsHealthy:  b.Healthy >= 0.5,
+			PacketLoss: b.Loss,
+			Min:        b.Min,
+			Max:        b.Max,
+			Samples:    b.Samples,
+		})
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"peer":       peerName,
+		"range":      rangeStr,
+		"smoothing":  smoothingRaw,
+		"resolution": resolutionLabel(resolution),
+		"points":     points,
 	})
 }
 
+// Smoothing modes for /api/metrics (pNN percentiles are parsed separately)
+const (
+	smoothingRaw  = "raw"
+	smoothingEWMA = "ewma"
+)
+
+// gapIntervals is how many probe intervals without a measurement count as a gap
+const gapIntervals = 3
+
+// MetricPoint is one latency sample, or a gap marker (Gap set, Latency null) inserted
+// where no measurements were recorded so charts break the line instead of bridging it
+type MetricPoint struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Latency    *float64  `json:"latency"`
+	IsHealthy  bool      `json:"is_healthy"`
+	State      string    `json:"state,omitempty"`       // Operational state when measured (normal, frozen, dry-run)
+	Smoothed   *float64  `json:"smoothed,omitempty"`    // Smoothed latency when ?smoothing= is ewma or pNN
+	TTL        int       `json:"ttl,omitempty"`         // Reply TTL when known
+	PacketLoss float64   `json:"packet_loss,omitempty"` // Fraction of the measurement's echoes lost
+	Jitter     float64   `json:"jitter,omitempty"`      // Standard deviation of the measurement's echo RTTs in ms
+	Min        *float64  `json:"min,omitempty"`         // Bucketed points (?resolution=): lowest answered latency in the bucket
+	Max        *float64  `json:"max,omitempty"`         // Bucketed points: highest answered latency in the bucket
+	Samples    int       `json:"samples,omitempty"`     // Bucketed points: measurements in the bucket
+	Gap        bool      `json:"gap,omitempty"`
+	GapSeconds int64     `json:"gap_seconds,omitempty"`
+}
+
+// metricPoints converts measurements to API points, inserting a gap marker between
+// successive samples further apart than gapThreshold (0 disables gap detection).
+// smoothed, when set, holds one smoothed value per measurement (negative for none).
+func metricPoints(measurements []database.Measurement, smoothed []float64, gapThreshold time.Duration) []MetricPoint {
+	points := make([]MetricPoint, 0, len(measurements))
+	for i, m := range measurements {
+		if i > 0 && gapThreshold > 0 {
+			prev := measurements[i-1].Timestamp
+			if gap := m.Timestamp.Sub(prev); gap > gapThreshold {
+				points = append(points, MetricPoint{
+					Timestamp:  prev.Add(gap / 2),
+					Gap:        true,
+					GapSeconds: int64(gap.Seconds()),
+				})
+			}
+		}
+
+		latency := m.Latency
+		point := MetricPoint{
+			Timestamp:  m.Timestamp,
+			Latency:    &latency,
+			IsHealthy:  m.IsHealthy,
+			State:      m.State,
+			TTL:        m.TTL,
+			PacketLoss: m.PacketLoss,
+			Jitter:     m.Jitter,
+		}
+		if smoothed != nil && smoothed[i] >= 0 {
+			value := smoothed[i]
+			point.Smoothed = &value
+		}
+		points = append(points, point)
+	}
+	return points
+}
+
 // handleEvents returns system events
 func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 	rangeStr := r.URL.Query().Get("range")
@@ -198,22 +473,55 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Convert to API response format
-	type EventResponse struct {
-		ID         int64      `json:"id"`
-		Timestamp  time.Time  `json:"timestamp"`
-		EventType  string     `json:"event_type"`
-		PeerName   *string    `json:"peer_name,omitempty"`
-		OldPrimary *string    `json:"old_primary,omitempty"`
-		NewPrimary *string    `json:"new_primary,omitempty"`
-		OldHealth  *bool      `json:"old_health,omitempty"`
-		NewHealth  *bool      `json:"new_health,omitempty"`
-		Reason     string     `json:"reason"`
+	writeJSON(w, map[string]interface{}{
+		"range":  rangeStr,
+		"events": eventResponses(events),
+	})
+}
+
+// handleRecentEvents returns the latest events from memory, newest first. It never
+// touches the database, so it is cheap to poll and works while the database is down.
+func (s *Server) handleRecentEvents(w http.ResponseWriter, r *http.Request) {
+	if s.state.RecentEvents == nil {
+		writeError(w, "recent events not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	eventType := r.URL.Query().Get("type")
+	events := s.state.RecentEvents()
+	if eventType != "" {
+		kept := events[:0]
+		for _, e := range events {
+			if e.EventType == eventType {
+				kept = append(kept, e)
+			}
+		}
+		events = kept
 	}
 
-	eventResponses := make([]EventResponse, len(events))
+	writeJSON(w, map[string]interface{}{
+		"events": eventResponses(events),
+	})
+}
+
+// EventResponse is an event in API responses
+type EventResponse struct {
+	ID         int64     `json:"id"`
+	Timestamp  time.Time `json:"timestamp"`
+	EventType  string    `json:"event_type"`
+	PeerName   *string   `json:"peer_name,omitempty"`
+	OldPrimary *string   `json:"old_primary,omitempty"`
+	NewPrimary *string   `json:"new_primary,omitempty"`
+	OldHealth  *bool     `json:"old_health,omitempty"`
+	NewHealth  *bool     `json:"new_health,omitempty"`
+	Reason     string    `json:"reason"`
+}
+
+// eventResponses converts stored events to the API response format
+func eventResponses(events []database.Event) []EventResponse {
+	responses := make([]EventResponse, len(events))
 	for i, e := range events {
-		eventResponses[i] = EventResponse{
+		responses[i] = EventResponse{
 			ID:         e.ID,
 			Timestamp:  e.Timestamp,
 			EventType:  e.EventType,
@@ -225,13 +533,78 @@ func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
 			Reason:     e.Reason,
 		}
 	}
+	return responses
+}
+
+// handleNotificationHistory returns the recorded notification attempts, newest first,
+// optionally only one channel's (?channel=) or one outcome (?status=sent|failed|rate_limited)
+func (s *Server) handleNotificationHistory(w http.ResponseWriter, r *http.Request) {
+	rangeStr := r.URL.Query().Get("range")
+	channel := r.URL.Query().Get("channel")
+	status := r.URL.Query().Get("status")
+
+	// Parse range
+	var since time.Time
+	switch rangeStr {
+	case "1h":
+		since = time.Now().Add(-1 * time.Hour)
+	case "7d":
+		since = time.Now().Add(-7 * 24 * time.Hour)
+	case "30d":
+		since = time.Now().Add(-30 * 24 * time.Hour)
+	default:
+		since = time.Now().Add(-24 * time.Hour) // Default to 24 hours
+	}
+
+	switch status {
+	case "", notifications.OutcomeSent, notifications.OutcomeFailed, notifications.OutcomeRateLimited:
+	default:
+		writeError(w, "status must be sent, failed or rate_limited", http.StatusBadRequest)
+		return
+	}
+
+	if s.db == nil {
+		writeError(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	records, err := s.db.GetNotifications(since, channel, status)
+	if err != nil {
+		s.logger.Error("Failed to get notification history: %v", err)
+		writeError(w, "failed to fetch notification history", http.StatusInternalServerError)
+		return
+	}
+
+	responses := make([]NotificationRecordResponse, len(records))
+	for i, n := range records {
+		responses[i] = NotificationRecordResponse{
+			ID:        n.ID,
+			Timestamp: n.Timestamp,
+			Channel:   n.ChannelType,
+			EventID:   n.EventID,
+			Status:    n.Status,
+			Message:   n.Message,
+			Error:     n.Error,
+		}
+	}
 
 	writeJSON(w, map[string]interface{}{
-		"range":  rangeStr,
-		"events": eventResponses,
+		"range":         rangeStr,
+		"notifications": responses,
 	})
 }
 
+// NotificationRecordResponse is one recorded notification attempt in API responses
+type NotificationRecordResponse struct {
+	ID        int64     `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Channel   string    `json:"channel"`
+	EventID   *int64    `json:"event_id,omitempty"` // The event it notified about, see /api/events
+	Status    string    `json:"status"`             // sent, failed or rate_limited
+	Message   string    `json:"message"`
+	Error     *string   `json:"error,omitempty"`
+}
+
 // NotificationSettingsResponse represents notification configuration
 type NotificationSettingsResponse struct {
 	Enabled          bool                    `json:"enabled"`
@@ -408,7 +781,7 @@ func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request)
 
 	// Type assertion to access SendTest method
 	type testSender interface {
-		SendTest(channelName string) error
+		SendTest(channelName string) ([]notifications.TestResult, error)
 	}
 
 	notifier, ok := notifierInterface.(testSender)
@@ -419,15 +792,181 @@ func (s *Server) handleTestNotification(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Actually send the test
-	if err := notifier.SendTest(req.Channel); err != nil {
+	results, err := notifier.SendTest(req.Channel)
+	if err != nil {
 		s.logger.Error("Test notification failed: %v", err)
-		writeError(w, fmt.Sprintf("test notification failed: %v", err), http.StatusInternalServerError)
+		writeError(w, fmt.Sprintf("test notification failed: %v", err), http.StatusBadRequest)
 		return
 	}
 
+	success, anyOK := true, false
+	for _, result := range results {
+		if result.OK {
+			anyOK = true
+		} else {
+			success = false
+		}
+	}
+
+	// Scripts go by the status code: 502 when no channel got the notification through
+	if !anyOK {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+	}
 	writeJSON(w, map[string]interface{}{
-		"success": true,
-		"message": "test notification sent successfully",
+		"success": success,
 		"channel": req.Channel,
+		"results": results,
+	})
+}
+
+// Errors returned by the ResetPeer and SetPeerDisabled callbacks
+var (
+	ErrPeerNotFound        = errors.New("peer not found")
+	ErrPeerResetNeedsForce = errors.New("peer is accumulating unhealthy measurements; use force=true to reset anyway")
+)
+
+// handleResetPeer clears a peer's damping counters and measurement window
+func (s *Server) handleResetPeer(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	force := r.URL.Query().Get("force") == "true"
+
+	s.state.mu.RLock()
+	resetFunc := s.state.ResetPeer
+	s.state.mu.RUnlock()
+
+	if resetFunc == nil {
+		writeError(w, "peer reset not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := resetFunc(name, force); err != nil {
+		switch {
+		case errors.Is(err, ErrPeerNotFound):
+			writeError(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrPeerResetNeedsForce):
+			writeError(w, err.Error(), http.StatusConflict)
+		default:
+			writeError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"peer":    name,
+		"forced":  force,
+	})
+}
+
+// handleDisablePeer takes a peer out of ECMP for maintenance; it is still probed
+func (s *Server) handleDisablePeer(w http.ResponseWriter, r *http.Request) {
+	s.setPeerDisabled(w, mux.Vars(r)["name"], true)
+}
+
+// handleEnablePeer lets a disabled peer back into ECMP when healthy
+func (s *Server) handleEnablePeer(w http.ResponseWriter, r *http.Request) {
+	s.setPeerDisabled(w, mux.Vars(r)["name"], false)
+}
+
+func (s *Server) setPeerDisabled(w http.ResponseWriter, name string, disabled bool) {
+	s.state.mu.RLock()
+	setFunc := s.state.SetPeerDisabled
+	s.state.mu.RUnlock()
+
+	if setFunc == nil {
+		writeError(w, "peer enable/disable not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := setFunc(name, disabled); err != nil {
+		if errors.Is(err, ErrPeerNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+		} else {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"success":  true,
+		"peer":     name,
+		"disabled": disabled,
+	})
+}
+
+// handlePriorities returns the priority each peer should hold, the last applied values
+// and whether the router-facing state matches
+func (s *Server) handlePriorities(w http.ResponseWriter, r *http.Request) {
+	s.state.mu.RLock()
+	prioritiesFunc := s.state.Priorities
+	s.state.mu.RUnlock()
+
+	if prioritiesFunc == nil {
+		writeError(w, "priorities not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, prioritiesFunc())
+}
+
+// handleExplain returns a step-by-step explanation of why each peer is in or out of ECMP
+func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
+	s.state.mu.RLock()
+	explainFunc := s.state.Explain
+	s.state.mu.RUnlock()
+
+	if explainFunc == nil {
+		writeError(w, "explanation not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	writeJSON(w, explainFunc())
+}
+
+// handleDryRunReport returns the would-be routing changes accumulated in dry-run mode
+func (s *Server) handleDryRunReport(w http.ResponseWriter, r *http.Request) {
+	s.state.mu.RLock()
+	reportFunc := s.state.DryRunReport
+	s.state.mu.RUnlock()
+
+	if reportFunc == nil {
+		writeError(w, "not running in dry-run mode", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, reportFunc())
+}
+
+// handleFreeze holds all routing decisions at their current values
+func (s *Server) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	s.setFrozen(w, true)
+}
+
+// handleUnfreeze resumes automatic routing decisions
+func (s *Server) handleUnfreeze(w http.ResponseWriter, r *http.Request) {
+	s.setFrozen(w, false)
+}
+
+func (s *Server) setFrozen(w http.ResponseWriter, frozen bool) {
+	s.state.mu.Lock()
+	setFunc := s.state.SetFrozen
+	s.state.mu.Unlock()
+
+	if setFunc == nil {
+		writeError(w, "freeze control not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	setFunc(frozen)
+
+	s.state.mu.Lock()
+	s.state.Frozen = frozen
+	s.refreshSummaryLocked()
+	s.state.mu.Unlock()
+
+	writeJSON(w, map[string]interface{}{
+		"success": true,
+		"frozen":  frozen,
 	})
 }
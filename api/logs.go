@@ -0,0 +1,124 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Bounds for GET /api/logs?lines=
+const (
+	defaultLogLines = 500
+	maxLogLines     = 10000
+)
+
+// logTailChunk is how much of the log file is read per step back from its end
+const logTailChunk = 64 * 1024
+
+// logLevels orders the level tags the logger writes; untagged lines count as INFO
+var logLevels = map[string]int{"DEBUG": 0, "INFO": 1, "WARN": 2, "ERROR": 3}
+
+// handleLogs returns the last lines of the log file, oldest first, optionally only
+// those at or above ?level=. Only the end of the file is read, however large it is.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	s.state.mu.RLock()
+	path := s.state.Config.LogFile
+	s.state.mu.RUnlock()
+	if path == "" {
+		writeError(w, "log access not enabled (set logging.file and api.expose_logs)", http.StatusNotFound)
+		return
+	}
+
+	lines := defaultLogLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, "lines must be a positive number", http.StatusBadRequest)
+			return
+		}
+		lines = min(n, maxLogLines)
+	}
+
+	minLevel := 0
+	if v := r.URL.Query().Get("level"); v != "" {
+		level, ok := logLevels[strings.ToUpper(v)]
+		if !ok {
+			writeError(w, "level must be debug, info, warn or error", http.StatusBadRequest)
+			return
+		}
+		minLevel = level
+	}
+
+	tail, err := tailLog(path, lines, func(line string) bool {
+		return logLineLevel(line) >= minLevel
+	})
+	if err != nil {
+		s.logger.Error("Failed to read log file: %v", err)
+		writeError(w, "failed to read log file", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"lines": tail,
+	})
+}
+
+// logLineLevel returns the level of a log line from its [LEVEL] tag
+func logLineLevel(line string) int {
+	start := strings.IndexByte(line, '[')
+	if start >= 0 {
+		if end := strings.IndexByte(line[start:], ']'); end > 0 {
+			if level, ok := logLevels[line[start+1:start+end]]; ok {
+				return level
+			}
+		}
+	}
+	return logLevels["INFO"]
+}
+
+// tailLog reads the file backwards in chunks until it has n lines that keep accepts,
+// returning them oldest first
+func tailLog(path string, n int, keep func(string) bool) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string // Newest first
+	var partial []byte // Start of the line that continues into the chunk read last
+	buf := make([]byte, logTailChunk)
+	offset := info.Size()
+	for offset > 0 && len(lines) < n {
+		size := min(int64(logTailChunk), offset)
+		offset -= size
+		if _, err := file.ReadAt(buf[:size], offset); err != nil {
+			return nil, err
+		}
+
+		// The first piece may start in the chunk before, so hold it back (copied, as
+		// buf is reused)
+		parts := bytes.Split(append(buf[:size:size], partial...), []byte{'\n'})
+		partial = append([]byte(nil), parts[0]...)
+		for i := len(parts) - 1; i > 0 && len(lines) < n; i-- {
+			if line := string(parts[i]); line != "" && keep(line) {
+				lines = append(lines, line)
+			}
+		}
+	}
+	if offset == 0 && len(lines) < n && len(partial) > 0 && keep(string(partial)) {
+		lines = append(lines, string(partial))
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines, nil
+}
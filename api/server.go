@@ -3,11 +3,16 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"lagbuster/database"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -17,40 +22,77 @@ import (
 
 // Config represents the application configuration (subset needed for API)
 type Config struct {
-	MeasurementInterval int                   `yaml:"measurement_interval"`
-	Notifications       NotificationConfig `yaml:"notifications"`
+	MeasurementInterval  int                `yaml:"measurement_interval" json:"measurement_interval"`
+	MeasurementWindow    int                `yaml:"measurement_window" json:"measurement_window"`         // Samples the engine decides from (pNN smoothing window)
+	EWMAAlpha            float64            `yaml:"ewma_alpha" json:"ewma_alpha"`                         // The engine's EWMA alpha, default for ewma smoothing
+	StatusUpdateInterval int                `yaml:"status_update_interval" json:"status_update_interval"` // Minimum seconds between pushed status updates
+	PathPrefix           string             `yaml:"path_prefix" json:"path_prefix"`                       // Prefix for every route, e.g. "/lagbuster"
+	LogFile              string             `yaml:"-" json:"-"`                                           // Log file served by /api/logs, empty = disabled
+	Notifications        NotificationConfig `yaml:"notifications" json:"notifications"`
 }
 
 type NotificationConfig struct {
-	Enabled          bool           `yaml:"enabled"`
-	RateLimitMinutes int            `yaml:"rate_limit_minutes"`
-	Email            EmailConfig    `yaml:"email"`
-	Slack            SlackConfig    `yaml:"slack"`
-	Telegram         TelegramConfig `yaml:"telegram"`
+	Enabled                        bool            `yaml:"enabled" json:"enabled"`
+	RateLimitMinutes               int             `yaml:"rate_limit_minutes" json:"rate_limit_minutes"`
+	RateLimits                     map[string]int  `yaml:"rate_limits" json:"rate_limits"`
+	SuppressStartupIfRestartWithin int             `yaml:"suppress_startup_if_restart_within" json:"suppress_startup_if_restart_within"`
+	SuppressDuringWarmup           bool            `yaml:"suppress_during_warmup" json:"suppress_during_warmup"`
+	PersistRateLimits              bool            `yaml:"persist_rate_limits" json:"persist_rate_limits"`
+	Timezone                       string          `yaml:"timezone" json:"timezone"`
+	TimeFormat                     string          `yaml:"time_format" json:"time_format"`
+	DigestMinutes                  int             `yaml:"digest_minutes" json:"digest_minutes"`
+	Email                          EmailConfig     `yaml:"email" json:"email"`
+	Slack                          SlackConfig     `yaml:"slack" json:"slack"`
+	Telegram                       TelegramConfig  `yaml:"telegram" json:"telegram"`
+	Webhook                        WebhookConfig   `yaml:"webhook" json:"webhook"`
+	PagerDuty                      PagerDutyConfig `yaml:"pagerduty" json:"pagerduty"`
 }
 
 type EmailConfig struct {
-	Enabled    bool     `yaml:"enabled"`
-	SMTPHost   string   `yaml:"smtp_host"`
-	SMTPPort   int      `yaml:"smtp_port"`
-	Username   string   `yaml:"username"`
-	Password   string   `yaml:"password"`
-	From       string   `yaml:"from"`
-	To         []string `yaml:"to"`
-	EventTypes []string `yaml:"event_types"`
+	Enabled          bool              `yaml:"enabled" json:"enabled"`
+	SMTPHost         string            `yaml:"smtp_host" json:"smtp_host"`
+	SMTPPort         int               `yaml:"smtp_port" json:"smtp_port"`
+	Username         string            `yaml:"username" json:"username"`
+	Password         string            `yaml:"password" json:"password"`
+	From             string            `yaml:"from" json:"from"`
+	To               []string          `yaml:"to" json:"to"`
+	EventTypes       []string          `yaml:"event_types" json:"event_types"`
+	Groups           []string          `yaml:"groups" json:"groups"`
+	Templates        map[string]string `yaml:"templates,omitempty" json:"templates,omitempty"`
+	SubjectTemplates map[string]string `yaml:"subject_templates,omitempty" json:"subject_templates,omitempty"`
 }
 
 type SlackConfig struct {
-	Enabled    bool     `yaml:"enabled"`
-	WebhookURL string   `yaml:"webhook_url"`
-	EventTypes []string `yaml:"event_types"`
+	Enabled    bool              `yaml:"enabled" json:"enabled"`
+	WebhookURL string            `yaml:"webhook_url" json:"webhook_url"`
+	EventTypes []string          `yaml:"event_types" json:"event_types"`
+	Groups     []string          `yaml:"groups" json:"groups"`
+	Templates  map[string]string `yaml:"templates,omitempty" json:"templates,omitempty"`
 }
 
 type TelegramConfig struct {
-	Enabled    bool     `yaml:"enabled"`
-	BotToken   string   `yaml:"bot_token"`
-	ChatID     string   `yaml:"chat_id"`
-	EventTypes []string `yaml:"event_types"`
+	Enabled    bool              `yaml:"enabled" json:"enabled"`
+	BotToken   string            `yaml:"bot_token" json:"bot_token"`
+	ChatID     string            `yaml:"chat_id" json:"chat_id"`
+	EventTypes []string          `yaml:"event_types" json:"event_types"`
+	Groups     []string          `yaml:"groups" json:"groups"`
+	Templates  map[string]string `yaml:"templates,omitempty" json:"templates,omitempty"`
+}
+
+type WebhookConfig struct {
+	Enabled    bool              `yaml:"enabled" json:"enabled"`
+	URL        string            `yaml:"url" json:"url"`
+	Headers    map[string]string `yaml:"headers" json:"headers"`
+	EventTypes []string          `yaml:"event_types" json:"event_types"`
+	Groups     []string          `yaml:"groups" json:"groups"`
+}
+
+type PagerDutyConfig struct {
+	Enabled    bool     `yaml:"enabled" json:"enabled"`
+	RoutingKey string   `yaml:"routing_key" json:"routing_key"`
+	Source     string   `yaml:"source" json:"source"`
+	EventTypes []string `yaml:"event_types" json:"event_types"`
+	Groups     []string `yaml:"groups" json:"groups"`
 }
 
 // AppState represents the current application state (same as lagbuster.go)
@@ -58,17 +100,39 @@ type AppState struct {
 	StartTime            time.Time
 	Peers                map[string]*PeerState
 	Config               *Config
-	Notifier             interface{}          // notifications.Notifier (avoid circular import)
-	ConfigPath           string               // Path to config file for saving
-	RebuildNotifications func(*Config) error // Callback to rebuild notification channels
+	Notifier             interface{}                            // notifications.Notifier (avoid circular import)
+	ConfigPath           string                                 // Path to config file for saving
+	RebuildNotifications func(*Config) error                    // Callback to rebuild notification channels
+	Frozen               bool                                   // Whether routing decisions are frozen
+	SetFrozen            func(bool)                             // Callback to toggle the routing kill-switch
+	ResetPeer            func(name string, force bool) error    // Callback to clear a peer's damping state
+	SetPeerDisabled      func(name string, disabled bool) error // Callback to take a peer out of ECMP or put it back
+	DryRunReport         func() interface{}                     // Callback returning the dry-run report (nil unless dry-run)
+	Priorities           func() interface{}                     // Callback returning computed vs applied priorities
+	Explain              func() interface{}                     // Callback returning the reasoning behind the current routing
+	ReferenceQuorum      *ReferenceQuorumStatus                 // Latest reference target check, nil when not configured
+	Standby              bool                                   // Warm standby, not applying routing (ha.role)
+	DBWriteLagMs         *float64                               // Duration of the latest measurement write, nil without a database
+	RecentEvents         func() []database.Event                // Callback returning the in-memory recent events, newest first
 	mu                   sync.RWMutex
 }
 
+// ReferenceQuorumStatus reports whether enough reference targets answered for peer
+// measurements to be trusted
+type ReferenceQuorumStatus struct {
+	OK          bool     `json:"ok"`
+	Responding  int      `json:"responding"`
+	Required    int      `json:"required"`
+	Total       int      `json:"total"`
+	Unreachable []string `json:"unreachable,omitempty"`
+}
+
 // PeerState represents a peer's current state
 type PeerState struct {
 	Name                      string
 	Hostname                  string
 	Baseline                  float64
+	BaselineWindow            string // "HH:MM-HH:MM" of the baseline window in effect, empty for expected_baseline
 	CurrentLatency            float64
 	IsHealthy                 bool
 	ConsecutiveHealthyCount   int
@@ -76,6 +140,15 @@ type PeerState struct {
 	Measurements              []float64
 	BGPSessionUp              bool
 	BGPSessionState           string
+	Disabled                  bool // Taken out of ECMP by an operator
+	LastProbeError            string
+	PacketLoss                float64 // Fraction of the latest measurement's echoes lost
+	ExpectedLoss              float64 // Fraction the peer normally loses (peers[].expected_loss)
+	Jitter                    float64 // Standard deviation of the latest measurement's echo RTTs in ms
+	SmoothedLatency           float64 // EWMA of answered samples, -1 until the first reply
+	PathAsymmetry             *float64
+	MeasurementInterval       int       // Seconds between this peer's probes
+	HoldUntil                 time.Time // Kept in ECMP until then after recovering (zero when not held)
 }
 
 // Server is the HTTP API server
@@ -84,9 +157,13 @@ type Server struct {
 	db       *database.DB
 	router   *mux.Router
 	upgrader websocket.Upgrader
-	clients  map[*websocket.Conn]bool
+	clients  map[subscriber]bool
 	mu       sync.RWMutex
 	logger   Logger
+	summary  StatusSummary // Cached /api/status/summary, guarded by state.mu
+
+	statusDirty  chan struct{} // Signalled when peer state changes, coalesced by broadcastLoop
+	statusUrgent atomic.Bool   // An event fired; push the next status change without waiting
 }
 
 // Logger interface for logging
@@ -104,45 +181,89 @@ func NewServer(state *AppState, db *database.DB, logger Logger) *Server {
 		db:    db,
 		router: mux.NewRouter(),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true }, // Allow all origins for development
+			CheckOrigin:  func(r *http.Request) bool { return true }, // Allow all origins for development
+			Subprotocols: []string{wsMsgpackProtocol},
 		},
-		clients: make(map[*websocket.Conn]bool),
-		logger:  logger,
+		clients:     make(map[subscriber]bool),
+		logger:      logger,
+		statusDirty: make(chan struct{}, 1),
 	}
 
+	state.mu.Lock()
+	s.refreshSummaryLocked()
+	state.mu.Unlock()
+
 	s.setupRoutes()
 	return s
 }
 
 func (s *Server) setupRoutes() {
+	// All routes live under api.path_prefix when one is configured
+	router := s.router
+	if prefix := NormalizePathPrefix(s.state.Config.PathPrefix); prefix != "" {
+		router = s.router.PathPrefix(prefix).Subrouter()
+	}
+
 	// API routes
-	s.router.HandleFunc("/api/status", s.handleStatus).Methods("GET")
-	s.router.HandleFunc("/api/peers", s.handlePeers).Methods("GET")
-	s.router.HandleFunc("/api/metrics", s.handleMetrics).Methods("GET")
-	s.router.HandleFunc("/api/events", s.handleEvents).Methods("GET")
-	s.router.HandleFunc("/api/settings/notifications", s.handleGetNotificationSettings).Methods("GET")
-	s.router.HandleFunc("/api/settings/notifications", s.handleUpdateNotificationSettings).Methods("PUT", "POST")
-	s.router.HandleFunc("/api/settings/notifications/test", s.handleTestNotification).Methods("POST")
+	router.HandleFunc("/api/status", s.handleStatus).Methods("GET")
+	router.HandleFunc("/api/status/summary", s.handleStatusSummary).Methods("GET")
+	router.HandleFunc("/api/peers", s.handlePeers).Methods("GET")
+	router.HandleFunc("/api/peers/{name}/reset", s.handleResetPeer).Methods("POST")
+	router.HandleFunc("/api/peers/{name}/disable", s.handleDisablePeer).Methods("POST")
+	router.HandleFunc("/api/peers/{name}/enable", s.handleEnablePeer).Methods("POST")
+	router.HandleFunc("/api/metrics", s.handleMetrics).Methods("GET")
+	router.HandleFunc("/api/metrics/export", s.handleMetricsExport).Methods("GET")
+	router.HandleFunc("/api/events", s.handleEvents).Methods("GET")
+	router.HandleFunc("/api/events/stream", s.handleEventStream).Methods("GET")
+	router.HandleFunc("/api/events/recent", s.handleRecentEvents).Methods("GET")
+	router.HandleFunc("/api/notifications/history", s.handleNotificationHistory).Methods("GET")
+	router.HandleFunc("/api/settings/notifications", s.handleGetNotificationSettings).Methods("GET")
+	router.HandleFunc("/api/settings/notifications", s.handleUpdateNotificationSettings).Methods("PUT", "POST")
+	router.HandleFunc("/api/settings/notifications/test", s.handleTestNotification).Methods("POST")
+	router.HandleFunc("/api/freeze", s.handleFreeze).Methods("POST")
+	router.HandleFunc("/api/unfreeze", s.handleUnfreeze).Methods("POST")
+	router.HandleFunc("/api/dryrun/report", s.handleDryRunReport).Methods("GET")
+	router.HandleFunc("/api/priorities", s.handlePriorities).Methods("GET")
+	router.HandleFunc("/api/explain", s.handleExplain).Methods("GET")
+	router.HandleFunc("/api/logs", s.handleLogs).Methods("GET")
 
 	// WebSocket
-	s.router.HandleFunc("/ws", s.handleWebSocket)
+	router.HandleFunc("/ws", s.handleWebSocket)
+
+	// Server-Sent Events (same feed as /ws)
+	router.HandleFunc("/api/sse", s.handleSSE).Methods("GET")
 
 	// Enable CORS for development
 	s.router.Use(corsMiddleware)
 }
 
+// NormalizePathPrefix turns a configured path prefix into the form routes are mounted
+// under: a leading slash and no trailing slash ("" for none)
+func NormalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
 // Start starts the API server
 func (s *Server) Start(ctx context.Context, addr string) error {
 	srv := &http.Server{
 		Addr:    addr,
 		Handler: s.router,
+		// Requests inherit ctx so streaming clients (SSE, event stream) end on
+		// shutdown instead of holding it open until the timeout
+		BaseContext: func(net.Listener) context.Context { return ctx },
 	}
 
 	// Start broadcast goroutine
 	go s.broadcastLoop(ctx)
 
 	// Graceful shutdown
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		<-ctx.Done()
 		s.logger.Info("Shutting down API server...")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -151,44 +272,93 @@ func (s *Server) Start(ctx context.Context, addr string) error {
 	}()
 
 	s.logger.Info("API server listening on %s", addr)
-	return srv.ListenAndServe()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	// Let open requests finish before the caller closes the database
+	<-stopped
+	return nil
 }
 
-// Broadcast sends an update to all connected WebSocket clients
-func (s *Server) Broadcast(data interface{}) {
+// Broadcast sends a message to all connected subscribers (WebSocket, SSE and event streams).
+// A stream subscriber that has fallen behind misses the message; one whose connection
+// has failed is unsubscribed and closed.
+func (s *Server) Broadcast(msg *message) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	msg, err := json.Marshal(data)
-	if err != nil {
-		s.logger.Error("Failed to marshal broadcast data: %v", err)
-		return
-	}
-
+	var failed []subscriber
 	for client := range s.clients {
-		if err := client.WriteMessage(websocket.TextMessage, msg); err != nil {
-			s.logger.Warn("Failed to write to websocket client: %v", err)
-			client.Close()
-			delete(s.clients, client)
+		err := client.send(msg)
+		switch {
+		case err == nil:
+		case errors.Is(err, errSubscriberBehind):
+			// A slow reader loses this message but stays subscribed
+			s.logger.Warn("Subscriber is not keeping up, dropping %s", msg.Type)
+		default:
+			s.logger.Warn("Failed to send %s to subscriber: %v", msg.Type, err)
+			failed = append(failed, client)
 		}
 	}
+	s.mu.RUnlock()
+
+	for _, client := range failed {
+		s.unsubscribe(client)
+		client.close()
+	}
+}
+
+// subscribe registers a subscriber for broadcasts
+func (s *Server) subscribe(client subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clients[client] = true
 }
 
+// unsubscribe removes a subscriber from broadcasts
+func (s *Server) unsubscribe(client subscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, client)
+}
+
+// broadcastLoop pushes status updates to subscribers. State changes are coalesced so
+// clients get at most one update per status_update_interval, always carrying the latest
+// state; a change following an event is pushed immediately. A full status still goes
+// out every 10 seconds regardless.
 func (s *Server) broadcastLoop(ctx context.Context) {
+	s.state.mu.RLock()
+	interval := time.Duration(s.state.Config.StatusUpdateInterval) * time.Second
+	s.state.mu.RUnlock()
+	if interval <= 0 {
+		interval = time.Second
+	}
+
 	ticker := time.NewTicker(10 * time.Second)
 	defer ticker.Stop()
 
+	var lastSent time.Time
+	var flushTimer <-chan time.Time // Non-nil while a coalesced update is pending
+
+	flush := func() {
+		s.Broadcast(newMessage(messageStatusUpdate, "", s.getCurrentStatus()))
+		lastSent = time.Now()
+		flushTimer = nil
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-s.statusDirty:
+			if s.statusUrgent.Swap(false) || time.Since(lastSent) >= interval {
+				flush()
+			} else if flushTimer == nil {
+				flushTimer = time.After(interval - time.Since(lastSent))
+			}
+		case <-flushTimer:
+			flush()
 		case <-ticker.C:
 			// Broadcast current status every 10 seconds
-			status := s.getCurrentStatus()
-			s.Broadcast(map[string]interface{}{
-				"type": "status_update",
-				"data": status,
-			})
+			flush()
 		}
 	}
 }
@@ -238,8 +408,16 @@ func (s *Server) saveConfig() error {
 		return fmt.Errorf("reading config file: %w", err)
 	}
 
+	// Write back in the same format the config was read in
+	isJSON := strings.EqualFold(filepath.Ext(s.state.ConfigPath), ".json")
+
 	var fullConfig map[string]interface{}
-	if err := yaml.Unmarshal(data, &fullConfig); err != nil {
+	if isJSON {
+		err = json.Unmarshal(data, &fullConfig)
+	} else {
+		err = yaml.Unmarshal(data, &fullConfig)
+	}
+	if err != nil {
 		return fmt.Errorf("parsing config file: %w", err)
 	}
 
@@ -249,7 +427,12 @@ func (s *Server) saveConfig() error {
 	s.state.mu.RUnlock()
 
 	// Write back to file
-	updatedData, err := yaml.Marshal(fullConfig)
+	var updatedData []byte
+	if isJSON {
+		updatedData, err = json.MarshalIndent(fullConfig, "", "  ")
+	} else {
+		updatedData, err = yaml.Marshal(fullConfig)
+	}
 	if err != nil {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
@@ -295,6 +478,37 @@ func (s *Server) rebuildNotificationChannels() {
 	}
 }
 
+// SetReferenceQuorum records the latest reference target check for status responses
+func (s *Server) SetReferenceQuorum(status *ReferenceQuorumStatus) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.ReferenceQuorum = status
+}
+
+// SetStandby records whether this instance is a warm standby for status responses
+func (s *Server) SetStandby(standby bool) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.Standby = standby
+}
+
+// SetMeasurementConfig updates the engine settings metrics responses depend on after a
+// config reload
+func (s *Server) SetMeasurementConfig(interval, window int, ewmaAlpha float64) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.Config.MeasurementInterval = interval
+	s.state.Config.MeasurementWindow = window
+	s.state.Config.EWMAAlpha = ewmaAlpha
+}
+
+// SetDBWriteLag records how long the latest measurement write took for status responses
+func (s *Server) SetDBWriteLag(lagMs *float64) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.DBWriteLagMs = lagMs
+}
+
 // UpdateState updates the server's state without recreating the server
 // This preserves Config, Notifier, and ConfigPath while updating dynamic fields
 func (s *Server) UpdateState(startTime time.Time, peers map[string]*PeerState) {
@@ -303,4 +517,11 @@ func (s *Server) UpdateState(startTime time.Time, peers map[string]*PeerState) {
 
 	s.state.StartTime = startTime
 	s.state.Peers = peers
+	s.refreshSummaryLocked()
+
+	// Wake the broadcaster without blocking the monitoring loop
+	select {
+	case s.statusDirty <- struct{}{}:
+	default:
+	}
 }
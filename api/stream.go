@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// errSubscriberBehind reports a message dropped because the subscriber's queue is full.
+// The subscriber stays connected and gets the messages that follow.
+var errSubscriberBehind = errors.New("client is not keeping up")
+
+// streamSubscriber delivers broadcasts over a long-lived HTTP response.
+// Messages are encoded by format and queued; the owning handler writes them out.
+type streamSubscriber struct {
+	queue  chan []byte
+	format func(msg *message) ([]byte, bool) // returns false to skip a message
+	done   chan struct{}
+}
+
+func newStreamSubscriber(format func(msg *message) ([]byte, bool)) *streamSubscriber {
+	return &streamSubscriber{
+		queue:  make(chan []byte, 32),
+		format: format,
+		done:   make(chan struct{}),
+	}
+}
+
+func (c *streamSubscriber) send(msg *message) error {
+	data, ok := c.format(msg)
+	if !ok {
+		return nil
+	}
+
+	// Never block the broadcaster on a slow reader
+	select {
+	case c.queue <- data:
+		return nil
+	default:
+		return errSubscriberBehind
+	}
+}
+
+func (c *streamSubscriber) close() {
+	select {
+	case <-c.done:
+	default:
+		close(c.done)
+	}
+}
+
+// serveStream registers the subscriber and writes queued messages until the client disconnects
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, client *streamSubscriber, contentType, kind string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	s.subscribe(client)
+	s.logger.Info("New %s client connected from %s", kind, r.RemoteAddr)
+
+	// Cleanup on disconnect
+	defer func() {
+		s.unsubscribe(client)
+		client.close()
+		s.logger.Info("%s client disconnected from %s", kind, r.RemoteAddr)
+	}()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-client.done:
+			return
+		case data := <-client.queue:
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// handleEventStream streams new events as newline-delimited JSON until the client disconnects
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	eventType := r.URL.Query().Get("type")
+
+	client := newStreamSubscriber(func(msg *message) ([]byte, bool) {
+		if msg.Type != messageEvent || (eventType != "" && msg.EventType != eventType) {
+			return nil, false
+		}
+		line, err := json.Marshal(msg.Data)
+		if err != nil {
+			return nil, false
+		}
+		return append(line, '\n'), true
+	})
+
+	s.serveStream(w, r, client, "application/x-ndjson", "Event stream")
+}
+
+// handleSSE pushes the same status updates and events as /ws, formatted as Server-Sent Events
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	client := newStreamSubscriber(func(msg *message) ([]byte, bool) {
+		data, err := msg.envelopeJSON()
+		if err != nil {
+			return nil, false
+		}
+		return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", msg.Type, data)), true
+	})
+
+	// Queue initial status so the client renders immediately
+	client.send(newMessage(messageStatusUpdate, "", s.getCurrentStatus()))
+
+	s.serveStream(w, r, client, "text/event-stream", "SSE")
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Broadcast message types
+const (
+	messageStatusUpdate = "status_update"
+	messageEvent        = "event"
+	messageHeartbeat    = "heartbeat"
+)
+
+// message is a single broadcast shared by all subscribers
+type message struct {
+	Type      string      // messageStatusUpdate, messageEvent or messageHeartbeat
+	EventType string      // Event type for messageEvent (e.g. "health_change")
+	Data      interface{} // Payload
+
+	once     sync.Once
+	envelope []byte
+	err      error
+
+	packOnce sync.Once
+	packed   []byte
+	packErr  error
+}
+
+func newMessage(msgType, eventType string, data interface{}) *message {
+	return &message{Type: msgType, EventType: eventType, Data: data}
+}
+
+// envelopeJSON returns the {"type": ..., "data": ...} encoding, marshaled once per message
+func (m *message) envelopeJSON() ([]byte, error) {
+	m.once.Do(func() {
+		m.envelope, m.err = json.Marshal(map[string]interface{}{
+			"type": m.Type,
+			"data": m.Data,
+		})
+	})
+	return m.envelope, m.err
+}
+
+// envelopeMsgpack returns the same envelope as envelopeJSON encoded as MessagePack,
+// marshaled once per message. Field names follow the json tags so both encodings
+// carry identical keys.
+func (m *message) envelopeMsgpack() ([]byte, error) {
+	m.packOnce.Do(func() {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.SetCustomStructTag("json")
+		m.packErr = enc.Encode(map[string]interface{}{
+			"type": m.Type,
+			"data": m.Data,
+		})
+		m.packed = buf.Bytes()
+	})
+	return m.packed, m.packErr
+}
+
+// subscriber is a connected client receiving broadcasts.
+// Implementations must not block the caller for long; send is invoked from
+// the monitoring loop and the periodic status broadcaster.
+type subscriber interface {
+	send(msg *message) error
+	close()
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// StatusSummary is a compact status for frequent polling. It only changes when peer
+// health, BGP state, the peer set or the kill-switch changes, not on every latency sample.
+type StatusSummary struct {
+	Version          uint64   `json:"version"`
+	HealthyPeerCount int      `json:"healthy_peer_count"`
+	TotalPeerCount   int      `json:"total_peer_count"`
+	UnhealthyPeers   []string `json:"unhealthy_peers"`
+	BGPDownPeers     []string `json:"bgp_down_peers"`
+	Frozen           bool     `json:"frozen"`
+}
+
+// refreshSummaryLocked recomputes the cached summary and bumps its version if anything
+// changed. Callers must hold s.state.mu for writing.
+func (s *Server) refreshSummaryLocked() {
+	next := StatusSummary{
+		TotalPeerCount: len(s.state.Peers),
+		UnhealthyPeers: []string{},
+		BGPDownPeers:   []string{},
+		Frozen:         s.state.Frozen,
+	}
+	for name, peer := range s.state.Peers {
+		if peer.IsHealthy {
+			next.HealthyPeerCount++
+		} else {
+			next.UnhealthyPeers = append(next.UnhealthyPeers, name)
+		}
+		if !peer.BGPSessionUp {
+			next.BGPDownPeers = append(next.BGPDownPeers, name)
+		}
+	}
+	sort.Strings(next.UnhealthyPeers)
+	sort.Strings(next.BGPDownPeers)
+
+	next.Version = s.summary.Version
+	if s.summary.Version == 0 || !reflect.DeepEqual(next, s.summary) {
+		next.Version++
+		s.summary = next
+	}
+}
+
+// handleStatusSummary returns the compact status, or 304 when the client's ETag is current
+func (s *Server) handleStatusSummary(w http.ResponseWriter, r *http.Request) {
+	s.state.mu.RLock()
+	summary := s.summary
+	startTime := s.state.StartTime
+	s.state.mu.RUnlock()
+
+	// Versions restart at 1 with the process, so scope the ETag to this run
+	etag := fmt.Sprintf(`"%d-%d"`, startTime.Unix(), summary.Version)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeJSON(w, summary)
+}
@@ -2,11 +2,49 @@ package api
 
 import (
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// wsMsgpackProtocol is the WebSocket subprotocol that selects MessagePack frames
+const wsMsgpackProtocol = "msgpack"
+
+// wsSubscriber delivers broadcasts over a WebSocket connection
+type wsSubscriber struct {
+	conn    *websocket.Conn
+	mu      sync.Mutex // gorilla/websocket allows only one concurrent writer
+	msgpack bool       // Send binary MessagePack frames instead of JSON text
+}
+
+func (c *wsSubscriber) send(msg *message) error {
+	if c.msgpack {
+		data, err := msg.envelopeMsgpack()
+		if err != nil {
+			return err
+		}
+		return c.write(websocket.BinaryMessage, data)
+	}
+
+	data, err := msg.envelopeJSON()
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+func (c *wsSubscriber) write(messageType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteMessage(messageType, data)
+}
+
+func (c *wsSubscriber) close() {
+	c.conn.Close()
+}
+
 // handleWebSocket handles WebSocket connections
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.upgrader.Upgrade(w, r, nil)
@@ -15,19 +53,21 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.logger.Info("New WebSocket client connected from %s", r.RemoteAddr)
+	// Clients opt into MessagePack with ?format=msgpack or the "msgpack" subprotocol
+	useMsgpack := r.URL.Query().Get("format") == wsMsgpackProtocol || conn.Subprotocol() == wsMsgpackProtocol
+	format := "json"
+	if useMsgpack {
+		format = wsMsgpackProtocol
+	}
+	s.logger.Info("New WebSocket client connected from %s (%s)", r.RemoteAddr, format)
 
-	// Register client
-	s.mu.Lock()
-	s.clients[conn] = true
-	s.mu.Unlock()
+	client := &wsSubscriber{conn: conn, msgpack: useMsgpack}
 
 	// Send initial status immediately
-	status := s.getCurrentStatus()
-	conn.WriteJSON(map[string]interface{}{
-		"type": "status_update",
-		"data": status,
-	})
+	client.send(newMessage(messageStatusUpdate, "", s.getCurrentStatus()))
+
+	// Register client
+	s.subscribe(client)
 
 	// Setup ping/pong for connection health
 	conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -42,17 +82,15 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Cleanup on disconnect
 	defer func() {
-		s.mu.Lock()
-		delete(s.clients, conn)
-		s.mu.Unlock()
-		conn.Close()
+		s.unsubscribe(client)
+		client.close()
 		s.logger.Info("WebSocket client disconnected from %s", r.RemoteAddr)
 	}()
 
 	// Keep connection alive and handle incoming messages
 	go func() {
 		for range ticker.C {
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			if err := client.write(websocket.PingMessage, nil); err != nil {
 				return
 			}
 		}
@@ -70,15 +108,24 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// BroadcastEvent sends an event notification to all WebSocket clients
+// BroadcastEvent sends an event notification to all WebSocket, SSE and event stream clients
 func (s *Server) BroadcastEvent(eventType, peerName, reason string) {
-	s.Broadcast(map[string]interface{}{
-		"type": "event",
-		"data": map[string]interface{}{
-			"event_type": eventType,
-			"peer_name":  peerName,
-			"reason":     reason,
-			"timestamp":  time.Now(),
-		},
-	})
+	// Events are pushed immediately, and so is the status change that follows them
+	s.statusUrgent.Store(true)
+
+	s.Broadcast(newMessage(messageEvent, eventType, map[string]interface{}{
+		"event_type": eventType,
+		"peer_name":  peerName,
+		"reason":     reason,
+		"timestamp":  time.Now(),
+	}))
+}
+
+// BroadcastHeartbeat tells subscribers that a decision cycle has just run. Unlike the
+// periodic status updates it stops when the monitoring loop does, so a warm standby can
+// tell a working active instance from one whose API merely still answers.
+func (s *Server) BroadcastHeartbeat() {
+	s.Broadcast(newMessage(messageHeartbeat, "", map[string]interface{}{
+		"timestamp": time.Now(),
+	}))
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"lagbuster/database"
+)
+
+// ArchiveConfig exports data that is about to expire to an S3-compatible bucket. The
+// uploader is only compiled in with -tags s3 (see archive_s3.go).
+type ArchiveConfig struct {
+	Enabled         bool   `yaml:"enabled" json:"enabled"`
+	Endpoint        string `yaml:"endpoint" json:"endpoint"`                   // e.g. https://s3.eu-west-1.amazonaws.com or https://minio.example.net:9000
+	Region          string `yaml:"region" json:"region"`                       // Signing region, default us-east-1
+	Bucket          string `yaml:"bucket" json:"bucket"`                       // Bucket to write to
+	Prefix          string `yaml:"prefix" json:"prefix"`                       // Key prefix, e.g. "lagbuster/"
+	AccessKeyID     string `yaml:"access_key_id" json:"access_key_id"`         // Credentials with s3:PutObject on the bucket
+	SecretAccessKey string `yaml:"secret_access_key" json:"secret_access_key"` // Secret for access_key_id
+	VirtualHosted   bool   `yaml:"virtual_hosted" json:"virtual_hosted"`       // bucket.endpoint/key instead of endpoint/bucket/key
+}
+
+// validateArchive checks database.archive
+func validateArchive(config Config) error {
+	archive := config.Database.Archive
+	if !archive.Enabled {
+		return nil
+	}
+	if !archiveSupported {
+		return fmt.Errorf("database.archive requires a build with -tags s3")
+	}
+	if config.Database.Path == "" || config.Database.RetentionDays <= 0 {
+		return fmt.Errorf("database.archive needs database.path and a positive database.retention_days")
+	}
+	if archive.Endpoint == "" || archive.Bucket == "" {
+		return fmt.Errorf("database.archive needs endpoint and bucket")
+	}
+	if !strings.HasPrefix(archive.Endpoint, "http://") && !strings.HasPrefix(archive.Endpoint, "https://") {
+		return fmt.Errorf("database.archive.endpoint must start with http:// or https://")
+	}
+	if archive.AccessKeyID == "" || archive.SecretAccessKey == "" {
+		return fmt.Errorf("database.archive needs access_key_id and secret_access_key")
+	}
+	return nil
+}
+
+// archiveMeasurement and archiveEvent are the JSON lines written to the archive
+type archiveMeasurement struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Peer       string    `json:"peer"`
+	Latency    float64   `json:"latency"`
+	IsHealthy  bool      `json:"is_healthy"`
+	State      string    `json:"state"`
+	TTL        int       `json:"ttl,omitempty"`
+	PacketLoss float64   `json:"packet_loss,omitempty"`
+	Jitter     float64   `json:"jitter,omitempty"`
+}
+
+type archiveEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	EventType string    `json:"event_type"`
+	Peer      *string   `json:"peer,omitempty"`
+	OldHealth *bool     `json:"old_health,omitempty"`
+	NewHealth *bool     `json:"new_health,omitempty"`
+	Reason    string    `json:"reason"`
+	Metadata  *string   `json:"metadata,omitempty"`
+}
+
+// archiveAndCleanup uploads measurements and events older than the retention cutoff as
+// gzipped JSON lines, then deletes them. Nothing is deleted if the upload fails, so the
+// next run retries the same rows.
+func archiveAndCleanup(db *database.DB, config Config) error {
+	cutoff := time.Now().AddDate(0, 0, -config.Database.RetentionDays)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	// Measurements are the bulk of the history, so they go straight from the query into
+	// the gzip stream
+	measurements, err := uploadArchiveRecords(config.Database.Archive, "measurements-"+stamp+".jsonl.gz", func(add func(interface{}) error) error {
+		return db.EachMeasurementBefore(cutoff, func(m database.Measurement) error {
+			return add(archiveMeasurement{Timestamp: m.Timestamp, Peer: m.PeerName, Latency: m.Latency, IsHealthy: m.IsHealthy, State: m.State, TTL: m.TTL, PacketLoss: m.PacketLoss, Jitter: m.Jitter})
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("archiving measurements: %w", err)
+	}
+
+	events, err := db.GetEventsBefore(cutoff)
+	if err != nil {
+		return err
+	}
+	_, err = uploadArchiveRecords(config.Database.Archive, "events-"+stamp+".jsonl.gz", func(add func(interface{}) error) error {
+		for _, e := range events {
+			if err := add(archiveEvent{Timestamp: e.Timestamp, EventType: e.EventType, Peer: e.PeerName, OldHealth: e.OldHealth, NewHealth: e.NewHealth, Reason: e.Reason, Metadata: e.Metadata}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("archiving events: %w", err)
+	}
+
+	logger.Info("Archived %d measurements and %d events older than %s", measurements, len(events), cutoff.Format("2006-01-02"))
+	return db.CleanupBefore(cutoff)
+}
+
+// uploadArchiveRecords gzips the records each passes to add as JSON lines and uploads
+// them under the prefix, returning how many there were. Records are compressed as they
+// come, so only the gzipped dump is held in memory. Empty dumps are skipped.
+func uploadArchiveRecords(config ArchiveConfig, name string, each func(add func(interface{}) error) error) (int, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	count := 0
+	err := each(func(record interface{}) error {
+		count++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	return count, uploadArchive(config, config.Prefix+name, buf.Bytes())
+}
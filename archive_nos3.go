@@ -0,0 +1,12 @@
+//go:build !s3
+
+package main
+
+import "fmt"
+
+// archiveSupported reports whether the S3 uploader is compiled in
+const archiveSupported = false
+
+func uploadArchive(config ArchiveConfig, key string, body []byte) error {
+	return fmt.Errorf("S3 archive support not built in (rebuild with -tags s3)")
+}
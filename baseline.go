@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Ways to handle a peer whose expected_baseline is zero or negative (startup.missing_baseline)
+const (
+	missingBaselineWarn   = "warn"
+	missingBaselineReject = "reject"
+	missingBaselineProbe  = "probe"
+)
+
+// baselineProbeCount is how many pings are used to derive a baseline in probe mode
+const baselineProbeCount = 5
+
+// validateBaselines checks expected_baseline values at config load. Only reject mode fails
+// here; warn and probe are handled by resolveMissingBaselines once logging is set up.
+func validateBaselines(config Config) error {
+	switch config.Startup.MissingBaseline {
+	case "", missingBaselineWarn, missingBaselineProbe:
+		return nil
+	case missingBaselineReject:
+		for _, peer := range config.Peers {
+			if peer.ExpectedBaseline <= 0 {
+				return fmt.Errorf("peer %s: expected_baseline must be positive (got %.2f)", peer.Name, peer.ExpectedBaseline)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("startup.missing_baseline: unknown mode %q (expected warn, reject or probe)", config.Startup.MissingBaseline)
+	}
+}
+
+// resolveMissingBaselines warns about peers without a usable baseline, and in probe mode
+// derives one from the median of a few pings. With a zero baseline every latency counts
+// as degradation, so such a peer would be marked unhealthy as soon as damping allows.
+// It runs at startup, before anything else uses the state.
+func resolveMissingBaselines(state *AppState) {
+	measured := measureMissingBaselines(state, state.Config, state.Config.Peers)
+	for i := range state.Config.Peers {
+		peerConfig := &state.Config.Peers[i]
+		baseline, ok := measured[peerConfig.Name]
+		if !ok {
+			continue
+		}
+		peerConfig.ExpectedBaseline = baseline
+		if peer, exists := state.Peers[peerConfig.Name]; exists {
+			peer.Config.ExpectedBaseline = baseline
+		}
+	}
+}
+
+// measureMissingBaselines logs the warnings for the given peers of config that have no
+// usable baseline and returns, by peer name, the baselines measured for them in probe
+// mode. It doesn't touch the state, so a reload measures added peers before taking
+// state.mu.
+func measureMissingBaselines(state *AppState, config Config, peers []PeerConfig) map[string]float64 {
+	measured := make(map[string]float64)
+	for _, peerConfig := range peers {
+		if peerConfig.ExpectedBaseline > 0 {
+			continue
+		}
+
+		if config.Startup.MissingBaseline != missingBaselineProbe {
+			logger.Warn("Peer %s has no usable expected_baseline (%.2f) - every latency will count as degradation and the peer will be marked UNHEALTHY. Set expected_baseline or startup.missing_baseline: probe",
+				peerConfig.Name, peerConfig.ExpectedBaseline)
+			continue
+		}
+
+		baseline, ok := probeBaseline(state, config, peerConfig)
+		if !ok {
+			logger.Warn("Peer %s has no expected_baseline and did not answer %d baseline probes - leaving it at %.2f, the peer will be marked UNHEALTHY",
+				peerConfig.Name, baselineProbeCount, peerConfig.ExpectedBaseline)
+			continue
+		}
+
+		logger.Warn("Peer %s has no expected_baseline - using %.2fms measured at startup (median of %d probes); set it in the config to make it stable",
+			peerConfig.Name, baseline, baselineProbeCount)
+		measured[peerConfig.Name] = baseline
+	}
+	return measured
+}
+
+// probeBaseline returns the median latency of baselineProbeCount pings to the peer
+func probeBaseline(state *AppState, config Config, peerConfig PeerConfig) (float64, bool) {
+	host := peerConfig.Hostname
+	var samples []float64
+	for i := 0; i < baselineProbeCount; i++ {
+		state.probeLimiter.wait(host, probeMinSpacing(config, peerConfig))
+		if probe, _ := sampleProbe(peerConfig, peerProbeConfig(config, peerConfig), state.probeClassifier); probe.Latency >= 0 {
+			samples = append(samples, probe.Latency)
+		}
+	}
+	if len(samples) == 0 {
+		return 0, false
+	}
+
+	sort.Float64s(samples)
+	return samples[len(samples)/2], true
+}
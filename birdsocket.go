@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultBirdSocketTimeout bounds a control socket exchange when birdc_timeout is unset
+const defaultBirdSocketTimeout = 5 * time.Second
+
+// birdCommand runs a Bird CLI command (e.g. "configure") and returns its output. With
+// bird.bird_socket set it talks to Bird's control socket directly; otherwise it runs
+// birdc_path. Both are bounded by birdc_timeout.
+func birdCommand(config BirdConfig, args ...string) (string, error) {
+	timeout := time.Duration(config.BirdcTimeout) * time.Second
+	if config.BirdSocket != "" {
+		if timeout <= 0 {
+			timeout = defaultBirdSocketTimeout
+		}
+		return birdSocketCommand(config.BirdSocket, strings.Join(args, " "), timeout)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	output, err := exec.CommandContext(ctx, config.BirdcPath, args...).CombinedOutput()
+	return string(output), err
+}
+
+// birdSocketCommand sends one command over Bird's control socket and returns the reply
+// as birdc would print it, without the reply codes. Replies are lines of a four-digit
+// code followed by '-' (more to come) or ' ' (last line), or a space for a
+// continuation of the previous line; codes 8xxx and 9xxx are errors.
+func birdSocketCommand(path, command string, timeout time.Duration) (string, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return "", fmt.Errorf("connecting to bird socket: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return "", fmt.Errorf("setting bird socket deadline: %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	if _, err := readBirdReply(reader); err != nil {
+		return "", fmt.Errorf("reading bird greeting: %w", err)
+	}
+	if _, err := fmt.Fprintf(conn, "%s\n", command); err != nil {
+		return "", fmt.Errorf("sending %q to bird: %w", command, err)
+	}
+	return readBirdReply(reader)
+}
+
+// readBirdReply reads one complete reply from the control socket
+func readBirdReply(reader *bufio.Reader) (string, error) {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return strings.Join(lines, "\n"), err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, " ") {
+			lines = append(lines, line[1:])
+			continue
+		}
+		if len(line) < 5 || (line[4] != '-' && line[4] != ' ') || !isBirdReplyCode(line[:4]) {
+			return strings.Join(lines, "\n"), fmt.Errorf("unexpected reply from bird: %q", line)
+		}
+
+		lines = append(lines, line[5:])
+		if line[4] == ' ' {
+			text := strings.Join(lines, "\n")
+			if line[0] == '8' || line[0] == '9' {
+				return text, fmt.Errorf("bird error %s: %s", line[:4], line[5:])
+			}
+			return text, nil
+		}
+	}
+}
+
+func isBirdReplyCode(code string) bool {
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
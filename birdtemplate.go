@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// birdTemplateData is what a bird.template is rendered with
+type birdTemplateData struct {
+	GeneratedAt time.Time
+	Peers       []birdTemplatePeer // In config order
+}
+
+// birdTemplatePeer describes one peer to a bird.template
+type birdTemplatePeer struct {
+	Name     string
+	Variable string  // bird_variable
+	Priority int     // 1 (in use) or 99 (withdrawn)
+	Value    string  // The priority as the built-in generator writes it (bird.priority_value_map applied)
+	Latency  float64 // Latest sample in ms, -1 when the probe failed
+	Baseline float64 // Baseline in effect now, in ms
+	Healthy  bool
+	BGPUp    bool
+}
+
+// parseBirdTemplate reads and parses the bird.template file. Templates get a "comment"
+// func that makes a string safe to put after a # in the Bird config.
+func parseBirdTemplate(path string) (*template.Template, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading bird.template: %w", err)
+	}
+	tmpl, err := template.New(path).Funcs(template.FuncMap{"comment": birdCommentText}).Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("parsing bird.template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// validateBirdTemplate checks that bird.template parses and renders, so a typo in a
+// field name fails at startup rather than on the first priority change
+func validateBirdTemplate(config BirdConfig) error {
+	if config.Template == "" {
+		return nil
+	}
+	tmpl, err := parseBirdTemplate(config.Template)
+	if err != nil {
+		return err
+	}
+	sample := birdTemplateData{
+		GeneratedAt: time.Now(),
+		Peers:       []birdTemplatePeer{{Name: "example", Variable: "example_priority", Priority: 1, Value: "1", Healthy: true, BGPUp: true}},
+	}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("rendering bird.template: %w", err)
+	}
+	return nil
+}
+
+// birdConfigContent renders the priorities file: through bird.template when one is
+// configured, otherwise with the built-in generator
+func birdConfigContent(state *AppState, priorities map[string]int) (string, error) {
+	if state.birdTemplate == nil {
+		return generateBirdConfig(state, priorities), nil
+	}
+
+	now := time.Now()
+	data := birdTemplateData{GeneratedAt: now}
+	for _, peerConfig := range state.Config.Peers {
+		peer := state.Peers[peerConfig.Name]
+		priority := priorities[peerConfig.Name]
+		baseline, _ := activeBaseline(peer.Config, now)
+		data.Peers = append(data.Peers, birdTemplatePeer{
+			Name:     peerConfig.Name,
+			Variable: peerConfig.BirdVariable,
+			Priority: priority,
+			Value:    birdPriorityValue(state.Config.Bird, priority),
+			Latency:  peer.CurrentLatency,
+			Baseline: baseline,
+			Healthy:  peer.IsHealthy,
+			BGPUp:    peer.BGPSessionUp,
+		})
+	}
+
+	var sb strings.Builder
+	if err := state.birdTemplate.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("rendering bird.template: %w", err)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// birdIdentifierPattern matches a BIRD symbol name usable in a define statement
+var birdIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Keywords BIRD's config parser reserves, which cannot be defined as constants
+var birdReservedWords = map[string]bool{
+	"accept": true, "case": true, "define": true, "else": true, "false": true, "filter": true,
+	"from": true, "function": true, "if": true, "include": true, "print": true, "protocol": true,
+	"reject": true, "return": true, "table": true, "then": true, "true": true,
+}
+
+// validateBirdVariables checks every peer's bird_variable against BIRD's identifier rules,
+// since it is written verbatim into `define <var> = <value>;`. In Bird mode each peer needs
+// one and no two peers may share it.
+func validateBirdVariables(config Config) error {
+	seen := make(map[string]string)
+	for _, peer := range config.Peers {
+		variable := peer.BirdVariable
+		if variable == "" {
+			if !config.ExaBGP.Enabled {
+				return fmt.Errorf("peer %s: bird_variable is required in Bird mode", peer.Name)
+			}
+			continue
+		}
+		if !birdIdentifierPattern.MatchString(variable) {
+			return fmt.Errorf("peer %s: bird_variable %q is not a valid BIRD identifier (letters, digits and _, not starting with a digit)", peer.Name, variable)
+		}
+		if birdReservedWords[strings.ToLower(variable)] {
+			return fmt.Errorf("peer %s: bird_variable %q is a BIRD keyword", peer.Name, variable)
+		}
+		if other, dup := seen[variable]; dup {
+			return fmt.Errorf("peers %s and %s use the same bird_variable %q", other, peer.Name, variable)
+		}
+		seen[variable] = peer.Name
+	}
+	return nil
+}
+
+// birdCommentText makes free text such as a peer name safe to put in a generated
+// comment line: control characters (newlines in particular) become spaces
+func birdCommentText(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return ' '
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LatencyBudget limits how long a peer may spend above a latency within a rolling period
+type LatencyBudget struct {
+	Threshold float64 `yaml:"threshold" json:"threshold"` // Milliseconds; samples above it (or unanswered) count against the budget
+	MaxOver   int     `yaml:"max_over" json:"max_over"`   // Seconds over threshold allowed per period
+	Period    int     `yaml:"period" json:"period"`       // Rolling period in seconds
+}
+
+// budgetCheckInterval is how often latency budgets are evaluated
+const budgetCheckInterval = time.Minute
+
+// validateLatencyBudgets checks every configured peers[].latency_budget
+func validateLatencyBudgets(config Config) error {
+	for _, peer := range config.Peers {
+		budget := peer.LatencyBudget
+		if budget == nil {
+			continue
+		}
+		if budget.Threshold <= 0 || budget.MaxOver <= 0 || budget.Period <= 0 {
+			return fmt.Errorf("peer %s: latency_budget needs positive threshold, max_over and period", peer.Name)
+		}
+		if budget.MaxOver >= budget.Period {
+			return fmt.Errorf("peer %s: latency_budget.max_over must be shorter than period", peer.Name)
+		}
+	}
+	return nil
+}
+
+// hasLatencyBudgets reports whether any peer has a latency budget
+func hasLatencyBudgets(config Config) bool {
+	for _, peer := range config.Peers {
+		if peer.LatencyBudget != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runBudgetEvaluator periodically checks each peer's recorded measurements against its
+// latency budget and fires a budget_exceeded event when the time spent over threshold in
+// the rolling period crosses max_over. It fires again only after the peer is back within
+// budget. Each sample counts for one of the peer's probe intervals.
+func runBudgetEvaluator(ctx context.Context, state *AppState) {
+	ticker := time.NewTicker(budgetCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// A SIGHUP reload can swap the peer list, so work from a copy and skip peers
+		// that are gone by the time their result is in
+		state.mu.Lock()
+		config := state.Config
+		peers := append([]PeerConfig(nil), state.Config.Peers...)
+		state.mu.Unlock()
+
+		for _, peerConfig := range peers {
+			budget := peerConfig.LatencyBudget
+			if budget == nil {
+				continue
+			}
+
+			period := time.Duration(budget.Period) * time.Second
+			measurements, err := state.db.GetMeasurements(peerConfig.Name, time.Now().Add(-period))
+			if err != nil {
+				logger.Warn("Could not check latency budget for %s: %v", peerConfig.Name, err)
+				continue
+			}
+
+			overSamples := 0
+			for _, m := range measurements {
+				if m.Latency < 0 || m.Latency > budget.Threshold {
+					overSamples++
+				}
+			}
+			over := time.Duration(overSamples) * peerMeasurementInterval(config, peerConfig)
+			limit := time.Duration(budget.MaxOver) * time.Second
+
+			state.mu.Lock()
+			peer, ok := state.Peers[peerConfig.Name]
+			if !ok {
+				state.mu.Unlock()
+				continue
+			}
+			if over > limit && !peer.budgetExceeded {
+				peer.budgetExceeded = true
+				name := peerConfig.Name
+				reason := fmt.Sprintf("%s above %.0fms in the last %s (budget %s)", over, budget.Threshold, period, limit)
+				logger.Warn("Peer %s exceeded its latency budget: %s", name, reason)
+				recordEvent(state, "budget_exceeded", &name, nil, nil, reason, nil)
+			} else if over <= limit && peer.budgetExceeded {
+				peer.budgetExceeded = false
+				logger.Info("Peer %s is back within its latency budget (%s over in the last %s)", peerConfig.Name, over, period)
+			}
+			state.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+)
+
+// canaryResult records a confirmation burst sent to a peer before it recovers
+type canaryResult struct {
+	Latencies []float64 `json:"latencies_ms"` // -1 for failed probes
+	Errors    []string  `json:"errors,omitempty"`
+	Passed    bool      `json:"passed"`
+}
+
+// runPendingCanaries sends the canary bursts asked for by the last decision cycle. The
+// probes run without state.mu so the API and the scheduler aren't held up by them; each
+// result is stored on the peer and judged by the next decision cycle.
+func runPendingCanaries(state *AppState) {
+	state.mu.Lock()
+	config := state.Config
+	pending := make(map[*PeerState]PeerConfig)
+	for _, peer := range state.Peers {
+		if peer.canaryPending {
+			peer.canaryPending = false
+			pending[peer] = peer.Config
+		}
+	}
+	state.mu.Unlock()
+
+	for peer, peerConfig := range pending {
+		result := runCanary(state, config, peerConfig)
+
+		state.mu.Lock()
+		// Drop the result if the peer was removed by a config reload meanwhile
+		if current, ok := state.Peers[peerConfig.Name]; ok && current == peer {
+			peer.canary = result
+		}
+		state.mu.Unlock()
+	}
+}
+
+// runCanary sends damping.canary_count back-to-back probes to a peer that is about to
+// recover, so it is re-added on fresh evidence rather than a window that may be stale.
+// Returns nil when canaries are disabled.
+func runCanary(state *AppState, config Config, peerConfig PeerConfig) *canaryResult {
+	count := config.Damping.CanaryCount
+	if count <= 0 {
+		return nil
+	}
+
+	spacing := time.Duration(config.Damping.CanarySpacing) * time.Millisecond
+	result := &canaryResult{Passed: true}
+
+	for i := 0; i < count; i++ {
+		if i > 0 && spacing > 0 {
+			time.Sleep(spacing)
+		}
+
+		state.probeLimiter.wait(peerConfig.Hostname, probeMinSpacing(config, peerConfig))
+		probe, _ := sampleProbe(peerConfig, peerProbeConfig(config, peerConfig), state.probeClassifier)
+		latency, probeErr := probe.Latency, probe.Error
+		result.Latencies = append(result.Latencies, latency)
+		if probeErr != "" {
+			result.Errors = append(result.Errors, probeErr)
+		}
+
+		// Judge as a still-unhealthy peer so the recovery hysteresis applies
+		if healthy, _ := isPeerHealthy(latency, sampleQuality{}, peerConfig, config.Thresholds, false); !healthy {
+			result.Passed = false
+			break
+		}
+	}
+
+	return result
+}
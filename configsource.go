@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Remote config fetches are retried with doubling delays before startup gives up
+const (
+	configFetchAttempts = 5
+	configFetchDelay    = time.Second
+	configFetchTimeout  = 10 * time.Second
+)
+
+// isRemoteConfig reports whether -config names a URL or key-value store rather than a file
+func isRemoteConfig(source string) bool {
+	for _, scheme := range []string{"http://", "https://", "consul://", "etcd://"} {
+		if strings.HasPrefix(source, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// configSourceName is the part of a config source whose extension tells JSON from
+// YAML: the file name, URL path or key
+func configSourceName(source string) string {
+	if !isRemoteConfig(source) {
+		return source
+	}
+	if u, err := url.Parse(source); err == nil {
+		return u.Path
+	}
+	return source
+}
+
+// readConfigSource returns the raw config from a local file (the default) or from:
+//
+//	http(s)://host/path            fetched with GET
+//	consul://host:8500/key/path    Consul KV (token from CONSUL_HTTP_TOKEN)
+//	etcd://host:2379/key/path      etcd v3 via its JSON gateway
+//
+// Remote fetches are retried with backoff so a config server that is briefly
+// unavailable at boot doesn't stop lagbuster from starting.
+func readConfigSource(source string) ([]byte, error) {
+	if !isRemoteConfig(source) {
+		return os.ReadFile(source)
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL: %w", err)
+	}
+
+	delay := configFetchDelay
+	for attempt := 1; ; attempt++ {
+		data, err := fetchConfig(u)
+		if err == nil {
+			return data, nil
+		}
+		if attempt == configFetchAttempts {
+			return nil, fmt.Errorf("fetching config from %s (%d attempts): %w", u.Redacted(), attempt, err)
+		}
+		log.Printf("[WARN] Fetching config from %s failed (attempt %d of %d), retrying in %s: %v",
+			u.Redacted(), attempt, configFetchAttempts, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// fetchConfig makes one attempt at reading a remote config
+func fetchConfig(u *url.URL) ([]byte, error) {
+	client := &http.Client{Timeout: configFetchTimeout}
+
+	switch u.Scheme {
+	case "consul":
+		kv := url.URL{Scheme: "http", Host: u.Host, Path: "/v1/kv/" + strings.TrimPrefix(u.Path, "/"), RawQuery: "raw"}
+		req, err := http.NewRequest(http.MethodGet, kv.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+			req.Header.Set("X-Consul-Token", token)
+		}
+		return readConfigResponse(client.Do(req))
+
+	case "etcd":
+		key := strings.TrimPrefix(u.Path, "/")
+		body, _ := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(key))})
+		gateway := url.URL{Scheme: "http", Host: u.Host, Path: "/v3/kv/range"}
+		data, err := readConfigResponse(client.Post(gateway.String(), "application/json", bytes.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Kvs []struct {
+				Value string `json:"value"`
+			} `json:"kvs"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("parsing etcd response: %w", err)
+		}
+		if len(result.Kvs) == 0 {
+			return nil, fmt.Errorf("etcd key %q not found", key)
+		}
+		return base64.StdEncoding.DecodeString(result.Kvs[0].Value)
+
+	default:
+		return readConfigResponse(client.Get(u.String()))
+	}
+}
+
+// readConfigResponse returns the body of a successful response
+func readConfigResponse(resp *http.Response, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+}
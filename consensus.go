@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Ways of combining a peer's probes (peers[].health_consensus)
+const (
+	consensusAll      = "all"      // A sample is bad only when every probe's is (default)
+	consensusAny      = "any"      // A sample is bad when any probe's is
+	consensusMajority = "majority" // A sample is bad when most probes' are
+)
+
+// probeResult is one echo to a peer: the latency (-1 when it failed), the reply TTL and
+// tcp port results, and the reason it failed
+type probeResult struct {
+	Method  string          `json:"method"`
+	Latency float64         `json:"latency_ms"`
+	Error   string          `json:"error,omitempty"`
+	ttl     int             // TTL of the reply, 0 if unknown
+	ports   []tcpPortResult // Each port's handshake, for tcp probes
+}
+
+// validateConsensus checks peers[].health_probes and health_consensus. Each probe method
+// needs the same settings it would need as the peer's probe_method.
+func validateConsensus(config Config) error {
+	for _, peer := range config.Peers {
+		if len(peer.HealthProbes) == 0 {
+			if peer.HealthConsensus != "" {
+				return fmt.Errorf("peer %s: health_consensus needs health_probes", peer.Name)
+			}
+			continue
+		}
+		if len(peer.HealthProbes) < 2 {
+			return fmt.Errorf("peer %s: health_probes needs at least two probe methods", peer.Name)
+		}
+		switch peer.HealthConsensus {
+		case "", consensusAll, consensusAny, consensusMajority:
+		default:
+			return fmt.Errorf("peer %s: health_consensus must be all, any or majority, got %q", peer.Name, peer.HealthConsensus)
+		}
+		for _, method := range peer.HealthProbes {
+			if method == "" {
+				return fmt.Errorf("peer %s: health_probes has an empty probe method", peer.Name)
+			}
+			probed := peer
+			probed.ProbeMethod = method
+			probed.HealthProbes = nil
+			single := config
+			single.Peers = []PeerConfig{probed}
+			if err := validateProbeMethods(single); err != nil {
+				return fmt.Errorf("health_probes: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// consensusMode returns a peer's health_consensus, all when unset
+func consensusMode(peer PeerConfig) string {
+	if peer.HealthConsensus == "" {
+		return consensusAll
+	}
+	return peer.HealthConsensus
+}
+
+// sampleProbe sends one echo to a peer with its probe settings, or with each of its
+// health_probes at once when it has them (see consensusProbe). It also returns every
+// probe's own result, nil for a single probe.
+func sampleProbe(peer PeerConfig, probe ProbeConfig, classifier *probeClassifier) (probeResult, []probeResult) {
+	if len(peer.HealthProbes) == 0 {
+		latency, ttl, ports, probeErr := probeHost(peer.Hostname, probe, classifier)
+		return probeResult{Method: probe.Method, Latency: latency, Error: probeErr, ttl: ttl, ports: ports}, nil
+	}
+	return consensusProbe(peer.Hostname, probe, peer.HealthProbes, consensusMode(peer), classifier)
+}
+
+// consensusProbe probes host with each of methods at once and returns the result the
+// consensus mode settles on: the k-th fastest, with failures counted as slowest, where
+// k is 1 for all, every probe for any and a majority for majority. The health rules
+// only get worse as latency rises, so that sample fails them exactly when the mode says
+// it should: with all, only when even the fastest probe does.
+func consensusProbe(host string, probe ProbeConfig, methods []string, mode string, classifier *probeClassifier) (probeResult, []probeResult) {
+	results := make([]probeResult, len(methods))
+	var wg sync.WaitGroup
+	for i, method := range methods {
+		wg.Add(1)
+		go func(i int, method string) {
+			defer wg.Done()
+			sub := probe
+			sub.Method = method
+			latency, ttl, ports, probeErr := probeHost(host, sub, classifier)
+			results[i] = probeResult{Method: method, Latency: latency, Error: probeErr, ttl: ttl, ports: ports}
+		}(i, method)
+	}
+	wg.Wait()
+
+	ranked := append([]probeResult(nil), results...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if (ranked[i].Latency < 0) != (ranked[j].Latency < 0) {
+			return ranked[j].Latency < 0
+		}
+		return ranked[i].Latency < ranked[j].Latency
+	})
+
+	k := 1
+	switch mode {
+	case consensusAny:
+		k = len(ranked)
+	case consensusMajority:
+		k = len(ranked)/2 + 1
+	}
+	chosen := ranked[k-1]
+	if chosen.Latency >= 0 {
+		return chosen, results
+	}
+
+	var failed []string
+	for _, result := range results {
+		if result.Latency < 0 {
+			failed = append(failed, fmt.Sprintf("%s: %s", result.Method, result.Error))
+		}
+	}
+	chosen.Method = strings.Join(methods, "+")
+	chosen.Error = fmt.Sprintf("%d of %d probes failed (%s)", len(failed), len(results), strings.Join(failed, ", "))
+	return chosen, results
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	_ "embed"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -15,16 +17,32 @@ var schemaSQL string
 // DB wraps the SQLite database connection
 type DB struct {
 	conn *sql.DB
+
+	// Recovery describes what Open had to do to a corrupt database file
+	// (repair or recreate). Empty when the database was healthy.
+	Recovery string
 }
 
+// Operational states recorded with each measurement
+const (
+	StateNormal  = "normal"
+	StateFrozen  = "frozen"  // Routing decisions frozen (kill-switch)
+	StateDryRun  = "dry-run" // Decisions logged but never applied
+	StateStandby = "standby" // Warm standby following another instance
+)
+
 // Measurement represents a peer latency measurement
 type Measurement struct {
-	ID        int64
-	Timestamp time.Time
-	PeerName  string
-	Latency   float64
-	IsHealthy bool
-	IsPrimary bool
+	ID         int64
+	Timestamp  time.Time
+	PeerName   string
+	Latency    float64
+	IsHealthy  bool
+	IsPrimary  bool
+	State      string  // Operational state when the measurement was taken
+	TTL        int     // Reply TTL, 0 when unknown
+	PacketLoss float64 // Fraction of the measurement's echoes lost
+	Jitter     float64 // Standard deviation of the measurement's echo RTTs in ms
 }
 
 // Event represents a system event
@@ -52,13 +70,25 @@ type Notification struct {
 	Error       *string
 }
 
-// Open opens or creates the database at the given path
-func Open(dbPath string) (*DB, error) {
+// Open opens or creates the database at the given path.
+// The file is integrity-checked first; a corrupt database is repaired in place if possible
+// (REINDEX, then dump-and-reload via VACUUM INTO). If it cannot be repaired and
+// recreateOnCorruption is set, the corrupt file is moved aside and a fresh database is
+// created instead of failing on every start.
+func Open(dbPath string, recreateOnCorruption bool) (*DB, error) {
 	conn, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
 
+	var recovery string
+	if checkErr := checkIntegrity(conn); checkErr != nil {
+		conn, recovery, err = recoverDatabase(conn, dbPath, checkErr, recreateOnCorruption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Enable WAL mode for better concurrency
 	if _, err := conn.Exec("PRAGMA journal_mode=WAL"); err != nil {
 		conn.Close()
@@ -71,7 +101,149 @@ func Open(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("creating schema: %w", err)
 	}
 
-	return &DB{conn: conn}, nil
+	// Columns added after the original schema
+	if err := ensureColumn(conn, "measurements", "state", "TEXT NOT NULL DEFAULT 'normal'"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ensureColumn(conn, "measurements", "ttl", "INTEGER"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ensureColumn(conn, "measurements", "packet_loss", "REAL"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := ensureColumn(conn, "measurements", "jitter", "REAL"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{conn: conn, Recovery: recovery}, nil
+}
+
+// checkIntegrity runs PRAGMA integrity_check and returns an error describing any problems
+func checkIntegrity(conn *sql.DB) error {
+	rows, err := conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// recoverDatabase tries to repair a corrupt database, falling back to recreating it.
+// It returns the connection to use and a description of what was done.
+func recoverDatabase(conn *sql.DB, dbPath string, checkErr error, recreate bool) (*sql.DB, string, error) {
+	// Index corruption is the common case and REINDEX fixes it in place
+	if _, err := conn.Exec("REINDEX"); err == nil && checkIntegrity(conn) == nil {
+		return conn, fmt.Sprintf("repaired corrupt database by rebuilding indexes (%v)", checkErr), nil
+	}
+
+	// Dump-and-reload whatever SQLite can still read into a new file
+	recoveredPath := dbPath + ".recovered"
+	os.Remove(recoveredPath)
+	if _, err := conn.Exec("VACUUM INTO ?", recoveredPath); err == nil {
+		recovered, err := sql.Open("sqlite3", recoveredPath)
+		if err == nil && checkIntegrity(recovered) == nil {
+			recovered.Close()
+			conn.Close()
+			aside, err := moveAside(dbPath)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := os.Rename(recoveredPath, dbPath); err != nil {
+				return nil, "", fmt.Errorf("replacing corrupt database: %w", err)
+			}
+			conn, err = sql.Open("sqlite3", dbPath)
+			if err != nil {
+				return nil, "", fmt.Errorf("opening recovered database: %w", err)
+			}
+			return conn, fmt.Sprintf("recovered corrupt database by dump-and-reload, original kept at %s (%v)", aside, checkErr), nil
+		}
+		if recovered != nil {
+			recovered.Close()
+		}
+	}
+	os.Remove(recoveredPath)
+	conn.Close()
+
+	if !recreate {
+		return nil, "", fmt.Errorf("database %s is corrupt and could not be repaired (set database.recreate_on_corruption to start fresh): %w", dbPath, checkErr)
+	}
+
+	aside, err := moveAside(dbPath)
+	if err != nil {
+		return nil, "", err
+	}
+	conn, err = sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("opening fresh database: %w", err)
+	}
+	return conn, fmt.Sprintf("database was corrupt and unrecoverable, started fresh; corrupt file moved to %s (%v)", aside, checkErr), nil
+}
+
+// moveAside renames a database file (and its WAL/SHM companions) out of the way
+func moveAside(dbPath string) (string, error) {
+	aside := fmt.Sprintf("%s.corrupt-%d", dbPath, time.Now().Unix())
+	if err := os.Rename(dbPath, aside); err != nil {
+		return "", fmt.Errorf("moving corrupt database aside: %w", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Rename(dbPath+suffix, aside+suffix)
+	}
+	return aside, nil
+}
+
+// ensureColumn adds a column to a table created by an older schema
+func ensureColumn(conn *sql.DB, table, column, definition string) error {
+	rows, err := conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid          int
+			name, ctype  string
+			notNull, pk  int
+			defaultValue sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultValue, &pk); err != nil {
+			return fmt.Errorf("inspecting %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("inspecting %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("adding %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // Close closes the database connection
@@ -79,11 +251,16 @@ func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-// RecordMeasurement records a peer latency measurement
-func (db *DB) RecordMeasurement(peerName string, latency float64, isHealthy, isPrimary bool) error {
-	query := `INSERT INTO measurements (peer_name, latency, is_healthy, is_primary)
-	          VALUES (?, ?, ?, ?)`
-	_, err := db.conn.Exec(query, peerName, latency, isHealthy, isPrimary)
+// RecordMeasurement records a peer latency measurement, its packet loss and jitter along
+// with the operational state (StateNormal, StateFrozen, StateDryRun) it was taken in
+func (db *DB) RecordMeasurement(peerName string, latency float64, ttl int, packetLoss, jitter float64, isHealthy, isPrimary bool, state string) error {
+	query := `INSERT INTO measurements (peer_name, latency, is_healthy, is_primary, state, ttl, packet_loss, jitter)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	var replyTTL sql.NullInt64
+	if ttl > 0 {
+		replyTTL = sql.NullInt64{Int64: int64(ttl), Valid: true}
+	}
+	_, err := db.conn.Exec(query, peerName, latency, isHealthy, isPrimary, state, replyTTL, packetLoss, jitter)
 	if err != nil {
 		return fmt.Errorf("recording measurement: %w", err)
 	}
@@ -125,27 +302,156 @@ func (db *DB) RecordNotification(channelType string, eventID *int64, status, mes
 
 // GetMeasurements retrieves measurements for a peer within a time range
 func (db *DB) GetMeasurements(peerName string, since time.Time) ([]Measurement, error) {
-	query := `SELECT id, timestamp, peer_name, latency, is_healthy, is_primary
+	var measurements []Measurement
+	err := db.EachMeasurement(peerName, since, func(m Measurement) error {
+		measurements = append(measurements, m)
+		return nil
+	})
+	return measurements, err
+}
+
+// EachMeasurement calls fn with a peer's measurements since a time, oldest first, one
+// row at a time so long ranges are never held in memory. An error from fn stops the
+// iteration and is returned.
+func (db *DB) EachMeasurement(peerName string, since time.Time, fn func(Measurement) error) error {
+	query := `SELECT id, timestamp, peer_name, latency, is_healthy, is_primary, state, ttl, packet_loss, jitter
 	          FROM measurements
 	          WHERE peer_name = ? AND timestamp >= ?
 	          ORDER BY timestamp ASC`
 
 	rows, err := db.conn.Query(query, peerName, since)
 	if err != nil {
-		return nil, fmt.Errorf("querying measurements: %w", err)
+		return fmt.Errorf("querying measurements: %w", err)
 	}
 	defer rows.Close()
 
-	var measurements []Measurement
 	for rows.Next() {
 		var m Measurement
-		if err := rows.Scan(&m.ID, &m.Timestamp, &m.PeerName, &m.Latency, &m.IsHealthy, &m.IsPrimary); err != nil {
-			return nil, fmt.Errorf("scanning measurement: %w", err)
+		var ttl sql.NullInt64
+		var packetLoss, jitter sql.NullFloat64
+		if err := rows.Scan(&m.ID, &m.Timestamp, &m.PeerName, &m.Latency, &m.IsHealthy, &m.IsPrimary, &m.State, &ttl, &packetLoss, &jitter); err != nil {
+			return fmt.Errorf("scanning measurement: %w", err)
+		}
+		m.TTL = int(ttl.Int64)
+		m.PacketLoss = packetLoss.Float64
+		m.Jitter = jitter.Float64
+		if err := fn(m); err != nil {
+			return err
 		}
-		measurements = append(measurements, m)
 	}
 
-	return measurements, rows.Err()
+	return rows.Err()
+}
+
+// MeasurementBucket aggregates a peer's measurements over one time bucket
+type MeasurementBucket struct {
+	Start   time.Time
+	Samples int
+	Min     *float64 // Latency aggregates over the answered probes, nil when none was answered
+	Avg     *float64
+	Max     *float64
+	Loss    float64 // Mean packet loss, an unanswered probe counting as total loss
+	Healthy float64 // Fraction of the samples taken while the peer was healthy
+}
+
+// GetMeasurementsAggregated buckets a peer's measurements since a time into intervals of
+// bucket (rounded down to whole seconds, at least one) and aggregates each in SQL, oldest
+// first. Buckets without measurements are left out. A non-empty state keeps only the
+// measurements taken in that operational state.
+func (db *DB) GetMeasurementsAggregated(peerName string, since time.Time, bucket time.Duration, state string) ([]MeasurementBucket, error) {
+	seconds := max(int64(bucket/time.Second), 1)
+	query := `SELECT (CAST(strftime('%s', timestamp) AS INTEGER) / ?) * ? AS bucket_start,
+	                 COUNT(*),
+	                 MIN(CASE WHEN latency >= 0 THEN latency END),
+	                 AVG(CASE WHEN latency >= 0 THEN latency END),
+	                 MAX(CASE WHEN latency >= 0 THEN latency END),
+	                 AVG(CASE WHEN latency < 0 THEN 1.0 ELSE COALESCE(packet_loss, 0) END),
+	                 AVG(CASE WHEN is_healthy THEN 1.0 ELSE 0 END)
+	          FROM measurements
+	          WHERE peer_name = ? AND timestamp >= ? AND (? = '' OR state = ?)
+	          GROUP BY bucket_start
+	          ORDER BY bucket_start ASC`
+
+	rows, err := db.conn.Query(query, seconds, seconds, peerName, since, state, state)
+	if err != nil {
+		return nil, fmt.Errorf("querying aggregated measurements: %w", err)
+	}
+	defer rows.Close()
+
+	var buckets []MeasurementBucket
+	for rows.Next() {
+		var b MeasurementBucket
+		var start int64
+		var minLatency, avgLatency, maxLatency sql.NullFloat64
+		if err := rows.Scan(&start, &b.Samples, &minLatency, &avgLatency, &maxLatency, &b.Loss, &b.Healthy); err != nil {
+			return nil, fmt.Errorf("scanning aggregated measurement: %w", err)
+		}
+		b.Start = time.Unix(start, 0).UTC()
+		if avgLatency.Valid {
+			b.Min, b.Avg, b.Max = &minLatency.Float64, &avgLatency.Float64, &maxLatency.Float64
+		}
+		buckets = append(buckets, b)
+	}
+
+	return buckets, rows.Err()
+}
+
+// EachMeasurementBefore calls fn with every peer's measurements older than cutoff, oldest
+// first, one row at a time like EachMeasurement, so a long history awaiting archival is
+// never held in memory. An error from fn stops the iteration and is returned.
+func (db *DB) EachMeasurementBefore(cutoff time.Time, fn func(Measurement) error) error {
+	query := `SELECT id, timestamp, peer_name, latency, is_healthy, is_primary, state, ttl, packet_loss, jitter
+	          FROM measurements
+	          WHERE timestamp < ?
+	          ORDER BY timestamp ASC`
+
+	rows, err := db.conn.Query(query, cutoff)
+	if err != nil {
+		return fmt.Errorf("querying measurements: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Measurement
+		var ttl sql.NullInt64
+		var packetLoss, jitter sql.NullFloat64
+		if err := rows.Scan(&m.ID, &m.Timestamp, &m.PeerName, &m.Latency, &m.IsHealthy, &m.IsPrimary, &m.State, &ttl, &packetLoss, &jitter); err != nil {
+			return fmt.Errorf("scanning measurement: %w", err)
+		}
+		m.TTL = int(ttl.Int64)
+		m.PacketLoss = packetLoss.Float64
+		m.Jitter = jitter.Float64
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// LastMeasurementTime returns the timestamp of the most recent measurement, if any
+func (db *DB) LastMeasurementTime() (time.Time, bool, error) {
+	var ts time.Time
+	err := db.conn.QueryRow(`SELECT timestamp FROM measurements ORDER BY timestamp DESC LIMIT 1`).Scan(&ts)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("querying last measurement: %w", err)
+	}
+	return ts, true, nil
+}
+
+// CountEvents counts a peer's events of one type since the given time
+func (db *DB) CountEvents(peerName, eventType string, since time.Time) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM events
+	                         WHERE event_type = ? AND peer_name = ? AND timestamp >= ?`,
+		eventType, peerName, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting events: %w", err)
+	}
+	return count, nil
 }
 
 // GetEvents retrieves events within a time range
@@ -191,10 +497,110 @@ func (db *DB) GetEvents(since time.Time, eventTypes []string) ([]Event, error) {
 	return events, rows.Err()
 }
 
+// GetNotifications retrieves recorded notification attempts since a time, newest first.
+// A non-empty channelType or status keeps only those attempts.
+func (db *DB) GetNotifications(since time.Time, channelType, status string) ([]Notification, error) {
+	query := `SELECT id, timestamp, channel_type, event_id, status, message, error
+	          FROM notifications
+	          WHERE timestamp >= ?`
+	args := []interface{}{since}
+
+	if channelType != "" {
+		query += ` AND channel_type = ?`
+		args = append(args, channelType)
+	}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+
+	query += ` ORDER BY timestamp DESC, id DESC`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		var message sql.NullString
+		if err := rows.Scan(&n.ID, &n.Timestamp, &n.ChannelType, &n.EventID, &n.Status, &message, &n.Error); err != nil {
+			return nil, fmt.Errorf("scanning notification: %w", err)
+		}
+		n.Message = message.String
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+// GetNotificationRateState returns when each notification rate-limit key last sent
+func (db *DB) GetNotificationRateState() (map[string]time.Time, error) {
+	rows, err := db.conn.Query(`SELECT rate_key, last_sent FROM notification_rate_state`)
+	if err != nil {
+		return nil, fmt.Errorf("querying notification rate state: %w", err)
+	}
+	defer rows.Close()
+
+	state := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var lastSent time.Time
+		if err := rows.Scan(&key, &lastSent); err != nil {
+			return nil, fmt.Errorf("scanning notification rate state: %w", err)
+		}
+		state[key] = lastSent
+	}
+	return state, rows.Err()
+}
+
+// SetNotificationRateState records when a notification rate-limit key last sent
+func (db *DB) SetNotificationRateState(key string, lastSent time.Time) error {
+	_, err := db.conn.Exec(`INSERT INTO notification_rate_state (rate_key, last_sent) VALUES (?, ?)
+	                        ON CONFLICT(rate_key) DO UPDATE SET last_sent = excluded.last_sent`, key, lastSent)
+	if err != nil {
+		return fmt.Errorf("recording notification rate state: %w", err)
+	}
+	return nil
+}
+
+// GetEventsBefore retrieves all events older than cutoff, oldest first
+func (db *DB) GetEventsBefore(cutoff time.Time) ([]Event, error) {
+	query := `SELECT id, timestamp, event_type, peer_name, old_primary, new_primary,
+	                 old_health, new_health, reason, metadata
+	          FROM events
+	          WHERE timestamp < ?
+	          ORDER BY timestamp ASC`
+
+	rows, err := db.conn.Query(query, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Timestamp, &e.EventType, &e.PeerName,
+			&e.OldPrimary, &e.NewPrimary, &e.OldHealth, &e.NewHealth,
+			&e.Reason, &e.Metadata); err != nil {
+			return nil, fmt.Errorf("scanning event: %w", err)
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
 // CleanupOldData removes data older than the retention period
 func (db *DB) CleanupOldData(retentionDays int) error {
-	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return db.CleanupBefore(time.Now().AddDate(0, 0, -retentionDays))
+}
 
+// CleanupBefore removes measurements and events older than cutoff
+func (db *DB) CleanupBefore(cutoff time.Time) error {
 	// Delete old measurements
 	if _, err := db.conn.Exec("DELETE FROM measurements WHERE timestamp < ?", cutoff); err != nil {
 		return fmt.Errorf("cleaning measurements: %w", err)
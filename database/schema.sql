@@ -7,7 +7,11 @@ CREATE TABLE IF NOT EXISTS measurements (
     peer_name TEXT NOT NULL,
     latency REAL NOT NULL,  -- -1 for timeout/unreachable
     is_healthy BOOLEAN NOT NULL,
-    is_primary BOOLEAN NOT NULL
+    is_primary BOOLEAN NOT NULL,
+    state TEXT NOT NULL DEFAULT 'normal',  -- Operational state when measured: 'normal', 'frozen', 'dry-run', 'standby'
+    ttl INTEGER,  -- TTL of the reply, NULL when unknown or unanswered
+    packet_loss REAL,  -- Fraction of the measurement's echoes lost, NULL in rows from before loss was recorded
+    jitter REAL  -- Standard deviation of the measurement's echo RTTs (ms), NULL in rows from before jitter was recorded
 );
 
 CREATE INDEX IF NOT EXISTS idx_measurements_timestamp ON measurements(timestamp);
@@ -34,7 +38,7 @@ CREATE INDEX IF NOT EXISTS idx_events_type ON events(event_type, timestamp);
 CREATE TABLE IF NOT EXISTS notifications (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    channel_type TEXT NOT NULL,  -- 'email', 'slack', 'telegram'
+    channel_type TEXT NOT NULL,  -- 'email', 'slack', 'telegram', 'webhook', 'pagerduty'
     event_id INTEGER,  -- Reference to event that triggered it
     status TEXT NOT NULL,  -- 'sent', 'failed', 'rate_limited'
     message TEXT,
@@ -51,3 +55,10 @@ CREATE TABLE IF NOT EXISTS notification_channels (
     events_json TEXT NOT NULL,  -- JSON array of event types to notify on
     updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
+
+-- Last time each notification rate-limit key was sent ("channel:event_type"), so rate
+-- limits survive restarts (notifications.persist_rate_limits)
+CREATE TABLE IF NOT EXISTS notification_rate_state (
+    rate_key TEXT PRIMARY KEY,
+    last_sent DATETIME NOT NULL
+);
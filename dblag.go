@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultDBWriteLagThreshold applies when database.write_lag_threshold is unset
+const defaultDBWriteLagThreshold = time.Second
+
+// trackDBWrite records how long a measurement write took and fires a db_lag event when
+// writes become slow or start failing, and db_lag_cleared once they are healthy again
+func trackDBWrite(state *AppState, elapsed time.Duration, err error) {
+	state.dbWriteLag = elapsed
+
+	threshold := time.Duration(state.Config.Database.WriteLagThreshold) * time.Millisecond
+	if threshold <= 0 {
+		threshold = defaultDBWriteLagThreshold
+	}
+
+	var problem string
+	if err != nil {
+		problem = fmt.Sprintf("measurement write failed: %v", err)
+	} else if elapsed > threshold {
+		problem = fmt.Sprintf("measurement write took %dms (threshold %dms)", elapsed.Milliseconds(), threshold.Milliseconds())
+	}
+
+	if problem != "" && !state.dbLagging {
+		state.dbLagging = true
+		logger.Warn("Database falling behind: %s", problem)
+		recordEvent(state, "db_lag", nil, nil, nil, problem, nil)
+	} else if problem == "" && state.dbLagging {
+		state.dbLagging = false
+		reason := fmt.Sprintf("measurement writes back to %dms", elapsed.Milliseconds())
+		logger.Info("Database caught up: %s", reason)
+		recordEvent(state, "db_lag_cleared", nil, nil, nil, reason, nil)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dryRunMaxChanges bounds how many individual would-be changes the report keeps
+const dryRunMaxChanges = 1000
+
+// dryRunReport accumulates the routing changes a dry run would have made
+type dryRunReport struct {
+	mu          sync.Mutex
+	started     time.Time
+	cycles      int
+	applies     int
+	changes     []dryRunChange
+	dropped     int
+	peers       map[string]*dryRunPeerSummary
+	lastReasons map[string]string // Latest event reason per peer in the current cycle
+}
+
+type dryRunChange struct {
+	Time         time.Time `json:"time"`
+	Peer         string    `json:"peer"`
+	FromPriority int       `json:"from_priority"`
+	ToPriority   int       `json:"to_priority"`
+	Reason       string    `json:"reason"`
+}
+
+type dryRunPeerSummary struct {
+	Removals     int    `json:"removals"`     // Would have been taken out of ECMP
+	Restorations int    `json:"restorations"` // Would have been put back into ECMP
+	LastReason   string `json:"last_reason,omitempty"`
+}
+
+// DryRunReportSnapshot is the JSON form of the report served by /api/dryrun/report
+type DryRunReportSnapshot struct {
+	Started        time.Time                     `json:"started"`
+	DurationSecs   int64                         `json:"duration_seconds"`
+	Cycles         int                           `json:"cycles"`
+	WouldApply     int                           `json:"would_apply"` // Cycles whose priorities differed from the previous ones
+	Changes        []dryRunChange                `json:"changes"`
+	DroppedChanges int                           `json:"dropped_changes,omitempty"`
+	Peers          map[string]*dryRunPeerSummary `json:"peers"`
+}
+
+func newDryRunReport() *dryRunReport {
+	return &dryRunReport{
+		started:     time.Now(),
+		peers:       make(map[string]*dryRunPeerSummary),
+		lastReasons: make(map[string]string),
+	}
+}
+
+// note remembers why a peer changed, for the priority change that follows in this cycle
+func (r *dryRunReport) note(peerName *string, reason string) {
+	if peerName == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastReasons[*peerName] = reason
+}
+
+// record compares the priorities a cycle would apply with the previous ones and
+// returns how many peers would change
+func (r *dryRunReport) record(state *AppState, previous, priorities map[string]int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cycles++
+	changed := 0
+	now := time.Now()
+
+	for _, peerConfig := range state.Config.Peers {
+		name := peerConfig.Name
+		from, known := previous[name]
+		to := priorities[name]
+		if !known || from == to {
+			continue
+		}
+		changed++
+
+		reason := r.lastReasons[name]
+		if reason == "" {
+			if peer := state.Peers[name]; peer != nil && !peer.BGPSessionUp {
+				reason = fmt.Sprintf("BGP session %s", peer.BGPSessionState)
+			} else {
+				reason = "priority changed"
+			}
+		}
+
+		summary := r.peers[name]
+		if summary == nil {
+			summary = &dryRunPeerSummary{}
+			r.peers[name] = summary
+		}
+		if to > from {
+			summary.Removals++
+		} else {
+			summary.Restorations++
+		}
+		summary.LastReason = reason
+
+		if len(r.changes) >= dryRunMaxChanges {
+			r.changes = r.changes[1:]
+			r.dropped++
+		}
+		r.changes = append(r.changes, dryRunChange{Time: now, Peer: name, FromPriority: from, ToPriority: to, Reason: reason})
+	}
+
+	if changed > 0 {
+		r.applies++
+	}
+	r.lastReasons = make(map[string]string)
+
+	return changed
+}
+
+// snapshot returns a copy of the report that is safe to serialize
+func (r *dryRunReport) snapshot() DryRunReportSnapshot {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	peers := make(map[string]*dryRunPeerSummary, len(r.peers))
+	for name, summary := range r.peers {
+		copied := *summary
+		peers[name] = &copied
+	}
+
+	return DryRunReportSnapshot{
+		Started:        r.started,
+		DurationSecs:   int64(time.Since(r.started).Seconds()),
+		Cycles:         r.cycles,
+		WouldApply:     r.applies,
+		Changes:        append([]dryRunChange{}, r.changes...),
+		DroppedChanges: r.dropped,
+		Peers:          peers,
+	}
+}
+
+// logSummary prints the report, e.g. when a dry run is stopped
+func (r *dryRunReport) logSummary() {
+	report := r.snapshot()
+
+	logger.Info("DRY-RUN report: %d cycles over %s, %d would have applied a routing change",
+		report.Cycles, time.Duration(report.DurationSecs)*time.Second, report.WouldApply)
+
+	names := make([]string, 0, len(report.Peers))
+	for name := range report.Peers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		summary := report.Peers[name]
+		logger.Info("DRY-RUN report: peer %s would have been removed %d times and restored %d times (last: %s)",
+			name, summary.Removals, summary.Restorations, summary.LastReason)
+	}
+	if len(names) == 0 {
+		logger.Info("DRY-RUN report: no routing changes would have been made")
+	}
+}
+
+// logBirdConfigDiff logs, as a unified diff, the define lines of the priorities file that
+// the apply would change. Nothing is written and birdc is not run.
+func logBirdConfigDiff(state *AppState, priorities map[string]int) {
+	path := state.Config.Bird.PrioritiesFile
+	current, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("DRY-RUN: Could not read %s to diff against: %v", path, err)
+		return
+	}
+
+	next, err := birdConfigContent(state, priorities)
+	if err != nil {
+		logger.Warn("DRY-RUN: %v", err)
+		return
+	}
+	diff := birdDefineDiff(string(current), next)
+	if len(diff) == 0 {
+		logger.Info("DRY-RUN: %s already has these priorities", path)
+		return
+	}
+	logger.Info("DRY-RUN: Would change %s:\n--- %s\n+++ %s (would write)\n%s",
+		path, path, path, strings.Join(diff, "\n"))
+}
+
+// birdDefineDiff compares the define lines of two priorities files by variable and
+// returns the changed ones in unified diff form, in the order of the new file
+func birdDefineDiff(current, next string) []string {
+	oldLines, oldOrder := birdDefines(current)
+	newLines, newOrder := birdDefines(next)
+
+	var diff []string
+	for _, name := range newOrder {
+		if old, ok := oldLines[name]; !ok {
+			diff = append(diff, "+"+newLines[name])
+		} else if old != newLines[name] {
+			diff = append(diff, "-"+old, "+"+newLines[name])
+		}
+	}
+	for _, name := range oldOrder {
+		if _, ok := newLines[name]; !ok {
+			diff = append(diff, "-"+oldLines[name])
+		}
+	}
+	return diff
+}
+
+// birdDefines indexes a priorities file's define lines by variable name
+func birdDefines(content string) (map[string]string, []string) {
+	lines := make(map[string]string)
+	var order []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "define ") {
+			continue
+		}
+		name := strings.TrimSpace(strings.SplitN(strings.TrimPrefix(line, "define "), "=", 2)[0])
+		if _, seen := lines[name]; !seen {
+			order = append(order, name)
+		}
+		lines[name] = line
+	}
+	return lines, order
+}
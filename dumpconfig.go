@@ -0,0 +1,135 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The documented example config supplies the comments for -dump-config output
+//
+//go:embed config.example.yaml
+var exampleConfig []byte
+
+// redactedValue replaces secrets in dumped configs
+const redactedValue = "<redacted>"
+
+// Keys whose values are credentials, matched as substrings of the YAML key
+var secretConfigKeys = []string{"password", "token", "secret", "webhook_url", "api_key", "routing_key"}
+
+// Config paths whose values are credentials under keys too generic to match by name:
+// the generic webhook's URL and every header it sends
+var secretConfigPaths = []string{"notifications.webhook.url", "notifications.webhook.headers."}
+
+// configComments holds the comments the example config attaches to one key
+type configComments struct {
+	head string
+	line string
+}
+
+// dumpConfig writes the effective config as commented YAML with secrets replaced by
+// placeholders, suitable for sharing
+func dumpConfig(config Config, w io.Writer) error {
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+
+	comments := make(map[string]configComments)
+	var example yaml.Node
+	if err := yaml.Unmarshal(exampleConfig, &example); err == nil && len(example.Content) > 0 {
+		collectConfigComments(example.Content[0], "", comments)
+	}
+	annotateConfig(&doc, "", comments)
+	doc.HeadComment = "Lagbuster configuration exported with -dump-config\nSecrets are replaced with " + redactedValue + "; fill them in before use"
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+	return encoder.Close()
+}
+
+// collectConfigComments records the comments on every key of the example config by
+// dotted path (sequence items share the path of their sequence plus "[]")
+func collectConfigComments(node *yaml.Node, path string, comments map[string]configComments) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinConfigPath(path, key.Value)
+			if _, seen := comments[keyPath]; !seen {
+				line := value.LineComment
+				if line == "" {
+					line = key.LineComment
+				}
+				comments[keyPath] = configComments{head: key.HeadComment, line: line}
+			}
+			collectConfigComments(value, keyPath, comments)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			collectConfigComments(item, path+"[]", comments)
+		}
+	}
+}
+
+// annotateConfig copies the collected comments onto the dumped config and redacts secrets
+func annotateConfig(node *yaml.Node, path string, comments map[string]configComments) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			keyPath := joinConfigPath(path, key.Value)
+			if c, ok := comments[keyPath]; ok {
+				key.HeadComment = c.head
+				key.LineComment = c.line
+			}
+			if (isSecretConfigKey(key.Value) || isSecretConfigPath(keyPath)) && value.Kind == yaml.ScalarNode && value.Value != "" {
+				value.Value = redactedValue
+				value.Tag = "!!str"
+				value.Style = 0
+			}
+			annotateConfig(value, keyPath, comments)
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			// Comment only the first entry of a list
+			if i == 0 {
+				annotateConfig(item, path+"[]", comments)
+			} else {
+				annotateConfig(item, path+"[]", nil)
+			}
+		}
+	}
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretConfigKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretConfigPath(path string) bool {
+	for _, secret := range secretConfigPaths {
+		if path == secret || (strings.HasSuffix(secret, ".") && strings.HasPrefix(path, secret)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"lagbuster/database"
+)
+
+// defaultRecentEvents is the event ring size when api.recent_events is unset
+const defaultRecentEvents = 50
+
+// eventRing keeps the most recent events in memory so the dashboard's "what just
+// happened" view doesn't need the database. It has its own lock because the API
+// reads it without holding the monitoring loop's state.mu.
+type eventRing struct {
+	mu     sync.Mutex
+	events []database.Event // Circular buffer, next is the slot written next
+	next   int
+	full   bool
+}
+
+func newEventRing(size int) *eventRing {
+	if size <= 0 {
+		size = defaultRecentEvents
+	}
+	return &eventRing{events: make([]database.Event, size)}
+}
+
+// add stores an event, overwriting the oldest once the ring is full
+func (r *eventRing) add(event database.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events[r.next] = event
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// recent returns the stored events, newest first
+func (r *eventRing) recent() []database.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.events)
+	}
+	out := make([]database.Event, 0, count)
+	for i := 1; i <= count; i++ {
+		out = append(out, r.events[(r.next-i+len(r.events))%len(r.events)])
+	}
+	return out
+}
+
+// rememberEvent adds an event to the in-memory ring; id is the database row ID (0 without one)
+func rememberEvent(state *AppState, id int64, eventType string, peerName *string, oldHealth, newHealth *bool, reason string, metadata *string) {
+	if state.recentEvents == nil {
+		return
+	}
+	state.recentEvents.add(database.Event{
+		ID:        id,
+		Timestamp: time.Now(),
+		EventType: eventType,
+		PeerName:  peerName,
+		OldHealth: oldHealth,
+		NewHealth: newHealth,
+		Reason:    reason,
+		Metadata:  metadata,
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Explanation is a plain-language account of the current routing decision
+type Explanation struct {
+	Summary string                     `json:"summary"`
+	Steps   []ExplainStep              `json:"steps"` // Instance-wide checks, in the order they apply
+	Peers   map[string]PeerExplanation `json:"peers"`
+}
+
+// PeerExplanation explains why one peer is in or out of ECMP
+type PeerExplanation struct {
+	Summary  string        `json:"summary"`
+	InECMP   bool          `json:"in_ecmp"`
+	Priority int           `json:"priority"` // Priority the next apply will use
+	Steps    []ExplainStep `json:"steps"`
+}
+
+// ExplainStep is one check in the reasoning chain
+type ExplainStep struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail"`
+}
+
+// explainDecision walks the same checks the decision cycle uses (health rules, damping,
+// hold, flap deferral, BGP state) against the current state and reports each one,
+// without changing anything
+func explainDecision(state *AppState) Explanation {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	effective := priorityAssignment(state)
+	explanation := Explanation{Peers: make(map[string]PeerExplanation, len(state.Peers))}
+
+	frozen := state.frozen.Load()
+	applies := true
+	addStep := func(check string, passed bool, detail string) {
+		explanation.Steps = append(explanation.Steps, ExplainStep{Check: check, Passed: passed, Detail: detail})
+		if !passed {
+			applies = false
+		}
+	}
+	if state.standby.Load() {
+		addStep("standby", false, "warm standby: decisions are made but the active instance owns routing")
+	}
+	if state.dryRun != nil {
+		addStep("dry_run", false, "dry-run: priorities are recorded, never applied")
+	}
+	if state.settling {
+		addStep("settle_delay", false, "within startup.settle_delay: routing is not applied yet")
+	}
+	if frozen {
+		addStep("frozen", false, "routing frozen: priorities held at their last applied values")
+	}
+	if status := state.referenceStatus; status != nil {
+		detail := fmt.Sprintf("%d of %d reference targets answering (need %d)", status.Responding, status.Total, status.Required)
+		if !status.OK {
+			detail += ": local network looks down, peer health is held unchanged"
+		}
+		addStep("reference_quorum", status.OK, detail)
+	}
+	if applies {
+		addStep("apply", true, "priorities are applied each decision cycle")
+	}
+
+	var active []string
+	for name, peer := range state.Peers {
+		peerExplanation := explainPeer(state, peer)
+		peerExplanation.Priority = effective[name]
+		if frozen && peerExplanation.InECMP != (effective[name] == 1) {
+			peerExplanation.Summary += fmt.Sprintf(", but frozen at priority %d", effective[name])
+		}
+		peerExplanation.InECMP = effective[name] == 1
+		if peerExplanation.InECMP {
+			active = append(active, name)
+		}
+		explanation.Peers[name] = peerExplanation
+	}
+	sort.Strings(active)
+
+	switch len(active) {
+	case 0:
+		explanation.Summary = "No peer is in ECMP: none is both healthy and BGP-established"
+	case len(state.Peers):
+		explanation.Summary = fmt.Sprintf("All %d peers are in ECMP: %s", len(active), strings.Join(active, ", "))
+	default:
+		explanation.Summary = fmt.Sprintf("%d of %d peers in ECMP: %s", len(active), len(state.Peers), strings.Join(active, ", "))
+	}
+	if !applies {
+		explanation.Summary += " (not currently being applied)"
+	}
+	return explanation
+}
+
+// explainPeer reports each check that decides a peer's place in ECMP
+func explainPeer(state *AppState, peer *PeerState) PeerExplanation {
+	config := state.Config
+	name := peer.Config.Name
+	var e PeerExplanation
+	step := func(check string, passed bool, format string, args ...interface{}) {
+		e.Steps = append(e.Steps, ExplainStep{Check: check, Passed: passed, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	// The latest sample (or the smoothed latency, per damping.health_metric) against the health rules
+	latency := healthLatency(config.Damping, peer)
+	baseline := peerBaseline(peer.Config)
+	sampleHealthy, failed := isPeerHealthy(latency, latestQuality(peer), peer.Config, config.Thresholds, peer.IsHealthy)
+	if latency < 0 {
+		detail := "no reply to the latest probe"
+		if peer.LastProbeError != "" {
+			detail += " (" + peer.LastProbeError + ")"
+		}
+		step("latest_sample", false, "%s", detail)
+	} else {
+		limit := degradationLimit(config.Thresholds, peer.IsHealthy)
+		detail := fmt.Sprintf("%.2fms, %.2fms above baseline %.2fms (limit %.2fms, absolute max %.2fms)",
+			latency, latency-baseline, baseline, limit, config.Thresholds.AbsoluteMaxLatency)
+		if metric := config.Damping.HealthMetric; metric != "" && metric != metricRaw {
+			detail += fmt.Sprintf(", judged on %s (latest sample %.2fms)", metric, peer.CurrentLatency)
+		}
+		if peer.PacketLoss > 0 {
+			detail += fmt.Sprintf(", %.0f%% packet loss%s", peer.PacketLoss*100, expectedLossNote(peer.Config))
+		}
+		if peer.Jitter > 0 {
+			detail += fmt.Sprintf(", %.2fms jitter", peer.Jitter)
+		}
+		detail += pathCostNote(latency, latestQuality(peer), config.Thresholds)
+		if len(failed) > 0 {
+			detail += "; failed " + strings.Join(failed, ", ")
+		}
+		step("latest_sample", sampleHealthy, "%s", detail)
+	}
+
+	// Damping state
+	if peer.IsHealthy {
+		needed := config.Damping.ConsecutiveUnhealthyCount
+		step("damping", true, "healthy; %d of %d consecutive bad samples needed to leave ECMP", peer.ConsecutiveUnhealthyCount, needed)
+		if !peer.holdUntil.IsZero() && time.Now().Before(peer.holdUntil) {
+			step("min_active_hold", true, "recently rejoined; kept in ECMP for another %s unless unreachable",
+				time.Until(peer.holdUntil).Round(time.Second))
+		}
+	} else {
+		needed := config.Damping.ConsecutiveHealthyCountForRecovery
+		step("damping", false, "unhealthy; %d of %d consecutive good samples needed to rejoin ECMP", peer.ConsecutiveHealthyCount, needed)
+		if peer.recoveryDeferred {
+			if flaps, unstable := recentlyFlapping(state, name); unstable {
+				step("flap_guard", false, "recovery deferred: %d health changes in the last %d minutes (max %d)",
+					flaps, config.Damping.FlapWindow, config.Damping.MaxRecentFlaps)
+			}
+		}
+	}
+	if healthWeighted(config.Damping) {
+		share, samples := weightedHealthyShare(state, peer)
+		step("health_weighting", share >= 0.5, "%s-weighted healthy share %.0f%% over %d samples",
+			config.Damping.HealthWeighting, share*100, samples)
+	}
+
+	// BGP session
+	bgpState := peer.BGPSessionState
+	if bgpState == "" {
+		bgpState = "not checked yet"
+	}
+	step("bgp_session", peer.BGPSessionUp, "BGP session %s", bgpState)
+
+	single := singlePeerMode(state)
+	if single {
+		step("single_peer", true, "only peer configured: no failover possible, kept in use whatever its health (mode.single_peer)")
+	}
+
+	if peer.Disabled {
+		step("enabled", false, "disabled by an operator (POST /api/peers/%s/enable to undo)", name)
+	}
+
+	inECMP := !peer.Disabled && (peer.IsHealthy || single) && peer.BGPSessionUp
+	switch {
+	case peer.Disabled:
+		e.Summary = fmt.Sprintf("%s is out of ECMP: disabled by an operator", name)
+	case inECMP && !peer.IsHealthy:
+		e.Summary = fmt.Sprintf("%s is in ECMP: unhealthy, but the only peer", name)
+	case inECMP:
+		e.Summary = fmt.Sprintf("%s is in ECMP: healthy and BGP established", name)
+	case !peer.IsHealthy && !peer.BGPSessionUp:
+		e.Summary = fmt.Sprintf("%s is out of ECMP: unhealthy and BGP session %s", name, bgpState)
+	case !peer.IsHealthy:
+		e.Summary = fmt.Sprintf("%s is out of ECMP: unhealthy", name)
+	default:
+		e.Summary = fmt.Sprintf("%s is out of ECMP: healthy, but BGP session %s", name, bgpState)
+	}
+	e.InECMP = inECMP
+	return e
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"lagbuster/notifications"
+)
+
+// HAConfig sets up a warm standby that follows an active instance and takes over
+// when it goes quiet
+type HAConfig struct {
+	Role          string `yaml:"role" json:"role"`                     // active (default) or standby
+	ActiveURL     string `yaml:"active_url" json:"active_url"`         // Active instance's API base URL, including any api.path_prefix
+	TakeoverAfter int    `yaml:"takeover_after" json:"takeover_after"` // Seconds without a decision heartbeat from the active instance before taking over, default 60
+}
+
+// defaultTakeoverAfter applies when ha.takeover_after is unset
+const defaultTakeoverAfter = 60 * time.Second
+
+// haReconnectDelay is how long the standby waits before reconnecting to the active instance
+const haReconnectDelay = 5 * time.Second
+
+// validateHA checks the ha section. The active instance sends a heartbeat every decision
+// cycle, so takeover_after has to leave room for more than one.
+func validateHA(config HAConfig, damping DampingConfig) error {
+	switch config.Role {
+	case "", "active":
+		return nil
+	case "standby":
+		if config.ActiveURL == "" {
+			return fmt.Errorf("ha.active_url is required for ha.role standby")
+		}
+		if config.TakeoverAfter < 0 {
+			return fmt.Errorf("ha.takeover_after must not be negative")
+		}
+		if takeoverAfter(config) <= 2*time.Duration(damping.MeasurementInterval)*time.Second {
+			return fmt.Errorf("ha.takeover_after must be more than twice damping.measurement_interval (%ds)", damping.MeasurementInterval)
+		}
+		return nil
+	default:
+		return fmt.Errorf("ha.role must be active or standby, got %q", config.Role)
+	}
+}
+
+// takeoverAfter returns ha.takeover_after, or its default
+func takeoverAfter(config HAConfig) time.Duration {
+	if config.TakeoverAfter > 0 {
+		return time.Duration(config.TakeoverAfter) * time.Second
+	}
+	return defaultTakeoverAfter
+}
+
+// standbyFollower tracks the active instance from a standby
+type standbyFollower struct {
+	state    *AppState
+	notifier *notifications.Notifier // Held back while standing by so alerts aren't sent twice
+	lastSeen atomic.Int64            // Unix nanoseconds of the active instance's last decision heartbeat
+}
+
+// startStandby puts the instance in observer mode: it keeps measuring and deciding but
+// applies nothing and sends no notifications. It follows the active instance's SSE feed,
+// which carries a heartbeat from every decision cycle, and promotes itself once no
+// heartbeat has arrived for ha.takeover_after. Status updates don't count: the API keeps
+// sending them when the monitoring loop is stuck. Once the active instance's heartbeats
+// resume, the promoted standby hands routing back and stands by again.
+func startStandby(ctx context.Context, state *AppState) {
+	state.standby.Store(true)
+	follower := &standbyFollower{state: state, notifier: state.notifier}
+	state.notifier = nil
+	follower.lastSeen.Store(time.Now().UnixNano())
+
+	logger.Warn("STANDBY: following active instance at %s - routing changes will not be applied", state.Config.HA.ActiveURL)
+
+	go follower.follow(ctx)
+	go follower.watch(ctx)
+}
+
+// follow reads the active instance's SSE feed, reconnecting until the context ends
+func (f *standbyFollower) follow(ctx context.Context) {
+	url := strings.TrimRight(f.state.Config.HA.ActiveURL, "/") + "/api/sse"
+	connected := false
+
+	for ctx.Err() == nil {
+		err := f.readStream(ctx, url, &connected)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			logger.Warn("STANDBY: lost active instance feed: %v", err)
+			connected = false
+		} else {
+			logger.Debug("STANDBY: cannot reach active instance: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(haReconnectDelay):
+		}
+	}
+}
+
+// readStream consumes one SSE connection; heartbeats count as signs of life
+func (f *standbyFollower) readStream(ctx context.Context, url string, connected *bool) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if !*connected {
+		logger.Info("STANDBY: connected to active instance feed at %s", url)
+		*connected = true
+	}
+
+	var eventType string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			switch eventType {
+			case "heartbeat":
+				f.lastSeen.Store(time.Now().UnixNano())
+			case "event":
+				f.logActiveEvent(strings.TrimPrefix(line, "data: "))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream closed")
+}
+
+// logActiveEvent mirrors the active instance's events into the standby's log
+func (f *standbyFollower) logActiveEvent(data string) {
+	var envelope struct {
+		Data struct {
+			EventType string `json:"event_type"`
+			PeerName  string `json:"peer_name"`
+			Reason    string `json:"reason"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(data), &envelope); err != nil {
+		return
+	}
+	event := envelope.Data
+	logger.Info("STANDBY: active instance reported %s %s: %s", event.EventType, event.PeerName, event.Reason)
+}
+
+// watch promotes the standby once the active instance has been silent too long, and
+// demotes it again when the active instance's heartbeats resume
+func (f *standbyFollower) watch(ctx context.Context) {
+	timeout := takeoverAfter(f.state.Config.HA)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var promotedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lastSeen := time.Unix(0, f.lastSeen.Load())
+			if promotedAt.IsZero() {
+				if silence := time.Since(lastSeen); silence >= timeout {
+					f.promote(silence)
+					promotedAt = time.Now()
+				}
+			} else if lastSeen.After(promotedAt) {
+				f.demote()
+				promotedAt = time.Time{}
+			}
+		}
+	}
+}
+
+// promote makes this instance active: the next decision cycle applies routing
+func (f *standbyFollower) promote(silence time.Duration) {
+	state := f.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	state.notifier = f.notifier
+	state.standby.Store(false)
+
+	reason := fmt.Sprintf("no message from active instance %s for %s, taking over", state.Config.HA.ActiveURL, silence.Round(time.Second))
+	logger.Warn("STANDBY PROMOTED: %s", reason)
+	recordEvent(state, "ha_promoted", nil, nil, nil, reason, nil)
+	updateAPIServerState(state)
+}
+
+// demote hands routing back to the active instance once it is deciding again, so the two
+// don't both keep writing the BGP config
+func (f *standbyFollower) demote() {
+	state := f.state
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	f.notifier = state.notifier
+	state.notifier = nil
+	state.standby.Store(true)
+
+	reason := fmt.Sprintf("active instance %s is deciding again, handing routing back", state.Config.HA.ActiveURL)
+	logger.Warn("STANDBY DEMOTED: %s", reason)
+	recordEvent(state, "ha_demoted", nil, nil, nil, reason, nil)
+	updateAPIServerState(state)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+
+	"lagbuster/stats"
+)
+
+// Latencies a peer's health can be judged on (damping.health_metric)
+const (
+	metricRaw  = "raw"  // The latest sample (default)
+	metricEWMA = "ewma" // Running EWMA of the answered samples, weighted by damping.ewma_alpha
+	metricMean = "mean" // Mean of the answered samples in the measurement window
+	// pNN (e.g. p95) is that percentile of the answered samples in the measurement window
+)
+
+// validateHealthMetric checks damping.health_metric and damping.ewma_alpha
+func validateHealthMetric(config DampingConfig) error {
+	switch config.HealthMetric {
+	case "", metricRaw, metricEWMA, metricMean:
+	default:
+		if _, err := stats.ParsePercentile(config.HealthMetric); err != nil {
+			return fmt.Errorf("damping.health_metric must be raw, ewma, mean or pNN (e.g. p95), got %q", config.HealthMetric)
+		}
+	}
+	if config.EWMAAlpha < 0 || config.EWMAAlpha > 1 {
+		return fmt.Errorf("damping.ewma_alpha must be between 0 and 1, got %g", config.EWMAAlpha)
+	}
+	return nil
+}
+
+// ewmaAlpha returns damping.ewma_alpha, or the default when unset
+func ewmaAlpha(config DampingConfig) float64 {
+	if config.EWMAAlpha > 0 {
+		return config.EWMAAlpha
+	}
+	return stats.DefaultEWMAAlpha
+}
+
+// updateSmoothedLatency folds the peer's latest sample into its EWMA. Failed probes
+// leave the average unchanged. It runs for every recorded probe, not every decision, so
+// peers probed faster than decisions are made get the same EWMA as the stored history.
+func updateSmoothedLatency(config DampingConfig, peer *PeerState) {
+	peer.SmoothedLatency = stats.EWMA(peer.SmoothedLatency, peer.CurrentLatency, ewmaAlpha(config))
+}
+
+// healthLatency returns the latency the peer's latest sample is judged on. A failed
+// probe counts as unreachable whatever the metric, so outages are never averaged away;
+// the window statistics fall back to the latest sample until the window has
+// min_samples_for_stats answered samples.
+func healthLatency(config DampingConfig, peer *PeerState) float64 {
+	metric := config.HealthMetric
+	if peer.CurrentLatency < 0 || metric == "" || metric == metricRaw {
+		return peer.CurrentLatency
+	}
+	if metric == metricEWMA {
+		if peer.SmoothedLatency >= 0 {
+			return peer.SmoothedLatency
+		}
+		return peer.CurrentLatency
+	}
+
+	minSamples := minSamplesForStats(config)
+	mean, _, n := stats.MeanStdDev(peer.Measurements, minSamples)
+	if n < minSamples {
+		return peer.CurrentLatency
+	}
+	if metric == metricMean {
+		return mean
+	}
+	p, _ := stats.ParsePercentile(metric) // Checked by validateHealthMetric
+	return stats.Percentile(peer.Measurements, p)
+}
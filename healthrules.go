@@ -0,0 +1,125 @@
+package main
+
+import "fmt"
+
+// Health rules a sample is checked against (thresholds.health_rules)
+const (
+	ruleUnreachable = "unreachable"  // The probe got no reply
+	ruleAbsoluteMax = "absolute_max" // Latency above thresholds.absolute_max_latency
+	ruleDegradation = "degradation"  // Latency too far above the peer's baseline (with hysteresis)
+	rulePacketLoss  = "packet_loss"  // More echoes lost than thresholds.packet_loss_threshold above peers[].expected_loss
+	ruleJitter      = "jitter"       // Echo RTTs spread wider than thresholds.jitter_threshold
+)
+
+// Ways of combining failed rules (thresholds.health_rule_mode)
+const (
+	ruleModeAny = "any" // Unhealthy when any rule fails (default)
+	ruleModeAll = "all" // Unhealthy only when every rule fails
+)
+
+// defaultHealthRules is the order rules are checked in when none are configured.
+// packet_loss and jitter join them when their thresholds are set.
+var defaultHealthRules = []string{ruleUnreachable, ruleAbsoluteMax, ruleDegradation}
+
+// healthRuleChecks report whether a sample fails a rule. A sample without a reply fails
+// every rule, since there is no latency to judge.
+var healthRuleChecks = map[string]func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool{
+	ruleUnreachable: func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool {
+		return latency < 0
+	},
+	ruleAbsoluteMax: func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool {
+		return latency < 0 || latency > thresholds.AbsoluteMaxLatency
+	},
+	ruleDegradation: func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool {
+		return latency < 0 || latency-baseline > degradationLimit(thresholds, isHealthy)
+	},
+	rulePacketLoss: func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool {
+		return latency < 0 || (thresholds.PacketLossThreshold > 0 && quality.PacketLoss > thresholds.PacketLossThreshold)
+	},
+	ruleJitter: func(latency float64, quality sampleQuality, baseline float64, thresholds ThresholdConfig, isHealthy bool) bool {
+		return latency < 0 || (thresholds.JitterThreshold > 0 && quality.Jitter > thresholds.JitterThreshold)
+	},
+}
+
+// validateHealthRules checks rule names and modes, globally and per peer
+func validateHealthRules(config Config) error {
+	check := func(where string, rules []string, mode string) error {
+		for _, rule := range rules {
+			if _, ok := healthRuleChecks[rule]; !ok {
+				return fmt.Errorf("%s: unknown health rule %q (expected unreachable, absolute_max, degradation, packet_loss or jitter)", where, rule)
+			}
+		}
+		if mode != "" && mode != ruleModeAny && mode != ruleModeAll {
+			return fmt.Errorf("%s: health rule mode must be any or all, got %q", where, mode)
+		}
+		return nil
+	}
+
+	if err := check("thresholds.health_rules", config.Thresholds.HealthRules, config.Thresholds.HealthRuleMode); err != nil {
+		return err
+	}
+	if loss := config.Thresholds.PacketLossThreshold; loss < 0 || loss >= 1 {
+		return fmt.Errorf("thresholds.packet_loss_threshold must be at least 0 and below 1, got %g", loss)
+	}
+	if config.Thresholds.JitterThreshold < 0 {
+		return fmt.Errorf("thresholds.jitter_threshold must not be negative")
+	}
+	if config.Thresholds.LossPenalty < 0 {
+		return fmt.Errorf("thresholds.loss_penalty_ms_per_percent must not be negative")
+	}
+	if config.Thresholds.JitterPenalty < 0 {
+		return fmt.Errorf("thresholds.jitter_penalty must not be negative")
+	}
+	for _, peer := range config.Peers {
+		if err := check("peer "+peer.Name, peer.HealthRules, peer.HealthRuleMode); err != nil {
+			return err
+		}
+		if loss := peer.ExpectedLoss; loss < 0 || loss >= 1 {
+			return fmt.Errorf("peer %s: expected_loss must be at least 0 and below 1, got %g", peer.Name, loss)
+		}
+	}
+	return nil
+}
+
+// peerHealthRules returns the rules and mode that apply to a peer: its own if set,
+// otherwise the global ones, otherwise the defaults
+func peerHealthRules(thresholds ThresholdConfig, peer PeerConfig) ([]string, string) {
+	rules := peer.HealthRules
+	if len(rules) == 0 {
+		rules = thresholds.HealthRules
+	}
+	if len(rules) == 0 {
+		rules = defaultHealthRules
+		if thresholds.PacketLossThreshold > 0 {
+			rules = append(rules[:len(rules):len(rules)], rulePacketLoss)
+		}
+		if thresholds.JitterThreshold > 0 {
+			rules = append(rules[:len(rules):len(rules)], ruleJitter)
+		}
+	}
+
+	mode := peer.HealthRuleMode
+	if mode == "" {
+		mode = thresholds.HealthRuleMode
+	}
+	if mode == "" {
+		mode = ruleModeAny
+	}
+	return rules, mode
+}
+
+// excessLoss is how much of a measurement's loss is above the peer's expected_loss
+func excessLoss(loss float64, peer PeerConfig) float64 {
+	if loss <= peer.ExpectedLoss {
+		return 0
+	}
+	return loss - peer.ExpectedLoss
+}
+
+// expectedLossNote describes a peer's expected_loss, empty when it has none
+func expectedLossNote(peer PeerConfig) string {
+	if peer.ExpectedLoss <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (expected %.0f%%)", peer.ExpectedLoss*100)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+)
+
+// httpProbeClient sends http probes: a fresh connection every time, so each probe pays
+// for the handshake like a new visitor would, and no redirects followed, so the
+// first response is the one timed
+var httpProbeClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		DisableKeepAlives: true,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// validateProbeURL checks a peer's probe_url for the http probe method
+func validateProbeURL(peer PeerConfig) error {
+	parsed, err := url.Parse(peer.ProbeURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("peer %s: http probing needs an http:// or https:// probe_url, got %q", peer.Name, peer.ProbeURL)
+	}
+	return nil
+}
+
+// httpPing sends a GET to rawURL and returns the time to the first response byte in
+// milliseconds, or -1 and the reason it failed. A 5xx status counts as a failure.
+func httpPing(ctx context.Context, rawURL string) (float64, string) {
+	var firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, rawURL, nil)
+	if err != nil {
+		return -1, fmt.Sprintf("request failed: %v", err)
+	}
+	req.Header.Set("User-Agent", "lagbuster")
+
+	start := time.Now()
+	resp, err := httpProbeClient.Do(req)
+	if err != nil {
+		logger.Debug("HTTP probe to %s: %v", rawURL, err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return -1, "timeout"
+		}
+		return -1, fmt.Sprintf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		logger.Debug("HTTP probe to %s: %s", rawURL, resp.Status)
+		return -1, fmt.Sprintf("http status %d", resp.StatusCode)
+	}
+	if firstByte.IsZero() {
+		firstByte = time.Now()
+	}
+	return float64(firstByte.Sub(start).Microseconds()) / 1000, ""
+}
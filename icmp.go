@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Probe methods (probe.method)
+const (
+	probeMethodExec = "exec" // Default: run the system ping binary and parse its output
+	probeMethodICMP = "icmp" // Send ICMP echo requests from the process itself
+	probeMethodTCP  = "tcp"  // Time a TCP handshake to the peer's probe port, for hosts that drop ICMP
+	probeMethodHTTP = "http" // Time to first byte of a GET to the peer's probe URL
+)
+
+// icmpProbeTimeout matches the exec ping's 3 second reply timeout
+const icmpProbeTimeout = 3 * time.Second
+
+// ICMP protocol numbers for icmp.ParseMessage
+const (
+	protocolICMP   = 1
+	protocolICMPv6 = 58
+)
+
+// icmpSeq numbers echo requests so replies can be matched to them
+var icmpSeq atomic.Uint32
+
+// nativePing sends one ICMP echo request to host and times the reply, returning the
+// latency in milliseconds and reply TTL, or -1 and the reason it failed. It prefers an
+// unprivileged ICMP datagram socket (Linux net.ipv4.ping_group_range, macOS) and falls
+// back to a raw socket, which needs root or CAP_NET_RAW.
+func nativePing(ctx context.Context, host string, useIPv6 bool, probe ProbeConfig) (float64, int, string) {
+	ip, err := resolveProbeAddr(ctx, host, useIPv6)
+	if err != nil {
+		logger.Debug("ICMP probe to %s: %v", host, err)
+		return -1, 0, "dns lookup failed"
+	}
+	v4 := ip.To4() != nil
+
+	conn, datagram, err := listenICMP(v4)
+	if err != nil {
+		logger.Debug("ICMP probe to %s: %v", host, err)
+		return -1, 0, fmt.Sprintf("ping failed: %v", err)
+	}
+	defer conn.Close()
+
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if datagram {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	// Datagram sockets get their echo ID from the kernel, which also filters replies
+	id := os.Getpid() & 0xffff
+	seq := int(icmpSeq.Add(1) & 0xffff)
+	msg := icmp.Message{Code: 0, Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("lagbuster")}}
+	if v4 {
+		msg.Type = ipv4.ICMPTypeEcho
+		if probe.TTL > 0 {
+			conn.IPv4PacketConn().SetTTL(probe.TTL)
+		}
+		conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	} else {
+		msg.Type = ipv6.ICMPTypeEchoRequest
+		if probe.TTL > 0 {
+			conn.IPv6PacketConn().SetHopLimit(probe.TTL)
+		}
+		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	}
+	packet, err := msg.Marshal(nil)
+	if err != nil {
+		return -1, 0, fmt.Sprintf("ping failed: %v", err)
+	}
+
+	deadline := time.Now().Add(icmpProbeTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetReadDeadline(deadline)
+
+	start := time.Now()
+	if _, err := conn.WriteTo(packet, dst); err != nil {
+		logger.Debug("ICMP probe to %s: %v", host, err)
+		return -1, 0, fmt.Sprintf("ping failed: %v", err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, ttl, src, err := readICMP(conn, v4, buf)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return -1, 0, "timeout"
+			}
+			return -1, 0, fmt.Sprintf("ping failed: %v", err)
+		}
+		rtt := time.Since(start)
+
+		if !sameIP(src, ip) {
+			continue
+		}
+		proto := protocolICMPv6
+		if v4 {
+			proto = protocolICMP
+		}
+		reply, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch reply.Type {
+		case ipv4.ICMPTypeEchoReply, ipv6.ICMPTypeEchoReply:
+			echo, ok := reply.Body.(*icmp.Echo)
+			if !ok || echo.Seq != seq || (!datagram && echo.ID != id) {
+				continue
+			}
+			return float64(rtt.Microseconds()) / 1000, ttl, ""
+		case ipv4.ICMPTypeDestinationUnreachable, ipv6.ICMPTypeDestinationUnreachable:
+			return -1, 0, "destination unreachable"
+		}
+	}
+}
+
+// resolveProbeAddr picks the address to probe: the host's first IPv4 address, or its
+// first IPv6 one when useIPv6 is set (see probeOverIPv6)
+func resolveProbeAddr(ctx context.Context, host string, useIPv6 bool) (net.IP, error) {
+	family := "IPv4"
+	if useIPv6 {
+		family = "IPv6"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if (ip.To4() == nil) != useIPv6 {
+			return nil, fmt.Errorf("%s is not an %s address", host, family)
+		}
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if (addr.IP.To4() == nil) == useIPv6 {
+			return addr.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s address for %s", family, host)
+}
+
+// listenICMP opens an ICMP socket, reporting whether it is a datagram (unprivileged) one
+func listenICMP(v4 bool) (*icmp.PacketConn, bool, error) {
+	datagramNet, rawNet, addr := "udp4", "ip4:icmp", "0.0.0.0"
+	if !v4 {
+		datagramNet, rawNet, addr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+	if conn, err := icmp.ListenPacket(datagramNet, addr); err == nil {
+		return conn, true, nil
+	}
+	conn, err := icmp.ListenPacket(rawNet, addr)
+	if err != nil {
+		return nil, false, fmt.Errorf("opening ICMP socket (needs net.ipv4.ping_group_range or CAP_NET_RAW): %w", err)
+	}
+	return conn, false, nil
+}
+
+// readICMP reads one ICMP message and the TTL (hop limit) it arrived with
+func readICMP(conn *icmp.PacketConn, v4 bool, buf []byte) (int, int, net.Addr, error) {
+	if v4 {
+		n, cm, src, err := conn.IPv4PacketConn().ReadFrom(buf)
+		ttl := 0
+		if cm != nil {
+			ttl = cm.TTL
+		}
+		return n, ttl, src, err
+	}
+	n, cm, src, err := conn.IPv6PacketConn().ReadFrom(buf)
+	hopLimit := 0
+	if cm != nil {
+		hopLimit = cm.HopLimit
+	}
+	return n, hopLimit, src, err
+}
+
+// sameIP reports whether a reply's source address is the probed IP
+func sameIP(addr net.Addr, ip net.IP) bool {
+	switch a := addr.(type) {
+	case *net.IPAddr:
+		return a.IP.Equal(ip)
+	case *net.UDPAddr:
+		return a.IP.Equal(ip)
+	}
+	return false
+}
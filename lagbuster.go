@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"lagbuster/api"
@@ -12,10 +13,16 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -23,74 +30,138 @@ import (
 
 // Configuration structures
 type Config struct {
-	Peers            []PeerConfig              `yaml:"peers"`
-	Thresholds       ThresholdConfig           `yaml:"thresholds"`
-	Damping          DampingConfig             `yaml:"damping"`
-	Startup          StartupConfig             `yaml:"startup"`
-	Bird             BirdConfig                `yaml:"bird"`
-	ExaBGP           ExaBGPConfig              `yaml:"exabgp"`
-	AnnouncedPrefixes []string                 `yaml:"announced_prefixes"`
-	Logging          LoggingConfig             `yaml:"logging"`
-	Mode             ModeConfig                `yaml:"mode"`
-	API              APIConfig                 `yaml:"api"`
-	Database         DatabaseConfig            `yaml:"database"`
-	Notifications    notifications.MainConfig  `yaml:"notifications"`
+	Peers             []PeerConfig             `yaml:"peers" json:"peers"`
+	Thresholds        ThresholdConfig          `yaml:"thresholds" json:"thresholds"`
+	Damping           DampingConfig            `yaml:"damping" json:"damping"`
+	Startup           StartupConfig            `yaml:"startup" json:"startup"`
+	Bird              BirdConfig               `yaml:"bird" json:"bird"`
+	ExaBGP            ExaBGPConfig             `yaml:"exabgp" json:"exabgp"`
+	AnnouncedPrefixes []string                 `yaml:"announced_prefixes" json:"announced_prefixes"`
+	Logging           LoggingConfig            `yaml:"logging" json:"logging"`
+	Mode              ModeConfig               `yaml:"mode" json:"mode"`
+	API               APIConfig                `yaml:"api" json:"api"`
+	Database          DatabaseConfig           `yaml:"database" json:"database"`
+	Notifications     notifications.MainConfig `yaml:"notifications" json:"notifications"`
+	Probe             ProbeConfig              `yaml:"probe" json:"probe"`
+	Reference         ReferenceConfig          `yaml:"reference" json:"reference"`
+	HA                HAConfig                 `yaml:"ha" json:"ha"`
+	Baseline          BaselineConfig           `yaml:"baseline" json:"baseline"`
 }
 
 type PeerConfig struct {
-	Name             string  `yaml:"name"`
-	Hostname         string  `yaml:"hostname"`
-	ExpectedBaseline float64 `yaml:"expected_baseline"`
-	BirdVariable     string  `yaml:"bird_variable"`  // For Bird mode: define variable name in lagbuster-priorities.conf
-	BirdProtocol     string  `yaml:"bird_protocol"`  // For Bird mode: Bird protocol name (e.g. EDGE_NYC_01)
-	NextHop          string  `yaml:"nexthop"`        // For ExaBGP mode - BGP next-hop IPv6 address
+	Name                string         `yaml:"name" json:"name"`
+	Hostname            string         `yaml:"hostname" json:"hostname"`
+	ExpectedBaseline    float64        `yaml:"expected_baseline" json:"expected_baseline"`
+	BaselineWindows     []TimeBaseline `yaml:"baseline_windows" json:"baseline_windows"`         // Baselines for times of day, overriding expected_baseline
+	BirdVariable        string         `yaml:"bird_variable" json:"bird_variable"`               // For Bird mode: define variable name in lagbuster-priorities.conf
+	BirdProtocol        string         `yaml:"bird_protocol" json:"bird_protocol"`               // For Bird mode: Bird protocol name (e.g. EDGE_NYC_01)
+	NextHop             string         `yaml:"nexthop" json:"nexthop"`                           // For ExaBGP mode - BGP next-hop IPv6 address
+	OWDResponder        string         `yaml:"owd_responder" json:"owd_responder"`               // Optional host:port of a lagbuster -owd-responder for one-way delay probes
+	MeasurementInterval int            `yaml:"measurement_interval" json:"measurement_interval"` // Seconds between probes, 0 = damping.measurement_interval
+	NotificationGroup   string         `yaml:"notification_group" json:"notification_group"`     // Routes this peer's alerts to channels listing the group
+	HealthRules         []string       `yaml:"health_rules" json:"health_rules"`                 // Overrides thresholds.health_rules for this peer
+	HealthRuleMode      string         `yaml:"health_rule_mode" json:"health_rule_mode"`         // Overrides thresholds.health_rule_mode for this peer
+	LatencyBudget       *LatencyBudget `yaml:"latency_budget" json:"latency_budget"`             // Optional cumulative time-over-threshold alert
+	ProbeMinSpacing     int            `yaml:"probe_min_spacing" json:"probe_min_spacing"`       // Milliseconds between probes to this peer, overrides probe.min_spacing
+	ProbeMethod         string         `yaml:"probe_method" json:"probe_method"`                 // Overrides probe.method for this peer (exec, icmp, tcp or http)
+	ProbePort           int            `yaml:"probe_port" json:"probe_port"`                     // TCP port for tcp probing, overrides probe.port
+	ProbePorts          []int          `yaml:"probe_ports" json:"probe_ports"`                   // TCP ports for tcp probing, all probed at once; overrides probe.port/probe.ports
+	ProbePortQuorum     int            `yaml:"probe_port_quorum" json:"probe_port_quorum"`       // How many of probe_ports must connect, 0 = all
+	ProbeSourcePort     string         `yaml:"probe_source_port" json:"probe_source_port"`       // Local port or "first-last" range for tcp probing, overrides probe.source_port
+	ProbeURL            string         `yaml:"probe_url" json:"probe_url"`                       // URL for http probing (5xx or errors count as unreachable)
+	ProbeFamily         string         `yaml:"probe_family" json:"probe_family"`                 // ipv4, ipv6 or auto (IPv6 if the host has an AAAA record); unset = IPv4 unless the host is IPv6-only
+	ExpectedLoss        float64        `yaml:"expected_loss" json:"expected_loss"`               // Fraction of echoes this peer normally loses; the packet_loss rule judges loss above it
+	HealthProbes        []string       `yaml:"health_probes" json:"health_probes"`               // Probe methods sent together in place of probe_method, combined by health_consensus
+	HealthConsensus     string         `yaml:"health_consensus" json:"health_consensus"`         // all (default): a sample is bad only if every probe's is; any; majority
 }
 
 type ThresholdConfig struct {
-	DegradationThreshold float64 `yaml:"degradation_threshold"`
-	AbsoluteMaxLatency   float64 `yaml:"absolute_max_latency"`
-	TimeoutLatency       float64 `yaml:"timeout_latency"`
+	DegradationThreshold float64  `yaml:"degradation_threshold" json:"degradation_threshold"`
+	AbsoluteMaxLatency   float64  `yaml:"absolute_max_latency" json:"absolute_max_latency"`
+	TimeoutLatency       float64  `yaml:"timeout_latency" json:"timeout_latency"`
+	HysteresisEnter      float64  `yaml:"hysteresis_enter" json:"hysteresis_enter"`                       // ms above degradation_threshold before a healthy peer's sample counts as bad
+	HysteresisExit       float64  `yaml:"hysteresis_exit" json:"hysteresis_exit"`                         // ms below degradation_threshold before an unhealthy peer's sample counts as good
+	MaxPathAsymmetry     float64  `yaml:"max_path_asymmetry" json:"max_path_asymmetry"`                   // ms between forward and reverse delay, 0 = disabled
+	HealthRules          []string `yaml:"health_rules" json:"health_rules"`                               // Rules checked in order (unreachable, absolute_max, degradation), default all
+	HealthRuleMode       string   `yaml:"health_rule_mode" json:"health_rule_mode"`                       // any (default): a sample is unhealthy if any rule fails; all: only if every rule fails
+	PacketLossThreshold  float64  `yaml:"packet_loss_threshold" json:"packet_loss_threshold"`             // Fraction of echoes lost above which a sample is unhealthy, 0 = disabled
+	JitterThreshold      float64  `yaml:"jitter_threshold" json:"jitter_threshold"`                       // ms of echo RTT standard deviation above which a sample is unhealthy, 0 = disabled
+	LossPenalty          float64  `yaml:"loss_penalty_ms_per_percent" json:"loss_penalty_ms_per_percent"` // ms added to a sample's latency per percent of packet loss before the latency rules, 0 = none
+	JitterPenalty        float64  `yaml:"jitter_penalty" json:"jitter_penalty"`                           // ms added to a sample's latency per ms of jitter before the latency rules, 0 = none
 }
 
 type DampingConfig struct {
-	ConsecutiveUnhealthyCount          int `yaml:"consecutive_unhealthy_count"`
-	ConsecutiveHealthyCountForRecovery int `yaml:"consecutive_healthy_count_for_recovery"`
-	MeasurementInterval                int `yaml:"measurement_interval"`
-	MeasurementWindow                  int `yaml:"measurement_window"`
+	ConsecutiveUnhealthyCount          int     `yaml:"consecutive_unhealthy_count" json:"consecutive_unhealthy_count"`
+	ConsecutiveHealthyCountForRecovery int     `yaml:"consecutive_healthy_count_for_recovery" json:"consecutive_healthy_count_for_recovery"`
+	MeasurementInterval                int     `yaml:"measurement_interval" json:"measurement_interval"`
+	MeasurementWindow                  int     `yaml:"measurement_window" json:"measurement_window"`
+	CanaryCount                        int     `yaml:"canary_count" json:"canary_count"`                           // Confirmation probes before a peer recovers, 0 = disabled
+	CanarySpacing                      int     `yaml:"canary_spacing" json:"canary_spacing"`                       // Milliseconds between canary probes
+	UnreachableBackoffAfter            int     `yaml:"unreachable_backoff_after" json:"unreachable_backoff_after"` // Unreachable probes before an unhealthy peer's interval starts doubling, 0 = disabled
+	UnreachableBackoffMax              int     `yaml:"unreachable_backoff_max" json:"unreachable_backoff_max"`     // Cap for the backed-off probe interval in seconds
+	MaxRecentFlaps                     int     `yaml:"max_recent_flaps" json:"max_recent_flaps"`                   // Health changes within flap_window that block recovery, 0 = disabled
+	FlapWindow                         int     `yaml:"flap_window" json:"flap_window"`                             // Minutes of event history checked for flaps
+	HealthWeighting                    string  `yaml:"health_weighting" json:"health_weighting"`                   // consecutive (default), linear or exponential
+	HealthWeightDecay                  float64 `yaml:"health_weight_decay" json:"health_weight_decay"`             // Exponential weighting: weight ratio of each older sample, default 0.7
+	HealthWeightDegrade                float64 `yaml:"health_weight_degrade" json:"health_weight_degrade"`         // Weighted unhealthy share that marks a peer unhealthy, default 0.5
+	HealthWeightRecovery               float64 `yaml:"health_weight_recovery" json:"health_weight_recovery"`       // Weighted healthy share that lets a peer recover, default 0.8
+	MinActiveHold                      int     `yaml:"min_active_hold" json:"min_active_hold"`                     // Seconds a recovered peer stays in ECMP unless unreachable, 0 = disabled
+	ProbeCount                         int     `yaml:"probe_count" json:"probe_count"`                             // Echoes per measurement, averaged over the replies, default 1
+	MinSamplesForStats                 int     `yaml:"min_samples_for_stats" json:"min_samples_for_stats"`         // Answered samples needed before jitter/percentile criteria apply, default 3
+	HealthMetric                       string  `yaml:"health_metric" json:"health_metric"`                         // Latency health is judged on: raw (default, latest sample), ewma, or mean / pNN of the window
+	EWMAAlpha                          float64 `yaml:"ewma_alpha" json:"ewma_alpha"`                               // Weight of the newest sample in the EWMA, default 0.3
 }
 
 type StartupConfig struct {
-	GracePeriod int `yaml:"grace_period"`
+	GracePeriod     int    `yaml:"grace_period" json:"grace_period"`
+	SettleDelay     int    `yaml:"settle_delay" json:"settle_delay"`         // Seconds to keep probing after the first measurement before the first apply
+	MissingBaseline string `yaml:"missing_baseline" json:"missing_baseline"` // warn (default), reject or probe for peers with expected_baseline <= 0
 }
 
 type BirdConfig struct {
-	PrioritiesFile string `yaml:"priorities_file"`
-	BirdcPath      string `yaml:"birdc_path"`
-	BirdcTimeout   int    `yaml:"birdc_timeout"`
+	PrioritiesFile   string         `yaml:"priorities_file" json:"priorities_file"`
+	BirdcPath        string         `yaml:"birdc_path" json:"birdc_path"`
+	BirdcTimeout     int            `yaml:"birdc_timeout" json:"birdc_timeout"`
+	PriorityValueMap map[int]string `yaml:"priority_value_map" json:"priority_value_map"` // Optional symbolic values (e.g. 1: PRIMARY) emitted instead of integers
+	MissingBirdc     string         `yaml:"missing_birdc" json:"missing_birdc"`           // fail (default) or monitor when birdc_path is not executable
+	BirdSocket       string         `yaml:"bird_socket" json:"bird_socket"`               // Bird control socket (e.g. /run/bird/bird.ctl) used instead of running birdc
+	Template         string         `yaml:"template" json:"template"`                     // Optional text/template file rendered instead of the built-in priorities file
+	LocalPrefMap     []int          `yaml:"localpref_map" json:"localpref_map"`           // Optional local-prefs (in use, withdrawn) emitted instead of priorities
 }
 
 type ExaBGPConfig struct {
-	Enabled bool `yaml:"enabled"` // Use ExaBGP instead of Bird
+	Enabled bool `yaml:"enabled" json:"enabled"` // Use ExaBGP instead of Bird
 }
 
 type LoggingConfig struct {
-	Level           string `yaml:"level"`
-	LogMeasurements bool   `yaml:"log_measurements"`
-	LogDecisions    bool   `yaml:"log_decisions"`
+	Level           string       `yaml:"level" json:"level"`
+	LogMeasurements bool         `yaml:"log_measurements" json:"log_measurements"`
+	LogDecisions    bool         `yaml:"log_decisions" json:"log_decisions"`
+	Syslog          SyslogConfig `yaml:"syslog" json:"syslog"` // Optional structured event sink
+	File            string       `yaml:"file" json:"file"`     // Also append the log here (readable via /api/logs with api.expose_logs)
 }
 
 type ModeConfig struct {
-	DryRun bool `yaml:"dry_run"`
+	DryRun     bool   `yaml:"dry_run" json:"dry_run"`
+	Frozen     bool   `yaml:"frozen" json:"frozen"`           // Start with routing decisions frozen (toggle at runtime via /api/freeze, /api/unfreeze)
+	SinglePeer string `yaml:"single_peer" json:"single_peer"` // With one peer: keep (default) leaves it in use whatever its health, withdraw treats it like any other
 }
 
 type APIConfig struct {
-	Enabled       bool   `yaml:"enabled"`
-	ListenAddress string `yaml:"listen_address"`
+	Enabled              bool   `yaml:"enabled" json:"enabled"`
+	ListenAddress        string `yaml:"listen_address" json:"listen_address"`
+	StatusUpdateInterval int    `yaml:"status_update_interval" json:"status_update_interval"` // Minimum seconds between pushed status updates (default 1)
+	PathPrefix           string `yaml:"path_prefix" json:"path_prefix"`                       // Mount all routes under this path (e.g. "/lagbuster") for reverse proxies
+	RecentEvents         int    `yaml:"recent_events" json:"recent_events"`                   // Events kept in memory for /api/events/recent (default 50)
+	ExposeLogs           bool   `yaml:"expose_logs" json:"expose_logs"`                       // Serve the tail of logging.file at /api/logs
 }
 
 type DatabaseConfig struct {
-	Path          string `yaml:"path"`
-	RetentionDays int    `yaml:"retention_days"`
+	Path                 string        `yaml:"path" json:"path"`
+	RetentionDays        int           `yaml:"retention_days" json:"retention_days"`
+	RecreateOnCorruption bool          `yaml:"recreate_on_corruption" json:"recreate_on_corruption"` // Move an unrepairable database aside and start fresh
+	WriteLagThreshold    int           `yaml:"write_lag_threshold" json:"write_lag_threshold"`       // Milliseconds a measurement write may take before a db_lag event, 0 = 1000
+	Archive              ArchiveConfig `yaml:"archive" json:"archive"`                               // Upload expiring data to S3-compatible storage before cleanup
 }
 
 // Runtime state structures
@@ -101,18 +172,66 @@ type PeerState struct {
 	ConsecutiveUnhealthyCount int
 	ConsecutiveHealthyCount   int
 	IsHealthy                 bool
-	BGPSessionUp              bool   // Whether BGP session is established in Bird
-	BGPSessionState           string // Current BGP session state from Bird
+	BGPSessionUp              bool      // Whether BGP session is established in Bird
+	BGPSessionState           string    // Current BGP session state from Bird
+	LastProbeError            string    // Why the latest probe failed (e.g. "timeout", "dns lookup failed"), empty on success
+	PathAsymmetry             *float64  // Forward minus reverse delay (ms), nil without OWD responder
+	AsymmetryExceeded         bool      // Whether path asymmetry is currently over threshold
+	nextProbe                 time.Time // When this peer is next due for a probe
+	freshSample               bool      // A probe completed since the last health evaluation
+	consecutiveUnreachable    int       // Probes in a row that got no reply, drives probe backoff
+	recoveryDeferred          bool      // Recovery is being held back by the flap check (logged once)
+	warmedUp                  bool      // Warmup probe already discarded (probe.discard_first)
+	resolvedAddrs             string    // Addresses the hostname last resolved to (probe.discard_first)
+	holdUntil                 time.Time // Recovered peer is kept healthy until then unless unreachable (damping.min_active_hold)
+	budgetExceeded            bool      // budget_exceeded fired and the peer has not yet come back within budget
+	CurrentTTL                int       // TTL of the latest reply, 0 if unknown
+	PacketLoss                float64   // Fraction of the latest measurement's echoes that got no reply (damping.probe_count)
+	Jitter                    float64   // Standard deviation of the latest measurement's echo RTTs in ms (damping.probe_count)
+	losses                    []float64 // Packet loss of each sample in Measurements
+	jitters                   []float64 // Jitter of each sample in Measurements
+	ttls                      []int     // Recent reply TTLs (probe.ttl_change_threshold)
+	settledTTL                int       // Median reply TTL last reported, 0 until known
+	samplesTaken              int       // Probes measured since startup (notifications.suppress_during_warmup)
+	SmoothedLatency           float64   // EWMA of answered samples (damping.ewma_alpha), -1 until the first reply
+	baselineLearned           bool      // Config.ExpectedBaseline was learned from history (baseline.mode: adaptive)
+	Disabled                  bool      // Taken out of ECMP by an operator (/api/peers/{name}/disable); still probed and recorded
+
+	// Each port's handshake in the latest tcp probe (probe.ports) and each probe's result
+	// for peers with health_probes, for health_change metadata
+	tcpPorts     []tcpPortResult
+	probeResults []probeResult
+
+	// A recovery confirmed by canary probes waits for the burst, which is sent outside
+	// state.mu by runPendingCanaries and judged by the next decision cycle
+	canaryPending bool
+	canary        *canaryResult
 }
 
 type AppState struct {
-	Config     Config
-	Peers      map[string]*PeerState
-	StartTime  time.Time
-	db         *database.DB
-	notifier   *notifications.Notifier
-	apiServer  *api.Server
-	exabgp     *exabgp.Client // ExaBGP API client (when ExaBGP mode enabled)
+	Config    Config
+	Peers     map[string]*PeerState
+	StartTime time.Time
+	db        *database.DB
+	notifier  *notifications.Notifier
+	apiServer *api.Server
+	exabgp    *exabgp.Client // ExaBGP API client (when ExaBGP mode enabled)
+
+	mu                sync.Mutex     // Serializes the monitoring loop with API-driven peer changes
+	frozen            atomic.Bool    // Kill-switch: hold priorities at last applied values
+	standby           atomic.Bool    // Warm standby: decide but don't apply until promoted (ha.role)
+	appliedPriorities map[string]int // Priorities from the last successful apply
+	probeClassifier   *probeClassifier
+	trace             *decisionTracer            // Decision trace (-trace / -replay)
+	dryRun            *dryRunReport              // Would-be changes, set in dry-run mode
+	syslog            *syslogSink                // Event sink (logging.syslog), nil when disabled
+	referenceStatus   *api.ReferenceQuorumStatus // Latest reference target check, nil without targets
+	dbWriteLag        time.Duration              // Duration of the latest measurement write
+	dbLagging         bool                       // Measurement writes are slow or failing (db_lag raised)
+	recentEvents      *eventRing                 // Latest events for /api/events/recent
+	settling          bool                       // Within startup.settle_delay: routing is not applied yet
+	probeLimiter      *probeLimiter              // Minimum spacing between probes to one target (probe.min_spacing)
+	birdTemplate      *template.Template         // Parsed bird.template, nil to use the built-in generator
 }
 
 // Logger wrapper for structured logging
@@ -150,10 +269,22 @@ var logger *Logger
 
 // Main function
 func main() {
-	configFile := flag.String("config", "config.yaml", "Path to configuration file")
+	configFile := flag.String("config", "config.yaml", "Path to configuration file, or an http(s)://, consul:// or etcd:// source")
 	dryRun := flag.Bool("dry-run", false, "Dry run mode - log decisions without applying changes")
+	owdResponder := flag.String("owd-responder", "", "Run as a one-way delay responder on the given UDP address (e.g. :8623) instead of monitoring")
+	traceFile := flag.String("trace", "", "Append a cycle-by-cycle decision trace (JSON lines) to this file")
+	replayFile := flag.String("replay", "", "Replay a decision trace through the decision logic and verify the recorded outputs, then exit")
+	dumpConfigFlag := flag.Bool("dump-config", false, "Print the effective configuration as a commented template with secrets redacted, then exit")
 	flag.Parse()
 
+	if *owdResponder != "" {
+		logger = NewLogger("info")
+		if err := runOWDResponder(*owdResponder); err != nil {
+			log.Fatalf("OWD responder failed: %v", err)
+		}
+		return
+	}
+
 	// Load configuration
 	config, err := loadConfig(*configFile)
 	if err != nil {
@@ -165,10 +296,30 @@ func main() {
 		config.Mode.DryRun = true
 	}
 
+	if *dumpConfigFlag {
+		if err := dumpConfig(config, os.Stdout); err != nil {
+			log.Fatalf("Failed to dump configuration: %v", err)
+		}
+		return
+	}
+
 	// Initialize logger
 	logger = NewLogger(config.Logging.Level)
+	if err := openLogFile(config.Logging); err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	if *replayFile != "" {
+		if err := runReplay(config, *replayFile); err != nil {
+			log.Fatalf("Replay failed: %v", err)
+		}
+		return
+	}
 
 	logger.Info("Lagbuster starting (version 1.0)")
+	if err := checkBirdc(&config); err != nil {
+		log.Fatalf("%v", err)
+	}
 	if config.Mode.DryRun {
 		logger.Info("Running in DRY-RUN mode - no changes will be applied")
 	}
@@ -176,18 +327,28 @@ func main() {
 	// Initialize database if configured
 	var db *database.DB
 	if config.Database.Path != "" {
-		db, err = database.Open(config.Database.Path)
+		db, err = database.Open(config.Database.Path, config.Database.RecreateOnCorruption)
 		if err != nil {
 			log.Fatalf("Failed to open database: %v", err)
 		}
 		defer db.Close()
 		logger.Info("Database initialized: %s", config.Database.Path)
+		if db.Recovery != "" {
+			logger.Warn("Database: %s", db.Recovery)
+		}
 
 		// Start cleanup goroutine if retention is configured
 		if config.Database.RetentionDays > 0 {
 			go func() {
 				for {
 					time.Sleep(24 * time.Hour)
+					if config.Database.Archive.Enabled {
+						// Expired rows are kept until they are archived
+						if err := archiveAndCleanup(db, config); err != nil {
+							logger.Error("Database archive failed, keeping expired data: %v", err)
+						}
+						continue
+					}
 					if err := db.CleanupOldData(config.Database.RetentionDays); err != nil {
 						logger.Error("Database cleanup failed: %v", err)
 					} else {
@@ -203,25 +364,127 @@ func main() {
 	if config.Notifications.Enabled {
 		channels := notifications.BuildChannels(config.Notifications, logger)
 		notifier = notifications.NewNotifier(channels, config.Notifications.RateLimitMinutes, logger)
-		logger.Info("Notifications initialized with %d channels", len(channels))
 
-		// Send startup notification
-		notifier.Notify(notifications.Event{
-			Type:      notifications.EventStartup,
-			Timestamp: time.Now(),
-		})
+		notifier.SetPeerGroups(peerNotificationGroups(config.Peers))
+		if len(config.Notifications.RateLimits) > 0 {
+			notifier.SetRateLimits(config.Notifications.RateLimits)
+		}
+		if config.Notifications.DigestMinutes > 0 {
+			notifier.SetDigest(config.Notifications.DigestMinutes)
+			logger.Info("Notification digest: events batched every %d minutes", config.Notifications.DigestMinutes)
+		}
+		if config.Notifications.PersistRateLimits {
+			if db == nil {
+				logger.Warn("notifications.persist_rate_limits needs a database - rate limits reset on restart")
+			} else if err := notifier.PersistRateLimits(db); err != nil {
+				logger.Warn("Could not load notification rate limits: %v", err)
+			}
+		}
+		if db != nil {
+			notifier.RecordHistory(db)
+		}
+		logger.Info("Notifications initialized with %d channels", len(channels))
 	}
 
 	// Initialize application state
 	state := initializeState(config)
 	state.db = db
 	state.notifier = notifier
+	resolveMissingBaselines(state)
+
+	if config.Bird.Template != "" && !config.ExaBGP.Enabled {
+		tmpl, err := parseBirdTemplate(config.Bird.Template)
+		if err != nil {
+			log.Fatalf("Failed to load Bird template: %v", err)
+		}
+		state.birdTemplate = tmpl
+		logger.Info("Rendering %s from %s", config.Bird.PrioritiesFile, config.Bird.Template)
+	}
+
+	if config.Logging.Syslog.Enabled {
+		sink, err := openSyslogSink(config.Logging.Syslog)
+		if err != nil {
+			logger.Error("Syslog event sink disabled: %v", err)
+		} else {
+			state.syslog = sink
+			logger.Info("Sending events to syslog")
+		}
+	}
+
+	if config.Mode.DryRun {
+		// Printed on shutdown
+		state.dryRun = newDryRunReport()
+	}
+
+	if *traceFile != "" {
+		tracer, err := openDecisionTrace(*traceFile)
+		if err != nil {
+			log.Fatalf("Failed to open decision trace: %v", err)
+		}
+		state.trace = tracer
+		logger.Info("Writing decision trace to %s", *traceFile)
+	}
 
-	// Initialize API server if configured
-	var apiServer *api.Server
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// SIGINT/SIGTERM cancel ctx, which stops the monitoring loop, the API server and the
+	// background goroutines. A second signal kills the process outright.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		logger.Info("Received %s, shutting down", sig)
+		cancel()
+	}()
+
+	// SIGHUP reloads the configuration in the monitoring loop (see reloadConfig)
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	if config.HA.Role == "standby" {
+		startStandby(ctx, state)
+	}
+
+	if config.Baseline.Mode == baselineAdaptive {
+		if db != nil {
+			go runBaselineLearner(ctx, state)
+		} else {
+			logger.Warn("Adaptive baselines need the database (database.path), using the configured baselines")
+		}
+	}
+
+	if hasLatencyBudgets(config) {
+		if db != nil {
+			go runBudgetEvaluator(ctx, state)
+		} else {
+			logger.Warn("Latency budgets need the database (database.path), not evaluating them")
+		}
+	}
+
+	// Note any downtime since the previous run, then send the startup notification
+	// (suppressed when restarting in a crash loop)
+	recordMonitoringGap(state)
+	sendStartupNotification(state)
+
+	// Surface database repairs made while opening
+	if db != nil && db.Recovery != "" {
+		eventID := recordEvent(state, "db_recovery", nil, nil, nil, db.Recovery, nil)
+		if state.notifier != nil {
+			state.notifier.Notify(notifications.Event{
+				Type:      notifications.EventDatabaseRecovery,
+				Reason:    db.Recovery,
+				Timestamp: time.Now(),
+				EventID:   eventID,
+			})
+		}
+	}
+
+	// Initialize API server if configured
+	var apiServer *api.Server
+	apiStopped := make(chan struct{})
+
 	if config.API.Enabled {
 		// Convert notification event types from EventType to string
 		emailEvents := make([]string, len(config.Notifications.Email.Events))
@@ -236,55 +499,138 @@ func main() {
 		for i, e := range config.Notifications.Telegram.Events {
 			telegramEvents[i] = string(e)
 		}
+		webhookEvents := make([]string, len(config.Notifications.Webhook.Events))
+		for i, e := range config.Notifications.Webhook.Events {
+			webhookEvents[i] = string(e)
+		}
+		templates := func(byType map[notifications.EventType]string) map[string]string {
+			if len(byType) == 0 {
+				return nil
+			}
+			out := make(map[string]string, len(byType))
+			for eventType, tmpl := range byType {
+				out[string(eventType)] = tmpl
+			}
+			return out
+		}
+		rateLimits := make(map[string]int, len(config.Notifications.RateLimits))
+		for eventType, mins := range config.Notifications.RateLimits {
+			rateLimits[string(eventType)] = mins
+		}
+		pagerDutyEvents := make([]string, len(config.Notifications.PagerDuty.Events))
+		for i, e := range config.Notifications.PagerDuty.Events {
+			pagerDutyEvents[i] = string(e)
+		}
 
 		// Create API state wrapper from AppState
 		apiState := &api.AppState{
 			StartTime: state.StartTime,
 			Peers:     make(map[string]*api.PeerState),
 			Config: &api.Config{
-				MeasurementInterval: config.Damping.MeasurementInterval,
+				MeasurementInterval:  config.Damping.MeasurementInterval,
+				MeasurementWindow:    config.Damping.MeasurementWindow,
+				EWMAAlpha:            ewmaAlpha(config.Damping),
+				StatusUpdateInterval: config.API.StatusUpdateInterval,
+				PathPrefix:           config.API.PathPrefix,
+				LogFile:              exposedLogFile(config),
 				Notifications: api.NotificationConfig{
-					Enabled:          config.Notifications.Enabled,
-					RateLimitMinutes: config.Notifications.RateLimitMinutes,
+					Enabled:                        config.Notifications.Enabled,
+					RateLimitMinutes:               config.Notifications.RateLimitMinutes,
+					RateLimits:                     rateLimits,
+					SuppressStartupIfRestartWithin: config.Notifications.SuppressStartupIfRestartWithin,
+					SuppressDuringWarmup:           config.Notifications.SuppressDuringWarmup,
+					PersistRateLimits:              config.Notifications.PersistRateLimits,
+					Timezone:                       config.Notifications.Timezone,
+					TimeFormat:                     config.Notifications.TimeFormat,
+					DigestMinutes:                  config.Notifications.DigestMinutes,
 					Email: api.EmailConfig{
-						Enabled:    config.Notifications.Email.Enabled,
-						SMTPHost:   config.Notifications.Email.SMTPHost,
-						SMTPPort:   config.Notifications.Email.SMTPPort,
-						Username:   config.Notifications.Email.Username,
-						Password:   config.Notifications.Email.Password,
-						From:       config.Notifications.Email.From,
-						To:         config.Notifications.Email.To,
-						EventTypes: emailEvents,
+						Enabled:          config.Notifications.Email.Enabled,
+						SMTPHost:         config.Notifications.Email.SMTPHost,
+						SMTPPort:         config.Notifications.Email.SMTPPort,
+						Username:         config.Notifications.Email.Username,
+						Password:         config.Notifications.Email.Password,
+						From:             config.Notifications.Email.From,
+						To:               config.Notifications.Email.To,
+						EventTypes:       emailEvents,
+						Groups:           config.Notifications.Email.Groups,
+						Templates:        templates(config.Notifications.Email.Templates),
+						SubjectTemplates: templates(config.Notifications.Email.SubjectTemplates),
 					},
 					Slack: api.SlackConfig{
 						Enabled:    config.Notifications.Slack.Enabled,
 						WebhookURL: config.Notifications.Slack.WebhookURL,
 						EventTypes: slackEvents,
+						Groups:     config.Notifications.Slack.Groups,
+						Templates:  templates(config.Notifications.Slack.Templates),
 					},
 					Telegram: api.TelegramConfig{
 						Enabled:    config.Notifications.Telegram.Enabled,
 						BotToken:   config.Notifications.Telegram.BotToken,
 						ChatID:     config.Notifications.Telegram.ChatID,
 						EventTypes: telegramEvents,
+						Groups:     config.Notifications.Telegram.Groups,
+						Templates:  templates(config.Notifications.Telegram.Templates),
+					},
+					Webhook: api.WebhookConfig{
+						Enabled:    config.Notifications.Webhook.Enabled,
+						URL:        config.Notifications.Webhook.URL,
+						Headers:    config.Notifications.Webhook.Headers,
+						EventTypes: webhookEvents,
+						Groups:     config.Notifications.Webhook.Groups,
+					},
+					PagerDuty: api.PagerDutyConfig{
+						Enabled:    config.Notifications.PagerDuty.Enabled,
+						RoutingKey: config.Notifications.PagerDuty.RoutingKey,
+						Source:     config.Notifications.PagerDuty.Source,
+						EventTypes: pagerDutyEvents,
+						Groups:     config.Notifications.PagerDuty.Groups,
 					},
 				},
 			},
 			Notifier:   notifier,
-			ConfigPath: *configFile,
+			ConfigPath: localConfigPath(*configFile),
+			Frozen:     config.Mode.Frozen,
+			SetFrozen: func(frozen bool) {
+				state.mu.Lock()
+				defer state.mu.Unlock()
+				setFrozen(state, frozen, "API")
+			},
+			ResetPeer: func(name string, force bool) error {
+				return resetPeer(state, name, force)
+			},
+			SetPeerDisabled: func(name string, disabled bool) error {
+				return setPeerDisabled(state, name, disabled)
+			},
+			Priorities: func() interface{} {
+				return priorityReport(state)
+			},
+			Explain: func() interface{} {
+				return explainDecision(state)
+			},
+			RecentEvents: state.recentEvents.recent,
+		}
+		if state.dryRun != nil {
+			apiState.DryRunReport = func() interface{} {
+				return state.dryRun.snapshot()
+			}
 		}
 
 		// Convert peer states
 		for name, peer := range state.Peers {
+			baseline, baselineWindow := activeBaseline(peer.Config, time.Now())
 			apiState.Peers[name] = &api.PeerState{
 				Name:                      peer.Config.Name,
 				Hostname:                  peer.Config.Hostname,
-				Baseline:                  peer.Config.ExpectedBaseline,
+				Baseline:                  baseline,
+				BaselineWindow:            baselineWindow,
 				CurrentLatency:            peer.CurrentLatency,
 				IsHealthy:                 peer.IsHealthy,
 				ConsecutiveHealthyCount:   peer.ConsecutiveHealthyCount,
 				ConsecutiveUnhealthyCount: peer.ConsecutiveUnhealthyCount,
 				BGPSessionUp:              peer.BGPSessionUp,
 				BGPSessionState:           peer.BGPSessionState,
+				Disabled:                  peer.Disabled,
+				MeasurementInterval:       int(peerMeasurementInterval(config, peer.Config).Seconds()),
 			}
 		}
 
@@ -292,52 +638,225 @@ func main() {
 		state.apiServer = apiServer
 
 		go func() {
-			logger.Info("Starting API server on %s", config.API.ListenAddress)
+			defer close(apiStopped)
+			logger.Info("Starting API server on %s%s", config.API.ListenAddress, api.NormalizePathPrefix(config.API.PathPrefix))
 			if err := apiServer.Start(ctx, config.API.ListenAddress); err != nil {
 				logger.Error("API server error: %v", err)
 			}
 		}()
+	} else {
+		close(apiStopped)
 	}
 
 	// Startup grace period
 	logger.Info("Startup grace period: %d seconds", config.Startup.GracePeriod)
-	time.Sleep(time.Duration(config.Startup.GracePeriod) * time.Second)
+	select {
+	case <-ctx.Done():
+		shutdown(state, apiStopped)
+		return
+	case <-time.After(time.Duration(config.Startup.GracePeriod) * time.Second):
+	}
 
-	// Main monitoring loop
-	ticker := time.NewTicker(time.Duration(config.Damping.MeasurementInterval) * time.Second)
+	// Main monitoring loop: the scheduler ticks often enough to serve every peer's
+	// interval, while health decisions keep the global measurement_interval cadence
+	decisionInterval := time.Duration(config.Damping.MeasurementInterval) * time.Second
+	tick := schedulerTick(config)
+	for _, peer := range config.Peers {
+		if peer.MeasurementInterval > 0 {
+			logger.Info("Peer %s probed every %ds (decisions every %s)", peer.Name, peer.MeasurementInterval, decisionInterval)
+		}
+	}
+	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
 
-	// Run first measurement immediately
+	// Run first measurement immediately. Its decision cycle applies the initial routing,
+	// or when settling the one at the end of settleBeforeFirstApply does.
+	state.settling = config.Startup.SettleDelay > 0
 	runMonitoringCycle(state)
 
-	// Apply initial Bird configuration based on first measurement
-	logger.Info("Applying initial Bird configuration (asymmetric routing mode)")
-	if !config.Mode.DryRun {
-		err := applyBirdConfiguration(state)
+	if !settleBeforeFirstApply(ctx, state, ticker, tick) {
+		shutdown(state, apiStopped)
+		return
+	}
+
+	nextDecision := time.Now().Add(decisionInterval)
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			shutdown(state, apiStopped)
+			return
+		case <-hangups:
+			logger.Info("Received SIGHUP, reloading configuration from %s", *configFile)
+			intervalsChanged := reloadConfig(state, *configFile)
+			if intervalsChanged {
+				decisionInterval = time.Duration(state.Config.Damping.MeasurementInterval) * time.Second
+				tick = schedulerTick(state.Config)
+				ticker.Reset(tick)
+				nextDecision = time.Now().Add(decisionInterval)
+				logger.Info("Probe intervals changed: scheduler now ticks every %s, decisions every %s", tick, decisionInterval)
+			}
+			continue
+		case now = <-ticker.C:
+		}
+
+		probeDuePeers(state, now, tick)
+		if !now.Add(tick / 2).Before(nextDecision) {
+			nextDecision = nextDecision.Add(decisionInterval)
+			references := pingReferences(state)
+			state.mu.Lock()
+			runDecisionCycle(state, references)
+			state.mu.Unlock()
+		}
+		runPendingCanaries(state)
+	}
+}
+
+// shutdown winds the service down once the main context is cancelled: it records the
+// stop, sends the shutdown notification (Notify returns once every channel has been
+// tried), prints the dry-run report and waits for the API server to finish its open
+// requests. main's deferred db.Close runs after it returns.
+func shutdown(state *AppState, apiStopped <-chan struct{}) {
+	state.mu.Lock()
+	eventID := recordEvent(state, "shutdown", nil, nil, nil, "lagbuster stopped", nil)
+	if state.notifier != nil {
+		state.notifier.Notify(notifications.Event{
+			Type:      notifications.EventShutdown,
+			Timestamp: time.Now(),
+			EventID:   eventID,
+		})
+		state.notifier.FlushDigest()
+	}
+	if state.dryRun != nil {
+		state.dryRun.logSummary()
+	}
+	state.mu.Unlock()
+
+	<-apiStopped
+	logger.Info("Lagbuster stopped")
+}
+
+// settleBeforeFirstApply keeps probing for startup.settle_delay after the first
+// measurement so routing isn't touched while the network is still converging at boot,
+// then runs the first real decision cycle. An unreachable peer ends the wait early:
+// routing away from it shouldn't be delayed. It returns false, without deciding, when
+// the context is cancelled (SIGINT/SIGTERM) during the wait.
+func settleBeforeFirstApply(ctx context.Context, state *AppState, ticker *time.Ticker, tick time.Duration) bool {
+	if !state.settling {
+		return true
+	}
+
+	delay := time.Duration(state.Config.Startup.SettleDelay) * time.Second
+	logger.Info("Settling for %s before the first routing change", delay)
+	deadline := time.Now().Add(delay)
+	for {
+		state.mu.Lock()
+		unreachable := ""
+		for name, peer := range state.Peers {
+			if peer.CurrentLatency < 0 {
+				unreachable = name
+				break
+			}
+		}
+		state.mu.Unlock()
+
+		if unreachable != "" {
+			logger.Warn("Peer %s is unreachable, ending settle delay early", unreachable)
+			break
+		}
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return false
+		case now = <-ticker.C:
+		}
+		if !now.Before(deadline) {
+			break
+		}
+		probeDuePeers(state, now, tick)
+	}
+
+	references := pingReferences(state)
+	state.mu.Lock()
+	state.settling = false
+	runDecisionCycle(state, references)
+	state.mu.Unlock()
+	return true
+}
+
+// sendStartupNotification notifies that the service started, unless the previous run
+// recorded a measurement within notifications.suppress_startup_if_restart_within minutes.
+// A quick restart is recorded as a single "restart" event instead, so deploys and crash
+// loops don't page anyone while genuine first starts still do.
+func sendStartupNotification(state *AppState) {
+	if state.notifier == nil {
+		return
+	}
+
+	window := time.Duration(state.Config.Notifications.SuppressStartupIfRestartWithin) * time.Minute
+	if window > 0 && state.db != nil {
+		lastSeen, found, err := state.db.LastMeasurementTime()
 		if err != nil {
-			logger.Error("Failed to apply initial Bird configuration: %v", err)
-		} else {
-			logger.Info("Initial Bird configuration applied successfully")
+			logger.Warn("Could not determine previous run time: %v", err)
+		} else if found && time.Since(lastSeen) < window {
+			downtime := time.Since(lastSeen).Round(time.Second)
+			logger.Info("Restarted %s after previous run, suppressing startup notification", downtime)
+			recordEvent(state, "restart", nil, nil, nil, fmt.Sprintf("restarted %s after previous run", downtime), nil)
+			return
 		}
-	} else {
-		logger.Info("DRY-RUN: Would apply initial Bird configuration")
 	}
 
-	for range ticker.C {
-		runMonitoringCycle(state)
+	state.notifier.Notify(notifications.Event{
+		Type:      notifications.EventStartup,
+		Timestamp: time.Now(),
+	})
+}
+
+// monitoringGapIntervals is how many measurement intervals without samples count as a gap
+const monitoringGapIntervals = 3
+
+// recordMonitoringGap records a monitoring_gap event when the previous run's last
+// measurement is older than a few measurement intervals, capturing the downtime
+func recordMonitoringGap(state *AppState) {
+	if state.db == nil {
+		return
+	}
+
+	lastSeen, found, err := state.db.LastMeasurementTime()
+	if err != nil {
+		logger.Warn("Could not determine previous run time: %v", err)
+		return
 	}
+	if !found {
+		return
+	}
+
+	downtime := time.Since(lastSeen)
+	if downtime < monitoringGapIntervals*time.Duration(state.Config.Damping.MeasurementInterval)*time.Second {
+		return
+	}
+
+	downtime = downtime.Round(time.Second)
+	logger.Info("No measurements for %s before this start (last at %s)", downtime, lastSeen.Format(time.RFC3339))
+
+	metadata := fmt.Sprintf(`{"last_measurement":%q,"downtime_seconds":%d}`, lastSeen.Format(time.RFC3339), int64(downtime.Seconds()))
+	recordEvent(state, "monitoring_gap", nil, nil, nil, fmt.Sprintf("monitoring gap of %s before startup", downtime), &metadata)
 }
 
-// Load configuration from YAML file
+// Load configuration from a YAML or JSON file (chosen by extension)
 func loadConfig(filename string) (Config, error) {
 	var config Config
 
-	data, err := os.ReadFile(filename)
+	data, err := readConfigSource(filename)
 	if err != nil {
 		return config, fmt.Errorf("reading config file: %w", err)
 	}
 
-	err = yaml.Unmarshal(data, &config)
+	if isJSONConfig(configSourceName(filename)) {
+		err = json.Unmarshal(data, &config)
+	} else {
+		err = yaml.Unmarshal(data, &config)
+	}
 	if err != nil {
 		return config, fmt.Errorf("parsing config file: %w", err)
 	}
@@ -347,9 +866,154 @@ func loadConfig(filename string) (Config, error) {
 		return config, fmt.Errorf("no peers defined in configuration")
 	}
 
+	if config.Damping.MeasurementInterval <= 0 {
+		return config, fmt.Errorf("damping.measurement_interval must be positive")
+	}
+	for _, peer := range config.Peers {
+		if peer.MeasurementInterval < 0 {
+			return config, fmt.Errorf("peer %s: measurement_interval must not be negative", peer.Name)
+		}
+		// Damping counts samples, so a peer slower than the decision cadence takes
+		// proportionally longer to change state; cap it at one measurement window
+		if maxInterval := config.Damping.MeasurementInterval * config.Damping.MeasurementWindow; maxInterval > 0 && peer.MeasurementInterval > maxInterval {
+			return config, fmt.Errorf("peer %s: measurement_interval %ds exceeds one measurement window of decisions (%ds)",
+				peer.Name, peer.MeasurementInterval, maxInterval)
+		}
+	}
+
+	switch config.Bird.MissingBirdc {
+	case "", "fail", "monitor":
+	default:
+		return config, fmt.Errorf("bird.missing_birdc must be fail or monitor, got %q", config.Bird.MissingBirdc)
+	}
+
+	for priority, value := range config.Bird.PriorityValueMap {
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, ";#\n") {
+			return config, fmt.Errorf("bird.priority_value_map: invalid value %q for priority %d", value, priority)
+		}
+	}
+
+	if err := validateLocalPrefMap(config.Bird); err != nil {
+		return config, err
+	}
+
+	if err := validateBirdTemplate(config.Bird); err != nil {
+		return config, err
+	}
+
+	if err := validateBirdVariables(config); err != nil {
+		return config, err
+	}
+
+	if err := validateHealthRules(config); err != nil {
+		return config, err
+	}
+
+	if err := validateHysteresis(config.Thresholds); err != nil {
+		return config, err
+	}
+
+	if err := validateHealthWeighting(config.Damping); err != nil {
+		return config, err
+	}
+
+	if err := validateBaselines(config); err != nil {
+		return config, err
+	}
+
+	if err := validateReferences(config.Reference); err != nil {
+		return config, err
+	}
+
+	if err := validateHA(config.HA, config.Damping); err != nil {
+		return config, err
+	}
+
+	if err := validateLatencyBudgets(config); err != nil {
+		return config, err
+	}
+
+	if err := validateTimeBaselines(config); err != nil {
+		return config, err
+	}
+
+	if err := validateBaselineMode(config.Baseline); err != nil {
+		return config, err
+	}
+
+	if err := validateArchive(config); err != nil {
+		return config, err
+	}
+
+	if config.Probe.MinSpacing < 0 {
+		return config, fmt.Errorf("probe.min_spacing must not be negative")
+	}
+	for _, peer := range config.Peers {
+		if peer.ProbeMinSpacing < 0 {
+			return config, fmt.Errorf("peer %s: probe_min_spacing must not be negative", peer.Name)
+		}
+	}
+
+	if _, err := newProbeClassifier(config.Probe); err != nil {
+		return config, err
+	}
+
+	if err := validateIPv6OnlyHosts(config.Probe); err != nil {
+		return config, err
+	}
+
+	if err := validateTTL(config.Probe); err != nil {
+		return config, err
+	}
+
+	if err := validateProbeMethods(config); err != nil {
+		return config, err
+	}
+	if err := validateConsensus(config); err != nil {
+		return config, err
+	}
+
+	if err := validateProbeCount(config.Damping); err != nil {
+		return config, err
+	}
+
+	if err := validateHealthMetric(config.Damping); err != nil {
+		return config, err
+	}
+
+	if err := notifications.ValidateTemplates(config.Notifications); err != nil {
+		return config, err
+	}
+
+	if err := validateSinglePeer(config.Mode); err != nil {
+		return config, err
+	}
+
+	if config.API.ExposeLogs && config.Logging.File == "" {
+		return config, fmt.Errorf("api.expose_logs needs logging.file")
+	}
+
+	if _, err := notifications.NewTimeFormatter(config.Notifications.Timezone, config.Notifications.TimeFormat); err != nil {
+		return config, err
+	}
+
 	return config, nil
 }
 
+// localConfigPath is where settings changed through the API are saved: the config file,
+// or nowhere when the config comes from a remote source
+func localConfigPath(source string) string {
+	if isRemoteConfig(source) {
+		return ""
+	}
+	return source
+}
+
+// isJSONConfig reports whether a config path should be read as JSON rather than YAML
+func isJSONConfig(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".json")
+}
+
 // Initialize application state
 func initializeState(config Config) *AppState {
 	state := &AppState{
@@ -357,17 +1021,34 @@ func initializeState(config Config) *AppState {
 		Peers:     make(map[string]*PeerState),
 		StartTime: time.Now(),
 	}
+	state.recentEvents = newEventRing(config.API.RecentEvents)
+	state.probeLimiter = newProbeLimiter()
+
+	// Patterns were already validated by loadConfig
+	state.probeClassifier, _ = newProbeClassifier(config.Probe)
 
 	// Initialize peer states (all start as healthy by default, will be evaluated on first cycle)
 	for _, peerConfig := range config.Peers {
-		state.Peers[peerConfig.Name] = &PeerState{
-			Config:       peerConfig,
-			Measurements: make([]float64, 0, config.Damping.MeasurementWindow),
-			IsHealthy:    true, // Assume healthy until first measurement
+		if spacing, interval := probeMinSpacing(config, peerConfig), peerMeasurementInterval(config, peerConfig); spacing > interval {
+			logger.Warn("Peer %s: probe min spacing %s is longer than its measurement interval %s, so some probes will be rejected",
+				peerConfig.Name, spacing, interval)
+		}
+		if worst, interval := probeCountDuration(config, peerConfig), peerMeasurementInterval(config, peerConfig); worst > interval {
+			logger.Warn("Peer %s: %d echoes per measurement can take up to %s, longer than its measurement interval %s",
+				peerConfig.Name, config.Damping.ProbeCount, worst, interval)
 		}
+		state.Peers[peerConfig.Name] = newPeerState(peerConfig, config)
 	}
 
 	logger.Info("Initialized with %d peers in asymmetric routing mode (ECMP)", len(state.Peers))
+	if singlePeerMode(state) {
+		logger.Info("Single peer, no failover possible: its health is still monitored and notified, but routing only follows its BGP session (mode.single_peer)")
+	}
+
+	if config.Mode.Frozen {
+		state.frozen.Store(true)
+		logger.Warn("Starting with routing decisions FROZEN (mode.frozen) - priorities will be held after the initial apply")
+	}
 
 	// Initialize ExaBGP client if enabled
 	if config.ExaBGP.Enabled {
@@ -378,49 +1059,311 @@ func initializeState(config Config) *AppState {
 	return state
 }
 
-// Run one monitoring cycle
+// Run one monitoring cycle: probe every peer, then evaluate and apply. The caller
+// must not hold state.mu; it is taken to record and decide.
 func runMonitoringCycle(state *AppState) {
-	// Measure latency and BGP session status for all peers
+	state.mu.Lock()
+	peers := make([]*PeerState, 0, len(state.Peers))
 	for _, peer := range state.Peers {
-		latency := pingHost(peer.Config.Hostname)
-		peer.CurrentLatency = latency
-
-		// Check BGP session status
-		// In ExaBGP mode, assume sessions are up (ExaBGP manages them directly)
-		// In Bird mode, check session status via birdc
-		if state.Config.ExaBGP.Enabled {
-			peer.BGPSessionUp = true
-			peer.BGPSessionState = "Established"
-		} else {
-			bgpUp, bgpState := checkBGPSession(peer.Config, state.Config.Bird)
-			peer.BGPSessionUp = bgpUp
-			peer.BGPSessionState = bgpState
+		peers = append(peers, peer)
+	}
+	state.mu.Unlock()
+
+	measurePeers(state, peers)
+	references := pingReferences(state)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, peer := range peers {
+		peer.nextProbe = time.Now().Add(nextProbeDelay(state.Config, peer))
+	}
+	runDecisionCycle(state, references)
+}
+
+// probeDuePeers measures each peer whose own measurement interval has elapsed.
+// Ticks arrive slightly late, so anything due within half a tick counts as due.
+// The caller must not hold state.mu.
+func probeDuePeers(state *AppState, now time.Time, tick time.Duration) {
+	state.mu.Lock()
+	var due []*PeerState
+	for _, peer := range state.Peers {
+		if now.Add(tick / 2).Before(peer.nextProbe) {
+			continue
+		}
+		due = append(due, peer)
+	}
+	state.mu.Unlock()
+
+	measurePeers(state, due)
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, peer := range due {
+		peer.nextProbe = now.Add(nextProbeDelay(state.Config, peer))
+	}
+}
+
+// nextProbeDelay is the peer's measurement interval, doubled for every unreachable probe
+// beyond damping.unreachable_backoff_after once the peer is unhealthy, up to
+// damping.unreachable_backoff_max. A single reply resets it.
+func nextProbeDelay(config Config, peer *PeerState) time.Duration {
+	interval := peerMeasurementInterval(config, peer.Config)
+
+	after := config.Damping.UnreachableBackoffAfter
+	if after <= 0 || peer.IsHealthy || peer.consecutiveUnreachable < after {
+		return interval
+	}
+
+	maxInterval := time.Duration(config.Damping.UnreachableBackoffMax) * time.Second
+	if maxInterval <= interval {
+		return interval
+	}
+
+	delay := interval
+	for i := after; i <= peer.consecutiveUnreachable && delay < maxInterval; i++ {
+		delay *= 2
+	}
+	if delay > maxInterval {
+		delay = maxInterval
+	}
+	return delay
+}
+
+// peerMeasurementInterval returns how often a peer is probed
+func peerMeasurementInterval(config Config, peer PeerConfig) time.Duration {
+	if peer.MeasurementInterval > 0 {
+		return time.Duration(peer.MeasurementInterval) * time.Second
+	}
+	return time.Duration(config.Damping.MeasurementInterval) * time.Second
+}
+
+// newPeerState returns the runtime state for a peer that has not been measured yet
+func newPeerState(peerConfig PeerConfig, config Config) *PeerState {
+	return &PeerState{
+		Config:          peerConfig,
+		Measurements:    make([]float64, 0, config.Damping.MeasurementWindow),
+		IsHealthy:       true, // Assume healthy until first measurement
+		SmoothedLatency: -1,
+	}
+}
+
+// schedulerTick is the greatest common divisor of all probe intervals and the
+// decision interval, so every deadline falls on a tick
+func schedulerTick(config Config) time.Duration {
+	tick := config.Damping.MeasurementInterval
+	for _, peer := range config.Peers {
+		interval := peer.MeasurementInterval
+		for interval > 0 {
+			tick, interval = interval, tick%interval
 		}
+	}
+	return time.Duration(tick) * time.Second
+}
+
+// peerProbe is what one peer's probes returned, before any of it is applied
+type peerProbe struct {
+	latency  float64
+	ttl      int
+	quality  sampleQuality
+	probeErr string
+	bgpUp    bool
+	bgpState string
+	owd      OWDResult
+	owdErr   error
+}
+
+// measurePeers probes the given peers concurrently, so a cycle takes about as long as
+// its slowest probe rather than the sum of them all, then records the results one peer
+// at a time. The probes run without state.mu, on copies of the config taken under it,
+// so the API and background goroutines aren't held up by them; they only touch their
+// own peer's warmup state. Everything shared (events, notifications, the database) is
+// written from the calling goroutine once state.mu is taken again. The caller must not
+// hold state.mu.
+func measurePeers(state *AppState, peers []*PeerState) {
+	state.mu.Lock()
+	config := state.Config
+	peerConfigs := make([]PeerConfig, len(peers))
+	for i, peer := range peers {
+		peerConfigs[i] = peer.Config
+	}
+	state.mu.Unlock()
 
-		// Add to measurement window
-		peer.Measurements = append(peer.Measurements, latency)
-		if len(peer.Measurements) > state.Config.Damping.MeasurementWindow {
-			peer.Measurements = peer.Measurements[1:]
+	probes := make([]*peerProbe, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probes[i] = probePeer(state, config, peer, peerConfigs[i])
+		}()
+	}
+	wg.Wait()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for i, peer := range peers {
+		if probes[i] != nil {
+			recordProbe(state, peer, probes[i])
 		}
+	}
+}
 
-		if state.Config.Logging.LogMeasurements {
-			logger.Debug("Peer %s: latency=%.2fms, baseline=%.2fms, BGP=%s",
-				peer.Config.Name, latency, peer.Config.ExpectedBaseline, peer.BGPSessionState)
+// probePeer probes latency and BGP session status for one peer, using the config copied
+// by measurePeers. It returns nil when the probe spacing doesn't allow a probe yet.
+func probePeer(state *AppState, config Config, peer *PeerState, peerConfig PeerConfig) *peerProbe {
+	if !allowPeerProbe(state, config, peerConfig, "probe") {
+		return nil
+	}
+	refreshWarmup(config.Probe, peer)
+	probe := &peerProbe{}
+	probe.latency, probe.ttl, probe.quality, probe.probeErr = measureLatency(state, config, peerConfig)
+	if discardWarmupProbe(config.Probe, peer, probe.latency) {
+		// Probe again straight away so the cycle still gets a sample, unless that would
+		// break the probe spacing - then the warmup sample has to do
+		if allowPeerProbe(state, config, peerConfig, "warmup re-probe") {
+			probe.latency, probe.ttl, probe.quality, probe.probeErr = measureLatency(state, config, peerConfig)
 		}
+	}
 
-		// Record measurement to database
-		if state.db != nil {
-			if err := state.db.RecordMeasurement(peer.Config.Name, latency, peer.IsHealthy, false); err != nil {
-				logger.Error("Failed to record measurement for %s: %v", peer.Config.Name, err)
-			}
+	// Check BGP session status
+	// In ExaBGP mode, assume sessions are up (ExaBGP manages them directly)
+	// In Bird mode, check session status via birdc
+	if config.ExaBGP.Enabled {
+		probe.bgpUp, probe.bgpState = true, "Established"
+	} else {
+		probe.bgpUp, probe.bgpState = checkBGPSession(peerConfig, config.Bird)
+	}
+
+	// Optional one-way delay probe (isolated from the ICMP health path)
+	if peerConfig.OWDResponder != "" {
+		probe.owd, probe.owdErr = measureOWD(peerConfig.OWDResponder)
+	}
+	return probe
+}
+
+// recordProbe applies one peer's probe results to its state and records the measurement
+func recordProbe(state *AppState, peer *PeerState, probe *peerProbe) {
+	latency, ttl, quality := probe.latency, probe.ttl, probe.quality
+	peer.CurrentLatency = latency
+	peer.CurrentTTL = ttl
+	peer.PacketLoss = quality.PacketLoss
+	peer.Jitter = quality.Jitter
+	peer.tcpPorts = quality.Ports
+	peer.probeResults = quality.Probes
+	peer.samplesTaken++
+	peer.LastProbeError = probe.probeErr
+	trackTTL(state, peer, ttl)
+	// Track unreachable streaks for probe backoff (see nextProbeDelay)
+	backoffAfter := state.Config.Damping.UnreachableBackoffAfter
+	backingOff := backoffAfter > 0 && !peer.IsHealthy && peer.consecutiveUnreachable >= backoffAfter
+	if latency < 0 {
+		peer.consecutiveUnreachable++
+		if !backingOff && backoffAfter > 0 && !peer.IsHealthy && peer.consecutiveUnreachable >= backoffAfter {
+			logger.Info("Peer %s unreachable for %d probes, backing off probe interval (max %ds)",
+				peer.Config.Name, peer.consecutiveUnreachable, state.Config.Damping.UnreachableBackoffMax)
+		}
+	} else {
+		if backingOff {
+			logger.Info("Peer %s is responding again, resuming normal probe interval", peer.Config.Name)
 		}
+		peer.consecutiveUnreachable = 0
 	}
 
-	// Evaluate health of all peers (with damping)
-	evaluatePeerHealth(state)
+	peer.BGPSessionUp = probe.bgpUp
+	peer.BGPSessionState = probe.bgpState
+	if peer.Config.OWDResponder != "" {
+		checkPathAsymmetry(state, peer, probe.owd, probe.owdErr)
+	}
+
+	// Add to measurement window
+	addSample(peer, latency, quality, state.Config.Damping.MeasurementWindow)
+	updateSmoothedLatency(state.Config.Damping, peer)
 
-	// Apply routing configuration based on mode
-	if state.Config.ExaBGP.Enabled {
+	if state.Config.Logging.LogMeasurements {
+		logger.Debug("Peer %s: latency=%.2fms, loss=%.0f%%, jitter=%.2fms, baseline=%.2fms, BGP=%s",
+			peer.Config.Name, latency, quality.PacketLoss*100, quality.Jitter, peerBaseline(peer.Config), peer.BGPSessionState)
+	}
+
+	// Record measurement to database
+	if state.db != nil {
+		writeStart := time.Now()
+		err := state.db.RecordMeasurement(peer.Config.Name, latency, ttl, quality.PacketLoss, quality.Jitter, peer.IsHealthy, false, operationalState(state))
+		if err != nil {
+			logger.Error("Failed to record measurement for %s: %v", peer.Config.Name, err)
+		}
+		trackDBWrite(state, time.Since(writeStart), err)
+	}
+
+	peer.freshSample = true
+}
+
+// addSample appends a measurement to the peer's rolling window
+func addSample(peer *PeerState, latency float64, quality sampleQuality, window int) {
+	peer.Measurements = append(peer.Measurements, latency)
+	peer.losses = append(peer.losses, quality.PacketLoss)
+	peer.jitters = append(peer.jitters, quality.Jitter)
+	// More than one sample can fall out when the window shrank on a config reload
+	if excess := len(peer.Measurements) - window; excess > 0 {
+		peer.Measurements = peer.Measurements[excess:]
+		peer.losses = peer.losses[excess:]
+		peer.jitters = peer.jitters[excess:]
+	}
+}
+
+// runDecisionCycle evaluates peer health from the latest measurements and applies routing.
+// references are the reference target replies from pingReferences. The caller holds state.mu.
+func runDecisionCycle(state *AppState, references []bool) {
+	if state.trace != nil {
+		state.trace.begin(state)
+	}
+
+	// Evaluate health of all peers (with damping), unless the reference targets say the
+	// local network itself is down - then every peer looks bad and acting would be wrong
+	if checkReferenceQuorum(state, references) {
+		evaluatePeerHealth(state)
+	} else {
+		for _, peer := range state.Peers {
+			peer.freshSample = false
+		}
+		if state.trace != nil {
+			state.trace.hold()
+		}
+	}
+
+	if state.trace != nil {
+		state.trace.end(state, priorityAssignment(state))
+	}
+
+	applyRouting(state)
+
+	// Update API server state
+	updateAPIServerState(state)
+	if state.apiServer != nil {
+		state.apiServer.BroadcastHeartbeat()
+	}
+}
+
+// applyRouting applies the current priorities the way the mode calls for: not at all in
+// standby or while settling, as a recorded would-be change in dry-run, otherwise to
+// ExaBGP or Bird. The caller holds state.mu.
+func applyRouting(state *AppState) {
+	if state.standby.Load() {
+		// Standby: the active instance owns routing until this one is promoted
+		logger.Debug("STANDBY: Not applying priorities %v", priorityAssignment(state))
+	} else if state.settling {
+		// Startup settle delay: decide, but leave routing alone until it ends
+		logger.Debug("SETTLING: Not applying priorities %v", priorityAssignment(state))
+	} else if state.dryRun != nil {
+		// Dry-run: record what would change instead of touching Bird/ExaBGP
+		priorities := priorityAssignment(state)
+		if changed := state.dryRun.record(state, state.appliedPriorities, priorities); changed > 0 {
+			logger.Info("DRY-RUN: Would apply new priorities (%d peers changed): %v", changed, priorities)
+			if !state.Config.ExaBGP.Enabled {
+				logBirdConfigDiff(state, priorities)
+			}
+		}
+		state.appliedPriorities = priorities
+	} else if state.Config.ExaBGP.Enabled {
 		// ExaBGP mode: API-driven route announcements
 		if err := applyExaBGPConfiguration(state); err != nil {
 			logger.Error("Failed to apply ExaBGP configuration: %v", err)
@@ -431,58 +1374,139 @@ func runMonitoringCycle(state *AppState) {
 			logger.Error("Failed to apply Bird configuration: %v", err)
 		}
 	}
-
-	// Update API server state
-	updateAPIServerState(state)
 }
 
-// Ping a host and return latency in milliseconds
+// Ping a host and return latency in milliseconds, or -1 and the reason the probe failed
 // Supports both IPv4 and IPv6 addresses
 // Uses context-based timeout to prevent hanging on unreachable hosts
-func pingHost(host string) float64 {
+func pingHost(host string, probe ProbeConfig, classifier *probeClassifier) (float64, string) {
+	latency, _, _, probeErr := probeHost(host, probe, classifier)
+	return latency, probeErr
+}
+
+// probeHost pings a host like pingHost and also returns the TTL (hop limit) of the
+// reply, 0 when the output doesn't show one, and for tcp probes the result of each port
+func probeHost(host string, probe ProbeConfig, classifier *probeClassifier) (float64, int, []tcpPortResult, string) {
 	// Create context with 5-second timeout (safety margin above ping's 3s timeout)
 	// This ensures the command will be killed even if DNS hangs or ping doesn't timeout properly
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var cmd *exec.Cmd
+	// HTTP probes go to the peer's URL rather than its hostname
+	if probe.Method == probeMethodHTTP {
+		latency, probeErr := httpPing(ctx, probe.URL)
+		return latency, 0, nil, probeErr
+	}
+
+	// IPv6 needs an explicit address family: macOS ping is IPv4-only and some Linux ping
+	// builds don't fall back to AAAA records, which otherwise looks like a timeout
+	useIPv6 := probeOverIPv6(ctx, host, probe.Family)
+	if useIPv6 && probe.Family == "" && probe.IPv6OnlyHosts == ipv6OnlyError {
+		logger.Debug("Not pinging %s: %s", host, probeErrIPv6Only)
+		return -1, 0, nil, probeErrIPv6Only
+	}
+
+	if probe.Method == probeMethodTCP {
+		latency, ports, probeErr := tcpPing(ctx, host, useIPv6, tcpProbePorts(probe), probe.PortQuorum, probe.SourcePort)
+		return latency, 0, ports, probeErr
+	}
+
+	if probe.Method == probeMethodICMP {
+		latency, ttl, probeErr := nativePing(ctx, host, useIPv6, probe)
+		if probeErr != "" {
+			logger.Debug("ICMP probe to %s failed: %s", host, probeErr)
+		}
+		return latency, ttl, nil, probeErr
+	}
+
+	var name string
+	var args []string
 
 	// Different ping syntax for different operating systems
 	// Let ping auto-detect IPv4 vs IPv6 based on hostname resolution
 	if runtime.GOOS == "darwin" {
-		// macOS: -t 3 = 3 second timeout
-		cmd = exec.CommandContext(ctx, "ping", "-c", "1", "-t", "3", host)
+		// macOS: -t 3 = 3 second timeout, -m sets the TTL; IPv6 needs ping6, which has
+		// no timeout flag (the context deadline covers it) and sets the hop limit with -h
+		if useIPv6 {
+			name, args = "ping6", []string{"-c", "1"}
+			if probe.TTL > 0 {
+				args = append(args, "-h", strconv.Itoa(probe.TTL))
+			}
+		} else {
+			name, args = "ping", []string{"-c", "1", "-t", "3"}
+			if probe.TTL > 0 {
+				args = append(args, "-m", strconv.Itoa(probe.TTL))
+			}
+		}
 	} else {
-		// Linux: -W timeout in milliseconds
-		// No -4 or -6 flag - let ping auto-detect based on DNS resolution
-		cmd = exec.CommandContext(ctx, "ping", "-c", "1", "-W", "3000", host)
+		// Linux: -W timeout in milliseconds, -t sets the TTL
+		// No -4 or -6 flag - let ping auto-detect based on DNS resolution,
+		// except -6 for IPv6-only hosts and either one when the peer sets probe_family
+		name, args = "ping", []string{"-c", "1", "-W", "3000"}
+		if useIPv6 {
+			args = append([]string{"-6"}, args...)
+		} else if probe.Family != "" {
+			args = append([]string{"-4"}, args...)
+		}
+		if probe.TTL > 0 {
+			args = append(args, "-t", strconv.Itoa(probe.TTL))
+		}
 	}
+	cmd := exec.CommandContext(ctx, name, append(args, host)...)
 
 	output, err := cmd.CombinedOutput()
-	if err != nil {
-		// Check if it was a timeout
-		if ctx.Err() == context.DeadlineExceeded {
-			logger.Warn("Ping to %s timed out after 5 seconds (host may be unreachable or DNS hanging)", host)
-		} else {
-			logger.Debug("Ping to %s failed: %v", host, err)
-		}
-		return -1
+
+	// Check if it was a timeout
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Warn("Ping to %s timed out after 5 seconds (host may be unreachable or DNS hanging)", host)
+		return -1, 0, nil, "timeout"
+	}
+
+	// Classify the result so ping implementations with unusual exit codes or wording are handled
+	class := classifier.classify(string(output), err)
+	if class != probeReachable {
+		logger.Debug("Ping to %s failed (%s): %v", host, class, err)
+		return -1, 0, nil, probeErrorMessage(class, string(output), err)
 	}
 
 	re := regexp.MustCompile(`time[=<](\d+\.?\d*)\s*ms`)
 	matches := re.FindStringSubmatch(string(output))
 	if len(matches) < 2 {
 		logger.Debug("Failed to parse ping output for %s", host)
-		return -1
+		return -1, 0, nil, "unparseable output"
 	}
 
 	latency, err := strconv.ParseFloat(matches[1], 64)
 	if err != nil {
 		logger.Debug("Failed to convert latency for %s: %v", host, err)
-		return -1
+		return -1, 0, nil, "unparseable output"
+	}
+
+	return latency, replyTTL(string(output)), nil, ""
+}
+
+// checkBirdc makes sure birdc_path is an executable in Bird mode. A missing birdc is
+// fatal unless bird.missing_birdc is "monitor", which falls back to dry-run so peers are
+// still measured; in dry-run it only warns.
+func checkBirdc(config *Config) error {
+	if config.ExaBGP.Enabled || config.Bird.BirdSocket != "" {
+		return nil
+	}
+	_, err := exec.LookPath(config.Bird.BirdcPath)
+	if err == nil {
+		return nil
 	}
+	if !config.Mode.DryRun && config.Bird.MissingBirdc != "monitor" {
+		return fmt.Errorf("birdc not usable at bird.birdc_path %q: %v (set bird.missing_birdc: monitor to run monitor-only)", config.Bird.BirdcPath, err)
+	}
+	logger.Warn("birdc not usable at bird.birdc_path %q: %v", config.Bird.BirdcPath, err)
 
-	return latency
+	if !config.Mode.DryRun {
+		config.Mode.DryRun = true
+		logger.Warn("MONITOR-ONLY: routing changes will NOT be applied until birdc is installed and lagbuster restarted")
+	}
+	logger.Warn("BGP session checks will report Unknown, so every peer will be treated as BGP down")
+	return nil
 }
 
 // Check BGP session status for a peer via birdc
@@ -493,18 +1517,12 @@ func checkBGPSession(peerConfig PeerConfig, config BirdConfig) (bool, string) {
 		return false, "Unknown"
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(config.BirdcTimeout)*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, config.BirdcPath, "show", "protocols", protocolName)
-	output, err := cmd.CombinedOutput()
+	outputStr, err := birdCommand(config, "show", "protocols", protocolName)
 	if err != nil {
 		logger.Debug("Failed to check BGP session for %s: %v", peerConfig.Name, err)
 		return false, "Unknown"
 	}
 
-	outputStr := string(output)
-
 	// Parse birdc output for BGP state
 	// Expected format: "PROTOCOL_NAME  BGP   ---   up/start   TIMESTAMP   Established/Active/..."
 	lines := strings.Split(outputStr, "\n")
@@ -551,11 +1569,19 @@ func checkBGPSession(peerConfig PeerConfig, config BirdConfig) (bool, string) {
 // Evaluate health of all peers with damping
 func evaluatePeerHealth(state *AppState) {
 	for name, peer := range state.Peers {
-		latency := peer.CurrentLatency
-		baseline := peer.Config.ExpectedBaseline
+		// Peers on a slower interval keep their state until a new sample arrives,
+		// so one measurement is never counted twice towards damping
+		if !peer.freshSample {
+			continue
+		}
+		peer.freshSample = false
+
+		// Judge the raw sample or a smoothed latency, per damping.health_metric
+		latency := healthLatency(state.Config.Damping, peer)
+		baseline, baselineWindow := activeBaseline(peer.Config, time.Now())
 
 		// Check current health (without damping)
-		currentlyHealthy := isPeerHealthy(latency, baseline, state.Config.Thresholds)
+		currentlyHealthy, failedRules := isPeerHealthy(latency, latestQuality(peer), peer.Config, state.Config.Thresholds, peer.IsHealthy)
 
 		// Track consecutive unhealthy/healthy counts
 		if !currentlyHealthy {
@@ -566,14 +1592,58 @@ func evaluatePeerHealth(state *AppState) {
 			peer.ConsecutiveHealthyCount++
 		}
 
-		// Apply damping: only change state after consecutive threshold
+		// Apply damping: only change state after consecutive threshold,
+		// or on the weighted share of the window when damping.health_weighting is set
+		degrade := peer.ConsecutiveUnhealthyCount >= state.Config.Damping.ConsecutiveUnhealthyCount
+		restore := peer.ConsecutiveHealthyCount >= state.Config.Damping.ConsecutiveHealthyCountForRecovery
+		trigger := ""
+		if healthWeighted(state.Config.Damping) {
+			var share float64
+			degrade, restore, share = weightedHealthDecision(state, peer)
+			trigger = fmt.Sprintf(" (%s-weighted healthy share %.0f%%)", state.Config.Damping.HealthWeighting, share*100)
+		}
+
 		wasHealthy := peer.IsHealthy
-		if peer.IsHealthy && peer.ConsecutiveUnhealthyCount >= state.Config.Damping.ConsecutiveUnhealthyCount {
+		var canary *canaryResult
+		if !restore {
+			peer.recoveryDeferred = false
+			peer.canaryPending = false
+			peer.canary = nil
+		}
+		if peer.IsHealthy && degrade && latency >= 0 && time.Now().Before(peer.holdUntil) {
+			// A peer that just rejoined ECMP keeps its place for damping.min_active_hold
+			// so sessions can settle; only unreachability breaks the hold
+			logger.Debug("Peer %s is degraded but held in ECMP for another %s", name, time.Until(peer.holdUntil).Round(time.Second))
+		} else if peer.IsHealthy && degrade {
 			// Degrade: healthy → unhealthy after N consecutive bad measurements
 			peer.IsHealthy = false
-		} else if !peer.IsHealthy && peer.ConsecutiveHealthyCount >= state.Config.Damping.ConsecutiveHealthyCountForRecovery {
-			// Recover: unhealthy → healthy after M consecutive good measurements
-			peer.IsHealthy = true
+			peer.holdUntil = time.Time{}
+		} else if !peer.IsHealthy && restore {
+			// Recover: unhealthy → healthy after M consecutive good measurements,
+			// provided the peer has not been flapping, confirmed by a canary burst when configured
+			if flaps, unstable := recentlyFlapping(state, name); unstable {
+				if !peer.recoveryDeferred {
+					logger.Info("Peer %s is healthy but had %d health changes in the last %d minutes, deferring recovery",
+						name, flaps, state.Config.Damping.FlapWindow)
+				}
+				peer.recoveryDeferred = true
+			} else if peer.canary == nil && state.Config.Damping.CanaryCount > 0 {
+				// The burst is sent once this cycle has released state.mu and judged
+				// on the next cycle
+				peer.canaryPending = true
+			} else if canary, peer.canary = peer.canary, nil; canary == nil || canary.Passed {
+				peer.IsHealthy = true
+				if hold := state.Config.Damping.MinActiveHold; hold > 0 {
+					peer.holdUntil = time.Now().Add(time.Duration(hold) * time.Second)
+				}
+			} else {
+				logger.Info("Peer %s canary failed (%v), staying UNHEALTHY", name, canary.Latencies)
+				peer.ConsecutiveHealthyCount = 0
+				if data, err := json.Marshal(map[string]interface{}{"canary": canary}); err == nil {
+					meta := string(data)
+					recordEvent(state, "canary_failed", &name, nil, nil, "recovery aborted: canary probes failed", &meta)
+				}
+			}
 		}
 
 		// Handle health transitions (only log/notify on actual state changes)
@@ -581,35 +1651,88 @@ func evaluatePeerHealth(state *AppState) {
 			// Determine reason for health change
 			var reason string
 			if !peer.IsHealthy {
+				// Explain with the first rule the sample failed; a weighted decision can
+				// degrade on a sample that passed, so fall back to what the latency shows
+				cost := pathCost(latency, latestQuality(peer), state.Config.Thresholds)
+				costNote := pathCostNote(latency, latestQuality(peer), state.Config.Thresholds)
+				rule := ruleDegradation
 				if latency < 0 {
+					rule = ruleUnreachable
+				} else if len(failedRules) > 0 {
+					rule = failedRules[0]
+				} else if cost > state.Config.Thresholds.AbsoluteMaxLatency {
+					rule = ruleAbsoluteMax
+				}
+
+				if rule == ruleUnreachable {
 					reason = "unreachable/timeout"
-					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements: unreachable/timeout, baseline=%.2fms",
-						name, peer.ConsecutiveUnhealthyCount, baseline)
-				} else if latency > state.Config.Thresholds.AbsoluteMaxLatency {
-					reason = fmt.Sprintf("latency %.2fms exceeds absolute max %.2fms", latency, state.Config.Thresholds.AbsoluteMaxLatency)
-					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements: latency=%.2fms exceeds absolute max (%.2fms), baseline=%.2fms",
-						name, peer.ConsecutiveUnhealthyCount, latency, state.Config.Thresholds.AbsoluteMaxLatency, baseline)
+					if peer.LastProbeError != "" {
+						reason = fmt.Sprintf("unreachable (%s)", peer.LastProbeError)
+					}
+					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements%s: %s, baseline=%.2fms",
+						name, peer.ConsecutiveUnhealthyCount, trigger, reason, baseline)
+				} else if rule == rulePacketLoss {
+					reason = fmt.Sprintf("packet loss %.0f%%%s exceeds %.0f%%", peer.PacketLoss*100, expectedLossNote(peer.Config), state.Config.Thresholds.PacketLossThreshold*100)
+					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements%s: packet loss %.0f%%%s exceeds threshold (%.0f%%), latency=%.2fms",
+						name, peer.ConsecutiveUnhealthyCount, trigger, peer.PacketLoss*100, expectedLossNote(peer.Config), state.Config.Thresholds.PacketLossThreshold*100, latency)
+				} else if rule == ruleJitter {
+					reason = fmt.Sprintf("jitter %.2fms exceeds %.2fms", peer.Jitter, state.Config.Thresholds.JitterThreshold)
+					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements%s: jitter %.2fms exceeds threshold (%.2fms), latency=%.2fms",
+						name, peer.ConsecutiveUnhealthyCount, trigger, peer.Jitter, state.Config.Thresholds.JitterThreshold, latency)
+				} else if rule == ruleAbsoluteMax {
+					reason = fmt.Sprintf("latency %.2fms exceeds absolute max %.2fms%s", cost, state.Config.Thresholds.AbsoluteMaxLatency, costNote)
+					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements%s: latency=%.2fms exceeds absolute max (%.2fms), baseline=%.2fms%s",
+						name, peer.ConsecutiveUnhealthyCount, trigger, cost, state.Config.Thresholds.AbsoluteMaxLatency, baseline, costNote)
 				} else {
-					degradation := latency - baseline
-					reason = fmt.Sprintf("degradation %.2fms above baseline", degradation)
-					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements: latency=%.2fms, baseline=%.2fms, degradation=%.2fms",
-						name, peer.ConsecutiveUnhealthyCount, latency, baseline, degradation)
+					degradation := cost - baseline
+					reason = fmt.Sprintf("degradation %.2fms above baseline%s", degradation, costNote)
+					logger.Info("Peer %s became UNHEALTHY after %d consecutive unhealthy measurements%s: latency=%.2fms, baseline=%.2fms, degradation=%.2fms%s",
+						name, peer.ConsecutiveUnhealthyCount, trigger, cost, baseline, degradation, costNote)
 				}
 			} else {
 				reason = "latency returned to acceptable levels"
-				logger.Info("Peer %s became HEALTHY after %d consecutive healthy measurements: latency=%.2fms, baseline=%.2fms",
-					name, peer.ConsecutiveHealthyCount, latency, baseline)
+				logger.Info("Peer %s became HEALTHY after %d consecutive healthy measurements%s: latency=%.2fms, baseline=%.2fms",
+					name, peer.ConsecutiveHealthyCount, trigger, latency, baseline)
 			}
 
-			// Record health change event to database
-			if state.db != nil {
-				if _, err := state.db.RecordEvent("health_change", &name, nil, nil, &wasHealthy, &peer.IsHealthy, reason, nil); err != nil {
-					logger.Error("Failed to record health change event for %s: %v", name, err)
+			// Record health change event to database and live subscribers
+			// Attach the probe failure reason so unreachable transitions can be diagnosed later,
+			// and the canary burst that confirmed a recovery
+			var metadata *string
+			details := map[string]interface{}{}
+			if !peer.IsHealthy && latency < 0 && peer.LastProbeError != "" {
+				details["last_probe_error"] = peer.LastProbeError
+			}
+			if canary != nil {
+				details["canary"] = canary
+			}
+			if !peer.IsHealthy && len(failedRules) > 0 {
+				details["failed_rules"] = failedRules
+			}
+			if len(peer.tcpPorts) > 1 {
+				details["tcp_ports"] = peer.tcpPorts
+			}
+			if len(peer.probeResults) > 0 {
+				details["probes"] = peer.probeResults
+			}
+			if baselineWindow != "" {
+				details["baseline"] = baseline
+				details["baseline_window"] = baselineWindow
+			}
+			if len(details) > 0 {
+				if data, err := json.Marshal(details); err == nil {
+					meta := string(data)
+					metadata = &meta
 				}
 			}
 
+			eventID := recordEvent(state, "health_change", &name, &wasHealthy, &peer.IsHealthy, reason, metadata)
+			if singlePeerMode(state) {
+				logger.Info("Peer %s is the only peer: single peer, no failover possible, routing left unchanged", name)
+			}
+
 			// Send notifications for significant health changes
-			if state.notifier != nil {
+			if state.notifier != nil && !peerWarmingUp(state, peer, "health change") {
 				if !peer.IsHealthy {
 					// Became unhealthy
 					state.notifier.Notify(notifications.Event{
@@ -619,6 +1742,7 @@ func evaluatePeerHealth(state *AppState) {
 						Baseline:  baseline,
 						Reason:    reason,
 						Timestamp: time.Now(),
+						EventID:   eventID,
 					})
 				} else {
 					// Recovered to healthy
@@ -628,6 +1752,7 @@ func evaluatePeerHealth(state *AppState) {
 						Latency:   latency,
 						Baseline:  baseline,
 						Timestamp: time.Now(),
+						EventID:   eventID,
 					})
 				}
 			}
@@ -635,72 +1760,259 @@ func evaluatePeerHealth(state *AppState) {
 	}
 }
 
-// Check if a peer is healthy based on current latency vs baseline
-func isPeerHealthy(latency float64, baseline float64, thresholds ThresholdConfig) bool {
-	// Timeout or failed ping
-	if latency < 0 {
+// peerWarmingUp reports whether a peer's notifications are held back because
+// notifications.suppress_during_warmup is set and the peer hasn't yet filled its
+// measurement window since startup. Events are still recorded; only alerts are skipped.
+func peerWarmingUp(state *AppState, peer *PeerState, what string) bool {
+	if !state.Config.Notifications.SuppressDuringWarmup || peer.samplesTaken >= state.Config.Damping.MeasurementWindow {
 		return false
 	}
+	logger.Info("Peer %s is still warming up (%d of %d samples), not notifying about %s",
+		peer.Config.Name, peer.samplesTaken, state.Config.Damping.MeasurementWindow, what)
+	return true
+}
 
-	// Exceeds absolute maximum
-	if latency > thresholds.AbsoluteMaxLatency {
-		return false
+// operationalState is the state stored with measurements so reporting can leave out
+// periods when routing decisions were not live
+func operationalState(state *AppState) string {
+	switch {
+	case state.Config.Mode.DryRun:
+		return database.StateDryRun
+	case state.standby.Load():
+		return database.StateStandby
+	case state.frozen.Load():
+		return database.StateFrozen
+	default:
+		return database.StateNormal
 	}
+}
 
-	// Degraded beyond threshold from baseline
-	degradation := latency - baseline
-	if degradation > thresholds.DegradationThreshold {
-		return false
+// recordEvent persists an event to the database and pushes it to live API subscribers.
+// It returns the event's database ID, 0 without a database or when the write failed.
+func recordEvent(state *AppState, eventType string, peerName *string, oldHealth, newHealth *bool, reason string, metadata *string) int64 {
+	if state.trace != nil {
+		state.trace.event(eventType, peerName)
+	}
+	if state.dryRun != nil {
+		state.dryRun.note(peerName, reason)
+	}
+	if state.syslog != nil {
+		state.syslog.emit(eventType, peerName, newHealth, reason)
 	}
 
-	return true
+	var id int64
+	if state.db != nil {
+		var err error
+		if id, err = state.db.RecordEvent(eventType, peerName, nil, nil, oldHealth, newHealth, reason, metadata); err != nil {
+			logger.Error("Failed to record %s event: %v", eventType, err)
+		}
+	}
+	rememberEvent(state, id, eventType, peerName, oldHealth, newHealth, reason, metadata)
+
+	if state.apiServer != nil {
+		name := ""
+		if peerName != nil {
+			name = *peerName
+		}
+		state.apiServer.BroadcastEvent(eventType, name, reason)
+	}
+	return id
+}
+
+// recentlyFlapping reports whether a peer changed health more than damping.max_recent_flaps
+// times within damping.flap_window minutes, in which case it should not recover yet
+func recentlyFlapping(state *AppState, name string) (int, bool) {
+	limit := state.Config.Damping.MaxRecentFlaps
+	if limit <= 0 || state.Config.Damping.FlapWindow <= 0 || state.db == nil {
+		return 0, false
+	}
+
+	since := time.Now().Add(-time.Duration(state.Config.Damping.FlapWindow) * time.Minute)
+	flaps, err := state.db.CountEvents(name, "health_change", since)
+	if err != nil {
+		logger.Warn("Could not check recent flaps for %s: %v", name, err)
+		return 0, false
+	}
+	return flaps, flaps > limit
 }
 
+// Check if a peer's sample is healthy against its health rules (see healthrules.go),
+// returning the rules it failed in the order they are checked
+// The degradation limit depends on the peer's current state (see degradationLimit)
+func isPeerHealthy(latency float64, quality sampleQuality, peer PeerConfig, thresholds ThresholdConfig, isHealthy bool) (bool, []string) {
+	rules, mode := peerHealthRules(thresholds, peer)
+
+	// The latency rules judge the path cost, which includes any loss penalty
+	latency = pathCost(latency, quality, thresholds)
+
+	// The packet_loss rule judges loss above what the peer normally sees, the way the
+	// degradation rule judges latency above its baseline
+	ruleQuality := quality
+	ruleQuality.PacketLoss = excessLoss(quality.PacketLoss, peer)
+
+	var failed []string
+	for _, rule := range rules {
+		if healthRuleChecks[rule](latency, ruleQuality, peerBaseline(peer), thresholds, isHealthy) {
+			failed = append(failed, rule)
+		}
+	}
+
+	if mode == ruleModeAll {
+		return len(failed) < len(rules), failed
+	}
+	return len(failed) == 0, failed
+}
+
+// degradationLimit applies the hysteresis band around degradation_threshold: a healthy peer's
+// samples only count as bad once they rise hysteresis_enter above the threshold, and an
+// unhealthy peer's samples only count as good once they drop hysteresis_exit below it.
+// This stops a peer hovering right at the threshold from constantly resetting its counters.
+func degradationLimit(thresholds ThresholdConfig, isHealthy bool) float64 {
+	if isHealthy {
+		return thresholds.DegradationThreshold + thresholds.HysteresisEnter
+	}
+	return thresholds.DegradationThreshold - thresholds.HysteresisExit
+}
+
+// validateHysteresis checks thresholds.hysteresis_enter and hysteresis_exit. Negative
+// margins would move the limits to the wrong side of degradation_threshold, and an exit
+// margin wider than the enter margin makes the band lopsided the wrong way round.
+func validateHysteresis(thresholds ThresholdConfig) error {
+	if thresholds.HysteresisEnter < 0 || thresholds.HysteresisExit < 0 {
+		return fmt.Errorf("thresholds.hysteresis_enter and hysteresis_exit must not be negative")
+	}
+	if thresholds.HysteresisEnter < thresholds.HysteresisExit {
+		return fmt.Errorf("thresholds.hysteresis_enter (%.2fms) must not be smaller than hysteresis_exit (%.2fms)",
+			thresholds.HysteresisEnter, thresholds.HysteresisExit)
+	}
+	return nil
+}
 
 // Apply Bird configuration changes
 func applyBirdConfiguration(state *AppState) error {
-	// Assign priorities: 1 for primary, 2 for second-best, 3 for third
-	priorities := assignPriorities(state)
+	// Assign priorities: 1 for healthy, 99 for unhealthy (held while frozen)
+	priorities := priorityAssignment(state)
 
 	// Generate configuration file content
-	content := generateBirdConfig(state, priorities)
-
-	// Write to temporary file first
-	tempFile := state.Config.Bird.PrioritiesFile + ".tmp"
-	err := os.WriteFile(tempFile, []byte(content), 0644)
+	content, err := birdConfigContent(state, priorities)
 	if err != nil {
-		return fmt.Errorf("writing temp file: %w", err)
+		return err
 	}
 
-	// Atomic rename
-	err = os.Rename(tempFile, state.Config.Bird.PrioritiesFile)
-	if err != nil {
-		return fmt.Errorf("renaming temp file: %w", err)
+	// Keep the current file so it can be put back if Bird rejects the new one. Only a
+	// missing file can be restored by removing it; if it can't be read for any other
+	// reason, don't replace what Bird is running on.
+	path := state.Config.Bird.PrioritiesFile
+	previous, readErr := os.ReadFile(path)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		return fmt.Errorf("reading current %s: %w", path, readErr)
+	}
+	restore := func() error {
+		if readErr != nil {
+			return os.Remove(path) // There was none
+		}
+		return writeBirdConfig(path, string(previous))
 	}
 
-	logger.Debug("Wrote Bird config to %s", state.Config.Bird.PrioritiesFile)
+	if err := writeBirdConfig(path, content); err != nil {
+		return err
+	}
+
+	logger.Debug("Wrote Bird config to %s", path)
+
+	// Have Bird parse the new config before reloading into it. The priorities file is an
+	// include, so it can only be checked in place; Bird doesn't read it until configure.
+	if err := checkBirdConfig(state.Config.Bird); err != nil {
+		if restoreErr := restore(); restoreErr != nil {
+			err = fmt.Errorf("%w (restoring the previous %s also failed: %v)", err, path, restoreErr)
+		} else {
+			err = fmt.Errorf("%w (previous %s restored)", err, path)
+		}
+		recordEvent(state, "bird_config_error", nil, nil, nil, err.Error(), nil)
+		return err
+	}
 
 	// Reload Bird configuration
-	cmd := exec.Command(state.Config.Bird.BirdcPath, "configure")
-	output, err := cmd.CombinedOutput()
+	if err := configureBird(state.Config.Bird); err != nil {
+		return rollbackBirdConfig(state, restore, err)
+	}
+
+	state.appliedPriorities = priorities
+	return nil
+}
+
+// configureBird runs `birdc configure` and requires Bird to confirm it reconfigured
+func configureBird(config BirdConfig) error {
+	output, err := birdCommand(config, "configure")
 	if err != nil {
-		return fmt.Errorf("birdc configure failed: %w, output: %s", err, string(output))
+		return fmt.Errorf("birdc configure failed: %w, output: %s", err, output)
 	}
 
 	// Check if reconfiguration was successful
-	if !strings.Contains(string(output), "Reconfigured") {
-		return fmt.Errorf("birdc configure did not confirm success: %s", string(output))
+	if !strings.Contains(output, "Reconfigured") {
+		return fmt.Errorf("birdc configure did not confirm success: %s", output)
 	}
 
-	logger.Debug("birdc output: %s", strings.TrimSpace(string(output)))
+	logger.Debug("birdc output: %s", strings.TrimSpace(output))
+	return nil
+}
 
+// rollbackBirdConfig returns Bird to the last-known-good priorities after a failed
+// reload: it puts the previous file back and configures again, then records a
+// bird_rollback event and notifies. The returned error describes both steps.
+func rollbackBirdConfig(state *AppState, restore func() error, configureErr error) error {
+	path := state.Config.Bird.PrioritiesFile
+	reason := fmt.Sprintf("birdc configure failed with the new priorities: %v", configureErr)
+	if err := restore(); err != nil {
+		reason += fmt.Sprintf("; restoring the previous %s failed: %v", path, err)
+	} else if err := configureBird(state.Config.Bird); err != nil {
+		reason += fmt.Sprintf("; previous %s restored but configure failed again: %v", path, err)
+	} else {
+		reason += fmt.Sprintf("; previous %s restored and reloaded", path)
+	}
+
+	logger.Error("Bird rollback: %s", reason)
+	eventID := recordEvent(state, "bird_rollback", nil, nil, nil, reason, nil)
+	if state.notifier != nil {
+		state.notifier.Notify(notifications.Event{
+			Type:      notifications.EventBirdRollback,
+			Reason:    reason,
+			Timestamp: time.Now(),
+			EventID:   eventID,
+		})
+	}
+	return fmt.Errorf("rolled back: %w", configureErr)
+}
+
+// writeBirdConfig replaces the priorities file atomically via a temp file and rename
+func writeBirdConfig(path, content string) error {
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
+
+// checkBirdConfig runs `birdc configure check`, which parses the configuration
+// without applying it
+func checkBirdConfig(config BirdConfig) error {
+	output, err := birdCommand(config, "configure", "check")
+	if err != nil {
+		return fmt.Errorf("birdc configure check failed: %w, output: %s", err, strings.TrimSpace(output))
+	}
+	if !strings.Contains(output, "Configuration OK") {
+		return fmt.Errorf("bird rejected the generated config: %s", strings.TrimSpace(output))
+	}
 	return nil
 }
 
 // Apply ExaBGP configuration changes via API
 func applyExaBGPConfiguration(state *AppState) error {
-	// Assign priorities: 1 for healthy, 99 for unhealthy
-	priorities := assignPriorities(state)
+	// Assign priorities: 1 for healthy, 99 for unhealthy (held while frozen)
+	priorities := priorityAssignment(state)
 
 	// Log what we're about to do
 	logger.Debug("Applying ExaBGP configuration for %d peers", len(state.Config.Peers))
@@ -748,6 +2060,9 @@ func applyExaBGPConfiguration(state *AppState) error {
 	logger.Info("ExaBGP configuration applied: %d healthy peers, %d unhealthy peers",
 		countHealthyPeers(state), len(state.Config.Peers)-countHealthyPeers(state))
 
+	if !state.Config.Mode.DryRun {
+		state.appliedPriorities = priorities
+	}
 	return nil
 }
 
@@ -762,14 +2077,92 @@ func countHealthyPeers(state *AppState) int {
 	return count
 }
 
-// Assign priority values (1=best, 2=second, 3=third) based on current primary
+// priorityAssignment returns the priorities to apply this cycle. While frozen it returns the
+// last applied assignment so routing holds still; probing, recording and alerting continue.
+func priorityAssignment(state *AppState) map[string]int {
+	if state.frozen.Load() && state.appliedPriorities != nil {
+		held := make(map[string]int, len(state.appliedPriorities))
+		for name, priority := range state.appliedPriorities {
+			held[name] = priority
+		}
+		// Peers that never had a priority applied stay disabled while frozen
+		for name := range state.Peers {
+			if _, ok := held[name]; !ok {
+				held[name] = 99
+			}
+		}
+		return held
+	}
+	return assignPriorities(state)
+}
+
+// setFrozen toggles the routing kill-switch and records the change. The caller holds
+// state.mu.
+func setFrozen(state *AppState, frozen bool, source string) {
+	if state.frozen.Swap(frozen) == frozen {
+		return
+	}
+
+	if frozen {
+		logger.Warn("Routing decisions FROZEN (%s) - priorities held at last applied values, monitoring continues", source)
+		recordEvent(state, "freeze", nil, nil, nil, "routing decisions frozen via "+source, nil)
+	} else {
+		logger.Warn("Routing decisions UNFROZEN (%s) - automatic priority management resumed", source)
+		recordEvent(state, "unfreeze", nil, nil, nil, "routing decisions unfrozen via "+source, nil)
+	}
+}
+
+// resetPeer clears a peer's damping counters and measurement window so damping starts
+// fresh, e.g. after a flapping link was fixed. Its health state is kept. A peer that is
+// healthy but already counting bad samples is mid-incident: resetting would postpone its
+// removal, so that requires force.
+func resetPeer(state *AppState, name string, force bool) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	peer, ok := state.Peers[name]
+	if !ok {
+		return api.ErrPeerNotFound
+	}
+
+	if peer.IsHealthy && peer.ConsecutiveUnhealthyCount > 0 && !force {
+		return api.ErrPeerResetNeedsForce
+	}
+
+	reason := fmt.Sprintf("counters reset via API (healthy=%d, unhealthy=%d, window=%d)",
+		peer.ConsecutiveHealthyCount, peer.ConsecutiveUnhealthyCount, len(peer.Measurements))
+	if force {
+		reason += " (forced)"
+	}
+
+	peer.ConsecutiveHealthyCount = 0
+	peer.ConsecutiveUnhealthyCount = 0
+	peer.Measurements = peer.Measurements[:0]
+	peer.losses = peer.losses[:0]
+	peer.jitters = peer.jitters[:0]
+	peer.SmoothedLatency = -1
+	peer.freshSample = false
+
+	logger.Warn("Peer %s %s", name, reason)
+	recordEvent(state, "peer_reset", &name, nil, nil, reason, nil)
+	updateAPIServerState(state)
+
+	return nil
+}
+
+// Assign each peer priority 1 (in ECMP) or 99 (withdrawn); there is no ranking between
+// in-use peers. Healthy peers with BGP established get 1, except disabled ones. In
+// single-peer mode the lone peer keeps 1 while BGP is up, whatever its health.
 func assignPriorities(state *AppState) map[string]int {
 	priorities := make(map[string]int)
 
 	// Asymmetric routing (ECMP): All healthy peers with established BGP get priority 1
 	// Unhealthy or BGP-down peers get priority 99 (effectively disabled)
+	// A lone peer stays in use whatever its health while singlePeerMode applies
+	// Disabled peers are withdrawn whatever their health
+	single := singlePeerMode(state)
 	for name, peer := range state.Peers {
-		if peer.IsHealthy && peer.BGPSessionUp {
+		if !peer.Disabled && (peer.IsHealthy || single) && peer.BGPSessionUp {
 			// Healthy peer with established BGP session - use for routing
 			priorities[name] = 1
 		} else {
@@ -785,14 +2178,15 @@ func assignPriorities(state *AppState) map[string]int {
 func generateBirdConfig(state *AppState, priorities map[string]int) string {
 	var sb strings.Builder
 
-	// Count healthy and unhealthy peers
-	healthyPeers := make([]string, 0)
-	unhealthyPeers := make([]string, 0)
-	for name, peer := range state.Peers {
-		if peer.IsHealthy && peer.BGPSessionUp {
-			healthyPeers = append(healthyPeers, name)
+	// List peers by the priority they are given, so disabled peers and a single peer
+	// kept in use are reported as written below
+	activePeers := make([]string, 0)
+	withdrawnPeers := make([]string, 0)
+	for _, peerConfig := range state.Config.Peers {
+		if priorities[peerConfig.Name] == 1 {
+			activePeers = append(activePeers, birdCommentText(peerConfig.Name))
 		} else {
-			unhealthyPeers = append(unhealthyPeers, name)
+			withdrawnPeers = append(withdrawnPeers, birdCommentText(peerConfig.Name))
 		}
 	}
 
@@ -800,10 +2194,14 @@ func generateBirdConfig(state *AppState, priorities map[string]int) string {
 	sb.WriteString(fmt.Sprintf("# Generated at: %s\n", time.Now().Format(time.RFC3339)))
 	sb.WriteString("#\n")
 	sb.WriteString("# Mode: All healthy peers get priority 1 (ECMP)\n")
-	sb.WriteString(fmt.Sprintf("# Healthy peers (%d): %v\n", len(healthyPeers), healthyPeers))
-	sb.WriteString(fmt.Sprintf("# Unhealthy/BGP-down peers (%d): %v\n", len(unhealthyPeers), unhealthyPeers))
+	sb.WriteString(fmt.Sprintf("# Active peers (%d): %v\n", len(activePeers), activePeers))
+	sb.WriteString(fmt.Sprintf("# Withdrawn peers (%d): %v\n", len(withdrawnPeers), withdrawnPeers))
 	sb.WriteString("#\n")
 	sb.WriteString("# Priority values: 1=active (ECMP), 99=disabled\n")
+	if active, ok := birdLocalPref(state.Config.Bird, 1); ok {
+		disabled, _ := birdLocalPref(state.Config.Bird, 99)
+		sb.WriteString(fmt.Sprintf("# Emitted as local-pref: %d=active, %d=disabled\n", active, disabled))
+	}
 	sb.WriteString("#\n\n")
 
 	// Write peer status as comments
@@ -816,8 +2214,24 @@ func generateBirdConfig(state *AppState, priorities map[string]int) string {
 			healthStatus = "UNHEALTHY"
 		}
 
-		sb.WriteString(fmt.Sprintf("# %s: priority=%d, latency=%.2fms, baseline=%.2fms, %s\n",
-			peerConfig.Name, priority, peer.CurrentLatency, peer.Config.ExpectedBaseline, healthStatus))
+		// Say which probe produced the latency when it isn't ICMP
+		probeNote := ""
+		switch probe := peerProbeConfig(state.Config, peerConfig); {
+		case len(peerConfig.HealthProbes) > 0:
+			probeNote = fmt.Sprintf(", probe=%s (%s)", strings.Join(peerConfig.HealthProbes, "+"), consensusMode(peerConfig))
+		case probe.Method == probeMethodTCP:
+			probeNote = fmt.Sprintf(", probe=tcp/%s", tcpPortsNote(probe))
+		case probe.Method == probeMethodHTTP:
+			probeNote = ", probe=http"
+		}
+
+		localPrefNote := ""
+		if localPref, ok := birdLocalPref(state.Config.Bird, priority); ok {
+			localPrefNote = fmt.Sprintf(" (local-pref %d)", localPref)
+		}
+
+		sb.WriteString(fmt.Sprintf("# %s: priority=%d%s, latency=%.2fms%s, baseline=%.2fms, %s\n",
+			birdCommentText(peerConfig.Name), priority, localPrefNote, peer.CurrentLatency, probeNote, peerBaseline(peer.Config), healthStatus))
 	}
 
 	sb.WriteString("\n")
@@ -825,11 +2239,25 @@ func generateBirdConfig(state *AppState, priorities map[string]int) string {
 	// Write priority definitions
 	for _, peerConfig := range state.Config.Peers {
 		priority := priorities[peerConfig.Name]
-		sb.WriteString(fmt.Sprintf("define %s = %d;\n", peerConfig.BirdVariable, priority))
+		sb.WriteString(fmt.Sprintf("define %s = %s;\n", peerConfig.BirdVariable, birdPriorityValue(state.Config.Bird, priority)))
 	}
 
 	return sb.String()
 }
+
+// birdPriorityValue renders a priority for a define statement: as its local-pref with
+// bird.localpref_map, through bird.priority_value_map when the priority is mapped, and as
+// the plain integer otherwise
+func birdPriorityValue(config BirdConfig, priority int) string {
+	if localPref, ok := birdLocalPref(config, priority); ok {
+		return strconv.Itoa(localPref)
+	}
+	if value, ok := config.PriorityValueMap[priority]; ok {
+		return value
+	}
+	return strconv.Itoa(priority)
+}
+
 // updateAPIServerState synchronizes AppState to API server state
 func updateAPIServerState(state *AppState) {
 	if state.apiServer == nil {
@@ -839,20 +2267,37 @@ func updateAPIServerState(state *AppState) {
 	// Convert peers to API format
 	apiPeers := make(map[string]*api.PeerState)
 	for name, peer := range state.Peers {
+		baseline, baselineWindow := activeBaseline(peer.Config, time.Now())
 		apiPeers[name] = &api.PeerState{
 			Name:                      peer.Config.Name,
 			Hostname:                  peer.Config.Hostname,
-			Baseline:                  peer.Config.ExpectedBaseline,
+			Baseline:                  baseline,
+			BaselineWindow:            baselineWindow,
 			CurrentLatency:            peer.CurrentLatency,
 			IsHealthy:                 peer.IsHealthy,
 			ConsecutiveHealthyCount:   peer.ConsecutiveHealthyCount,
 			ConsecutiveUnhealthyCount: peer.ConsecutiveUnhealthyCount,
 			BGPSessionUp:              peer.BGPSessionUp,
 			BGPSessionState:           peer.BGPSessionState,
+			Disabled:                  peer.Disabled,
+			LastProbeError:            peer.LastProbeError,
+			PacketLoss:                peer.PacketLoss,
+			ExpectedLoss:              peer.Config.ExpectedLoss,
+			Jitter:                    peer.Jitter,
+			SmoothedLatency:           peer.SmoothedLatency,
+			PathAsymmetry:             peer.PathAsymmetry,
+			MeasurementInterval:       int(peerMeasurementInterval(state.Config, peer.Config).Seconds()),
+			HoldUntil:                 peer.holdUntil,
 		}
 	}
 
 	// Update API server state without recreating the entire server
 	// This preserves Config, Notifier, and ConfigPath
+	state.apiServer.SetReferenceQuorum(state.referenceStatus)
+	state.apiServer.SetStandby(state.standby.Load())
+	if state.db != nil {
+		lag := float64(state.dbWriteLag.Microseconds()) / 1000
+		state.apiServer.SetDBWriteLag(&lag)
+	}
 	state.apiServer.UpdateState(state.StartTime, apiPeers)
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// validateLocalPrefMap checks bird.localpref_map: the local-pref for peers in use
+// (priority 1) followed by the one for withdrawn peers (priority 99)
+func validateLocalPrefMap(config BirdConfig) error {
+	localPrefs := config.LocalPrefMap
+	if len(localPrefs) == 0 {
+		return nil
+	}
+	if len(localPrefs) != 2 {
+		return fmt.Errorf("bird.localpref_map needs two values (in use, withdrawn), got %d", len(localPrefs))
+	}
+	for _, localPref := range localPrefs {
+		if localPref < 0 || int64(localPref) > math.MaxUint32 {
+			return fmt.Errorf("bird.localpref_map: %d is not a valid local-pref", localPref)
+		}
+	}
+	if localPrefs[0] <= localPrefs[1] {
+		return fmt.Errorf("bird.localpref_map: the in-use local-pref (%d) must be above the withdrawn one (%d)", localPrefs[0], localPrefs[1])
+	}
+	if len(config.PriorityValueMap) > 0 {
+		return fmt.Errorf("bird.localpref_map and bird.priority_value_map cannot both be set")
+	}
+	return nil
+}
+
+// birdLocalPref returns the local-pref bird.localpref_map gives a priority, and false
+// when no map is configured
+func birdLocalPref(config BirdConfig, priority int) (int, bool) {
+	if len(config.LocalPrefMap) != 2 {
+		return 0, false
+	}
+	if priority == 1 {
+		return config.LocalPrefMap[0], true
+	}
+	return config.LocalPrefMap[1], true
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// openLogFile copies the log to logging.file as well as stderr, so it can be read back
+// through GET /api/logs. The file is only ever appended to; rotate it with logrotate's
+// copytruncate.
+func openLogFile(config LoggingConfig) error {
+	if config.File == "" {
+		return nil
+	}
+	file, err := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("opening logging.file: %w", err)
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+	return nil
+}
+
+// exposedLogFile returns the log file /api/logs serves, empty unless api.expose_logs is set.
+// The API has no authentication of its own, so the logs stay private unless asked for.
+func exposedLogFile(config Config) string {
+	if !config.API.ExposeLogs {
+		return ""
+	}
+	return config.Logging.File
+}
@@ -1,31 +1,42 @@
 package notifications
 
 import (
+	"errors"
 	"fmt"
 	"net/smtp"
+	"net/textproto"
 	"strings"
 )
 
 // EmailConfig holds email notification configuration
 type EmailConfig struct {
-	Enabled   bool        `yaml:"enabled"`
-	SMTPHost  string      `yaml:"smtp_host"`
-	SMTPPort  int         `yaml:"smtp_port"`
-	Username  string      `yaml:"username"`
-	Password  string      `yaml:"password"`
-	From      string      `yaml:"from"`
-	To        []string    `yaml:"to"`
-	Events    []EventType `yaml:"event_types"`
+	Enabled          bool                 `yaml:"enabled" json:"enabled"`
+	SMTPHost         string               `yaml:"smtp_host" json:"smtp_host"`
+	SMTPPort         int                  `yaml:"smtp_port" json:"smtp_port"`
+	Username         string               `yaml:"username" json:"username"`
+	Password         string               `yaml:"password" json:"password"`
+	From             string               `yaml:"from" json:"from"`
+	To               []string             `yaml:"to" json:"to"`
+	Events           []EventType          `yaml:"event_types" json:"event_types"`
+	Groups           []string             `yaml:"groups" json:"groups"`                       // Peer notification groups to accept, empty = all
+	Templates        map[EventType]string `yaml:"templates" json:"templates"`                 // text/template bodies by event type, replacing the built-in text
+	SubjectTemplates map[EventType]string `yaml:"subject_templates" json:"subject_templates"` // text/template subjects by event type
 }
 
 // EmailChannel implements email notifications
 type EmailChannel struct {
-	config EmailConfig
+	config    EmailConfig
+	times     *TimeFormatter
+	templates messageTemplates
+	subjects  messageTemplates
 }
 
-// NewEmailChannel creates a new email notification channel
-func NewEmailChannel(config EmailConfig) *EmailChannel {
-	return &EmailChannel{config: config}
+// NewEmailChannel creates a new email notification channel. Templates that don't
+// parse are skipped (ValidateTemplates rejects them at config load).
+func NewEmailChannel(config EmailConfig, times *TimeFormatter) *EmailChannel {
+	templates, _ := parseTemplates("email.templates", config.Templates, times)
+	subjects, _ := parseTemplates("email.subject_templates", config.SubjectTemplates, times)
+	return &EmailChannel{config: config, times: times, templates: templates, subjects: subjects}
 }
 
 // Name returns the channel name
@@ -38,6 +49,11 @@ func (e *EmailChannel) IsEnabled() bool {
 	return e.config.Enabled
 }
 
+// AcceptsGroup returns whether this channel receives events for peers in the given group
+func (e *EmailChannel) AcceptsGroup(group string) bool {
+	return groupAllowed(e.config.Groups, group)
+}
+
 // ShouldNotify returns whether this channel should notify for the given event type
 func (e *EmailChannel) ShouldNotify(eventType EventType) bool {
 	for _, et := range e.config.Events {
@@ -50,7 +66,10 @@ func (e *EmailChannel) ShouldNotify(eventType EventType) bool {
 
 // Send sends an email notification
 func (e *EmailChannel) Send(event Event) error {
-	subject, body := e.formatMessage(event)
+	subject, body, err := e.formatMessage(event)
+	if err != nil {
+		return err
+	}
 
 	// Build email message
 	msg := fmt.Sprintf("From: %s\r\n"+
@@ -71,7 +90,33 @@ func (e *EmailChannel) Send(event Event) error {
 	return smtp.SendMail(addr, auth, e.config.From, e.config.To, []byte(msg))
 }
 
-func (e *EmailChannel) formatMessage(event Event) (subject, body string) {
+// smtpResponse extracts the SMTP server's reply line from a send error, if any
+func smtpResponse(err error) string {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return fmt.Sprintf("%d %s", protoErr.Code, protoErr.Msg)
+	}
+	return ""
+}
+
+// formatMessage renders the configured templates for the event, falling back to the
+// built-in subject and body
+func (e *EmailChannel) formatMessage(event Event) (subject, body string, err error) {
+	subject, body = e.builtinMessage(event)
+	if text, ok, err := e.subjects.render(event); err != nil {
+		return "", "", err
+	} else if ok {
+		subject = text
+	}
+	if text, ok, err := e.templates.render(event); err != nil {
+		return "", "", err
+	} else if ok {
+		body = text
+	}
+	return subject, body, nil
+}
+
+func (e *EmailChannel) builtinMessage(event Event) (subject, body string) {
 	switch event.Type {
 	case EventSwitch:
 		subject = fmt.Sprintf("[Lagbuster] BGP Primary Switch: %s → %s", event.OldPrimary, event.NewPrimary)
@@ -83,7 +128,7 @@ New Primary: %s
 Reason: %s
 
 The BGP primary path has been switched. Please monitor the situation.
-`, event.Timestamp.Format("2006-01-02 15:04:05"), event.OldPrimary, event.NewPrimary, event.Reason)
+`, e.times.Format(event.Timestamp), event.OldPrimary, event.NewPrimary, event.Reason)
 
 	case EventFailback:
 		subject = fmt.Sprintf("[Lagbuster] Failback to Preferred Primary: %s", event.NewPrimary)
@@ -94,7 +139,7 @@ Preferred Primary: %s
 Reason: %s
 
 The system has automatically failed back to the preferred primary peer.
-`, event.Timestamp.Format("2006-01-02 15:04:05"), event.NewPrimary, event.Reason)
+`, e.times.Format(event.Timestamp), event.NewPrimary, event.Reason)
 
 	case EventUnhealthy:
 		subject = fmt.Sprintf("[Lagbuster] Peer Unhealthy: %s", event.PeerName)
@@ -106,7 +151,7 @@ Latency: %.2fms (baseline: %.2fms)
 Reason: %s
 
 Please investigate the peer health issue.
-`, event.Timestamp.Format("2006-01-02 15:04:05"), event.PeerName, event.Latency, event.Baseline, event.Reason)
+`, e.times.Format(event.Timestamp), event.PeerName, event.Latency, event.Baseline, event.Reason)
 
 	case EventRecovery:
 		subject = fmt.Sprintf("[Lagbuster] Peer Recovered: %s", event.PeerName)
@@ -117,7 +162,7 @@ Peer: %s
 Latency: %.2fms (baseline: %.2fms)
 
 The peer has returned to healthy status.
-`, event.Timestamp.Format("2006-01-02 15:04:05"), event.PeerName, event.Latency, event.Baseline)
+`, e.times.Format(event.Timestamp), event.PeerName, event.Latency, event.Baseline)
 
 	case EventStartup:
 		subject = "[Lagbuster] Service Started"
@@ -126,7 +171,7 @@ The peer has returned to healthy status.
 Time: %s
 
 The BGP path optimization service has started.
-`, event.Timestamp.Format("2006-01-02 15:04:05"))
+`, e.times.Format(event.Timestamp))
 
 	case EventShutdown:
 		subject = "[Lagbuster] Service Stopped"
@@ -135,11 +180,32 @@ The BGP path optimization service has started.
 Time: %s
 
 The BGP path optimization service has been stopped.
-`, event.Timestamp.Format("2006-01-02 15:04:05"))
+`, e.times.Format(event.Timestamp))
+
+	case EventBirdRollback:
+		subject = "[Lagbuster] Bird Reload Failed - Rolled Back"
+		body = fmt.Sprintf(`Bird Reload Failed
+
+Time: %s
+Details: %s
+
+The new priorities were not applied and the previous priorities file was restored.
+Please check Bird.
+`, e.times.Format(event.Timestamp), event.Reason)
+
+	case EventDigest:
+		subject = fmt.Sprintf("[Lagbuster] Digest: %d events", len(event.Digest))
+		body = fmt.Sprintf(`Lagbuster Notification Digest
+
+Time: %s
+%s
+
+%s
+`, e.times.Format(event.Timestamp), event.Reason, strings.Join(digestLines(event, e.times), "\n"))
 
 	default:
 		subject = fmt.Sprintf("[Lagbuster] Event: %s", event.Type)
-		body = fmt.Sprintf("Event: %s\nTime: %s\n", event.Type, event.Timestamp.Format("2006-01-02 15:04:05"))
+		body = fmt.Sprintf("Event: %s\nTime: %s\n", event.Type, e.times.Format(event.Timestamp))
 	}
 
 	return subject, body
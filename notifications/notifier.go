@@ -10,24 +10,30 @@ import (
 type EventType string
 
 const (
-	EventSwitch     EventType = "switch"
-	EventUnhealthy  EventType = "unhealthy"
-	EventRecovery   EventType = "recovery"
-	EventFailback   EventType = "failback"
-	EventStartup    EventType = "startup"
-	EventShutdown   EventType = "shutdown"
+	EventSwitch           EventType = "switch"
+	EventUnhealthy        EventType = "unhealthy"
+	EventRecovery         EventType = "recovery"
+	EventFailback         EventType = "failback"
+	EventStartup          EventType = "startup"
+	EventShutdown         EventType = "shutdown"
+	EventPathAsymmetry    EventType = "path_asymmetry"
+	EventDatabaseRecovery EventType = "db_recovery"
+	EventBirdRollback     EventType = "bird_rollback" // birdc configure failed and the previous priorities were restored
+	EventDigest           EventType = "digest"        // Events buffered over notifications.digest_minutes
 )
 
 // Event represents a notification event
 type Event struct {
-	Type       EventType
-	PeerName   string
-	OldPrimary string
-	NewPrimary string
-	Reason     string
-	Latency    float64
-	Baseline   float64
-	Timestamp  time.Time
+	Type       EventType `json:"type"`
+	PeerName   string    `json:"peer_name,omitempty"`
+	OldPrimary string    `json:"old_primary,omitempty"`
+	NewPrimary string    `json:"new_primary,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	Latency    float64   `json:"latency"`
+	Baseline   float64   `json:"baseline"`
+	Timestamp  time.Time `json:"timestamp"`
+	Digest     []Event   `json:"digest,omitempty"`   // The buffered events of an EventDigest, oldest first
+	EventID    int64     `json:"event_id,omitempty"` // The recorded event this notifies about, 0 if none
 }
 
 // Channel represents a notification channel (email, slack, etc.)
@@ -36,17 +42,54 @@ type Channel interface {
 	Send(event Event) error
 	IsEnabled() bool
 	ShouldNotify(eventType EventType) bool
+	AcceptsGroup(group string) bool
+}
+
+// deduplicatingChannel is implemented by channels whose service collapses repeated
+// alerts itself (PagerDuty); the notifier doesn't rate-limit them
+type deduplicatingChannel interface {
+	Deduplicates() bool
+}
+
+func deduplicates(channel Channel) bool {
+	dedup, ok := channel.(deduplicatingChannel)
+	return ok && dedup.Deduplicates()
 }
 
 // Notifier manages multiple notification channels with rate limiting
 type Notifier struct {
 	channels      []Channel
 	rateLimitMins int
+	rateLimits    map[EventType]int    // Per-type overrides of rateLimitMins
 	lastSent      map[string]time.Time // key: "channelName:eventType"
+	peerGroups    map[string]string    // peer name -> notification group
+	rateStore     RateStore            // Persists lastSent across restarts, nil to keep it in memory
+	history       HistoryStore         // Records the outcome of every send, nil to keep no history
+	digestMins    int                  // Buffer events this long and send one digest per channel, 0 = send immediately
+	pending       map[string][]Event   // key: channel name; events waiting for the digest
+	digestTimer   *time.Timer          // Fires the flush of the current digest window
 	mu            sync.RWMutex
 	logger        Logger
 }
 
+// RateStore persists rate-limit timestamps so limits survive restarts
+type RateStore interface {
+	GetNotificationRateState() (map[string]time.Time, error)
+	SetNotificationRateState(key string, lastSent time.Time) error
+}
+
+// HistoryStore records the outcome of each notification attempt
+type HistoryStore interface {
+	RecordNotification(channelType string, eventID *int64, status, message string, errorMsg *string) error
+}
+
+// Notification outcomes written to the HistoryStore
+const (
+	OutcomeSent        = "sent"
+	OutcomeFailed      = "failed"
+	OutcomeRateLimited = "rate_limited"
+)
+
 // Logger interface for logging (matches lagbuster's logger)
 type Logger interface {
 	Info(format string, args ...interface{})
@@ -79,23 +122,217 @@ func (n *Notifier) Notify(event Event) {
 			continue
 		}
 
+		// Peer events only go to channels that accept the peer's group;
+		// events without a peer (startup, shutdown) go to every channel
+		if event.PeerName != "" && !channel.AcceptsGroup(n.peerGroups[event.PeerName]) {
+			continue
+		}
+
+		// In digest mode, buffering replaces rate limiting
+		if n.digestMins > 0 && !deduplicates(channel) {
+			if n.digestTimer == nil {
+				n.digestTimer = time.AfterFunc(time.Duration(n.digestMins)*time.Minute, n.FlushDigest)
+			}
+			n.pending[channel.Name()] = append(n.pending[channel.Name()], event)
+			continue
+		}
+
 		// Check rate limiting
 		key := fmt.Sprintf("%s:%s", channel.Name(), event.Type)
-		if lastSent, exists := n.lastSent[key]; exists {
-			if time.Since(lastSent) < time.Duration(n.rateLimitMins)*time.Minute {
+		if lastSent, exists := n.lastSent[key]; exists && !deduplicates(channel) {
+			if time.Since(lastSent) < time.Duration(n.rateLimitMinutes(event.Type))*time.Minute {
 				n.logger.Debug("Rate limited: %s for %s", channel.Name(), event.Type)
+				n.recordOutcome(channel, event, OutcomeRateLimited, nil)
 				continue
 			}
 		}
 
 		// Send notification
-		if err := channel.Send(event); err != nil {
+		err := channel.Send(event)
+		n.recordSendOutcome(channel, event, err)
+		if err != nil {
 			n.logger.Error("Failed to send %s notification via %s: %v", event.Type, channel.Name(), err)
 		} else {
 			n.logger.Info("Sent %s notification via %s", event.Type, channel.Name())
 			n.lastSent[key] = time.Now()
+			if n.rateStore != nil {
+				if err := n.rateStore.SetNotificationRateState(key, n.lastSent[key]); err != nil {
+					n.logger.Warn("Could not persist notification rate limit for %s: %v", key, err)
+				}
+			}
+		}
+	}
+}
+
+// SetPeerGroups sets which notification group each peer belongs to
+func (n *Notifier) SetPeerGroups(groups map[string]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.peerGroups = groups
+}
+
+// SetRateLimits sets per-event-type rate limits in minutes; types not listed use the
+// global limit
+func (n *Notifier) SetRateLimits(limits map[EventType]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.rateLimits = limits
+}
+
+// rateLimitMinutes returns the rate limit for an event type. The caller holds n.mu.
+func (n *Notifier) rateLimitMinutes(eventType EventType) int {
+	if mins, ok := n.rateLimits[eventType]; ok {
+		return mins
+	}
+	return n.rateLimitMins
+}
+
+// SetDigest switches digest mode on (minutes > 0) or off. Events buffered so far
+// are kept for the next flush.
+func (n *Notifier) SetDigest(minutes int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.digestMins = minutes
+	if n.pending == nil {
+		n.pending = make(map[string][]Event)
+	}
+}
+
+// FlushDigest sends each channel the events buffered for it: a lone event as itself,
+// several as one EventDigest. It runs when the digest window ends and should be
+// called on shutdown so nothing buffered is lost.
+func (n *Notifier) FlushDigest() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.digestTimer != nil {
+		n.digestTimer.Stop()
+		n.digestTimer = nil
+	}
+	for _, channel := range n.channels {
+		events := n.pending[channel.Name()]
+		if len(events) == 0 {
+			continue
+		}
+		delete(n.pending, channel.Name())
+
+		event := events[0]
+		if len(events) > 1 {
+			event = Event{
+				Type:      EventDigest,
+				Reason:    fmt.Sprintf("%d events within %d min", len(events), n.digestMins),
+				Timestamp: time.Now(),
+				Digest:    events,
+			}
+		}
+		err := channel.Send(event)
+		n.recordSendOutcome(channel, event, err)
+		if err != nil {
+			n.logger.Error("Failed to send %s notification via %s: %v", event.Type, channel.Name(), err)
+		} else {
+			n.logger.Info("Sent %s notification via %s", event.Type, channel.Name())
+		}
+	}
+}
+
+// RecordHistory writes the outcome of every notification attempt from now on to store
+func (n *Notifier) RecordHistory(store HistoryStore) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.history = store
+}
+
+// recordSendOutcome records a send as sent or failed. The caller holds n.mu.
+func (n *Notifier) recordSendOutcome(channel Channel, event Event, err error) {
+	if err != nil {
+		n.recordOutcome(channel, event, OutcomeFailed, err)
+	} else {
+		n.recordOutcome(channel, event, OutcomeSent, nil)
+	}
+}
+
+// recordOutcome writes one notification attempt to the history store, if any. The
+// caller holds n.mu (read or write).
+func (n *Notifier) recordOutcome(channel Channel, event Event, status string, sendErr error) {
+	if n.history == nil {
+		return
+	}
+
+	var eventID *int64
+	if event.EventID != 0 {
+		eventID = &event.EventID
+	}
+	var errorMsg *string
+	if sendErr != nil {
+		msg := sendErr.Error()
+		errorMsg = &msg
+	}
+
+	message := string(event.Type)
+	if event.PeerName != "" {
+		message += " " + event.PeerName
+	}
+	if event.Reason != "" {
+		message += ": " + event.Reason
+	}
+
+	if err := n.history.RecordNotification(channel.Name(), eventID, status, message, errorMsg); err != nil {
+		n.logger.Warn("Could not record %s notification via %s: %v", event.Type, channel.Name(), err)
+	}
+}
+
+// digestLines describes each event of a digest on one line, using times for timestamps
+func digestLines(event Event, times *TimeFormatter) []string {
+	lines := make([]string, len(event.Digest))
+	for i, e := range event.Digest {
+		line := fmt.Sprintf("%s %s", times.Format(e.Timestamp), e.Type)
+		if e.PeerName != "" {
+			line += " " + e.PeerName
 		}
+		if e.Type == EventUnhealthy || e.Type == EventRecovery {
+			line += fmt.Sprintf(" (%.2fms, baseline %.2fms)", e.Latency, e.Baseline)
+		}
+		if e.Reason != "" {
+			line += ": " + e.Reason
+		}
+		lines[i] = line
 	}
+	return lines
+}
+
+// PersistRateLimits loads the rate-limit timestamps saved by a previous run and saves
+// every new one to store, so a restart doesn't reset rate limits
+func (n *Notifier) PersistRateLimits(store RateStore) error {
+	saved, err := store.GetNotificationRateState()
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, lastSent := range saved {
+		if lastSent.After(n.lastSent[key]) {
+			n.lastSent[key] = lastSent
+		}
+	}
+	n.rateStore = store
+	n.logger.Debug("Loaded %d notification rate limits from the database", len(saved))
+	return nil
+}
+
+// groupAllowed reports whether a channel limited to groups accepts a peer in group.
+// A channel without groups accepts every peer; a peer without a group only reaches
+// channels without groups.
+func groupAllowed(groups []string, group string) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, g := range groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
 }
 
 // AddChannel adds a new notification channel
@@ -113,8 +350,19 @@ func (n *Notifier) UpdateChannels(channels []Channel) {
 	n.logger.Info("Notification channels updated (%d channels)", len(channels))
 }
 
-// SendTest sends a test notification to a specific channel or all channels
-func (n *Notifier) SendTest(channelName string) error {
+// TestResult describes the outcome of a test notification on one channel
+type TestResult struct {
+	Channel   string  `json:"channel"`
+	OK        bool    `json:"ok"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Response  string  `json:"response,omitempty"` // Server response line, when the channel exposes one (SMTP)
+}
+
+// SendTest sends a test notification to a specific channel or all channels and
+// reports the outcome per channel. An error is returned only when no enabled
+// channel matched.
+func (n *Notifier) SendTest(channelName string) ([]TestResult, error) {
 	n.mu.RLock()
 	defer n.mu.RUnlock()
 
@@ -125,8 +373,7 @@ func (n *Notifier) SendTest(channelName string) error {
 		Timestamp: time.Now(),
 	}
 
-	var sentCount int
-	var lastError error
+	var results []TestResult
 
 	for _, channel := range n.channels {
 		// Skip if channel doesn't match (unless "all" is specified)
@@ -140,50 +387,75 @@ func (n *Notifier) SendTest(channelName string) error {
 		}
 
 		// Send test notification (bypass rate limiting and event type filtering for tests)
-		if err := channel.Send(testEvent); err != nil {
+		start := time.Now()
+		err := channel.Send(testEvent)
+		n.recordSendOutcome(channel, testEvent, err)
+		result := TestResult{
+			Channel:   channel.Name(),
+			OK:        err == nil,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
 			n.logger.Error("Failed to send test notification via %s: %v", channel.Name(), err)
-			lastError = err
+			result.Error = err.Error()
+			result.Response = smtpResponse(err)
 		} else {
 			n.logger.Info("Sent test notification via %s", channel.Name())
-			sentCount++
 		}
+		results = append(results, result)
 	}
 
-	if sentCount == 0 {
-		if lastError != nil {
-			return fmt.Errorf("test notification failed: %w", lastError)
-		}
-		return fmt.Errorf("no enabled channels found for: %s", channelName)
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no enabled channels found for: %s", channelName)
 	}
 
-	return nil
+	return results, nil
 }
 
 // MainConfig holds the top-level notifications configuration
 type MainConfig struct {
-	Enabled          bool           `yaml:"enabled"`
-	RateLimitMinutes int            `yaml:"rate_limit_minutes"`
-	Email            EmailConfig    `yaml:"email"`
-	Slack            SlackConfig    `yaml:"slack"`
-	Telegram         TelegramConfig `yaml:"telegram"`
+	Enabled                        bool              `yaml:"enabled" json:"enabled"`
+	RateLimitMinutes               int               `yaml:"rate_limit_minutes" json:"rate_limit_minutes"`
+	RateLimits                     map[EventType]int `yaml:"rate_limits" json:"rate_limits"`                                               // Per-type overrides of rate_limit_minutes
+	SuppressStartupIfRestartWithin int               `yaml:"suppress_startup_if_restart_within" json:"suppress_startup_if_restart_within"` // Minutes; 0 = always notify
+	SuppressDuringWarmup           bool              `yaml:"suppress_during_warmup" json:"suppress_during_warmup"`                         // No peer alerts until the peer has a full measurement window
+	PersistRateLimits              bool              `yaml:"persist_rate_limits" json:"persist_rate_limits"`                               // Keep rate-limit state in the database across restarts
+	Timezone                       string            `yaml:"timezone" json:"timezone"`                                                     // IANA zone for timestamps in messages, empty = server local time
+	TimeFormat                     string            `yaml:"time_format" json:"time_format"`                                               // Go time layout, empty = "2006-01-02 15:04:05"
+	DigestMinutes                  int               `yaml:"digest_minutes" json:"digest_minutes"`                                         // Batch events into one message per channel per window, 0 = send each immediately
+	Email                          EmailConfig       `yaml:"email" json:"email"`
+	Slack                          SlackConfig       `yaml:"slack" json:"slack"`
+	Telegram                       TelegramConfig    `yaml:"telegram" json:"telegram"`
+	Webhook                        WebhookConfig     `yaml:"webhook" json:"webhook"`
+	PagerDuty                      PagerDutyConfig   `yaml:"pagerduty" json:"pagerduty"`
 }
 
 // BuildChannels creates notification channels based on configuration
 func BuildChannels(config MainConfig, logger Logger) []Channel {
 	var channels []Channel
 
+	// Shared timestamp formatting for all channels
+	times, err := NewTimeFormatter(config.Timezone, config.TimeFormat)
+	if err != nil {
+		logger.Warn("%v - using server local time", err)
+		times, _ = NewTimeFormatter("", config.TimeFormat)
+	}
+
 	// Email channel
 	if config.Email.Enabled {
 		emailChan := NewEmailChannel(EmailConfig{
-			Enabled:  config.Email.Enabled,
-			SMTPHost: config.Email.SMTPHost,
-			SMTPPort: config.Email.SMTPPort,
-			Username: config.Email.Username,
-			Password: config.Email.Password,
-			From:     config.Email.From,
-			To:       config.Email.To,
-			Events:   config.Email.Events,
-		})
+			Enabled:          config.Email.Enabled,
+			SMTPHost:         config.Email.SMTPHost,
+			SMTPPort:         config.Email.SMTPPort,
+			Username:         config.Email.Username,
+			Password:         config.Email.Password,
+			From:             config.Email.From,
+			To:               config.Email.To,
+			Events:           config.Email.Events,
+			Groups:           config.Email.Groups,
+			Templates:        config.Email.Templates,
+			SubjectTemplates: config.Email.SubjectTemplates,
+		}, times)
 		channels = append(channels, emailChan)
 		logger.Info("Email notifications enabled (to: %v)", config.Email.To)
 	}
@@ -194,7 +466,9 @@ func BuildChannels(config MainConfig, logger Logger) []Channel {
 			Enabled:    config.Slack.Enabled,
 			WebhookURL: config.Slack.WebhookURL,
 			Events:     config.Slack.Events,
-		})
+			Groups:     config.Slack.Groups,
+			Templates:  config.Slack.Templates,
+		}, times)
 		channels = append(channels, slackChan)
 		logger.Info("Slack notifications enabled")
 	}
@@ -202,14 +476,42 @@ func BuildChannels(config MainConfig, logger Logger) []Channel {
 	// Telegram channel
 	if config.Telegram.Enabled {
 		telegramChan := NewTelegramChannel(TelegramConfig{
-			Enabled:  config.Telegram.Enabled,
-			BotToken: config.Telegram.BotToken,
-			ChatID:   config.Telegram.ChatID,
-			Events:   config.Telegram.Events,
-		})
+			Enabled:   config.Telegram.Enabled,
+			BotToken:  config.Telegram.BotToken,
+			ChatID:    config.Telegram.ChatID,
+			Events:    config.Telegram.Events,
+			Groups:    config.Telegram.Groups,
+			Templates: config.Telegram.Templates,
+		}, times)
 		channels = append(channels, telegramChan)
 		logger.Info("Telegram notifications enabled (chat: %s)", config.Telegram.ChatID)
 	}
 
+	// Generic webhook channel
+	if config.Webhook.Enabled {
+		webhookChan := NewWebhookChannel(WebhookConfig{
+			Enabled: config.Webhook.Enabled,
+			URL:     config.Webhook.URL,
+			Headers: config.Webhook.Headers,
+			Events:  config.Webhook.Events,
+			Groups:  config.Webhook.Groups,
+		})
+		channels = append(channels, webhookChan)
+		logger.Info("Webhook notifications enabled")
+	}
+
+	// PagerDuty channel
+	if config.PagerDuty.Enabled {
+		pagerDutyChan := NewPagerDutyChannel(PagerDutyConfig{
+			Enabled:    config.PagerDuty.Enabled,
+			RoutingKey: config.PagerDuty.RoutingKey,
+			Source:     config.PagerDuty.Source,
+			Events:     config.PagerDuty.Events,
+			Groups:     config.PagerDuty.Groups,
+		})
+		channels = append(channels, pagerDutyChan)
+		logger.Info("PagerDuty notifications enabled")
+	}
+
 	return channels
 }
@@ -0,0 +1,212 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyConfig holds PagerDuty Events API v2 configuration
+type PagerDutyConfig struct {
+	Enabled    bool        `yaml:"enabled" json:"enabled"`
+	RoutingKey string      `yaml:"routing_key" json:"routing_key"` // Integration key of the Events API v2 service
+	Source     string      `yaml:"source" json:"source"`           // Source shown on the alert, empty = hostname
+	Events     []EventType `yaml:"event_types" json:"event_types"`
+	Groups     []string    `yaml:"groups" json:"groups"` // Peer notification groups to accept, empty = all
+}
+
+// PagerDutyChannel triggers PagerDuty incidents and resolves them when the
+// matching recovery or failback arrives
+type PagerDutyChannel struct {
+	config PagerDutyConfig
+	client *http.Client
+	url    string
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // trigger or resolve
+	DedupKey    string            `json:"dedup_key,omitempty"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"` // Required for trigger only
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"` // critical, error, warning or info
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// NewPagerDutyChannel creates a new PagerDuty notification channel
+func NewPagerDutyChannel(config PagerDutyConfig) *PagerDutyChannel {
+	if config.Source == "" {
+		config.Source, _ = os.Hostname()
+	}
+	if config.Source == "" {
+		config.Source = "lagbuster"
+	}
+	return &PagerDutyChannel{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		url:    pagerDutyEventsURL,
+	}
+}
+
+// Name returns the channel name
+func (p *PagerDutyChannel) Name() string {
+	return "pagerduty"
+}
+
+// IsEnabled returns whether the channel is enabled
+func (p *PagerDutyChannel) IsEnabled() bool {
+	return p.config.Enabled
+}
+
+// AcceptsGroup returns whether this channel receives events for peers in the given group
+func (p *PagerDutyChannel) AcceptsGroup(group string) bool {
+	return groupAllowed(p.config.Groups, group)
+}
+
+// ShouldNotify returns whether this channel should notify for the given event type
+func (p *PagerDutyChannel) ShouldNotify(eventType EventType) bool {
+	for _, et := range p.config.Events {
+		if et == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Deduplicates reports that PagerDuty collapses repeated alerts itself, so the
+// notifier doesn't rate-limit this channel and no resolve is ever dropped
+func (p *PagerDutyChannel) Deduplicates() bool {
+	return true
+}
+
+// Send triggers an incident for unhealthy and switch events and resolves the
+// incident with the same dedup key on recovery and failback. The resolve is sent
+// whether or not this process triggered the incident, so ones opened before a restart
+// are closed too; PagerDuty ignores a resolve with no open incident. Other event types
+// trigger informational alerts. A test event triggers and immediately resolves.
+func (p *PagerDutyChannel) Send(event Event) error {
+	key := pagerDutyDedupKey(event)
+	switch event.Type {
+	case EventUnhealthy:
+		return p.trigger(key, "critical", event)
+	case EventSwitch:
+		return p.trigger(key, "warning", event)
+	case EventRecovery, EventFailback:
+		return p.resolve(key)
+	case EventType("test"):
+		if err := p.trigger(key, "info", event); err != nil {
+			return err
+		}
+		return p.resolve(key)
+	default:
+		return p.post(pagerDutyEvent{
+			RoutingKey:  p.config.RoutingKey,
+			EventAction: "trigger",
+			Payload:     p.payload("info", event),
+		})
+	}
+}
+
+// pagerDutyDedupKey groups an event with the one that clears it: a peer's unhealthy
+// and recovery share a key, as do a primary switch and its failback
+func pagerDutyDedupKey(event Event) string {
+	switch event.Type {
+	case EventUnhealthy, EventRecovery:
+		return "lagbuster/peer/" + event.PeerName
+	case EventSwitch, EventFailback:
+		return "lagbuster/primary"
+	default:
+		return "lagbuster/" + string(event.Type)
+	}
+}
+
+func (p *PagerDutyChannel) trigger(key, severity string, event Event) error {
+	return p.post(pagerDutyEvent{
+		RoutingKey:  p.config.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    key,
+		Payload:     p.payload(severity, event),
+	})
+}
+
+func (p *PagerDutyChannel) resolve(key string) error {
+	return p.post(pagerDutyEvent{
+		RoutingKey:  p.config.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    key,
+	})
+}
+
+func (p *PagerDutyChannel) payload(severity string, event Event) *pagerDutyPayload {
+	var summary string
+	details := map[string]string{"event_type": string(event.Type)}
+
+	switch event.Type {
+	case EventUnhealthy:
+		summary = fmt.Sprintf("Peer %s unhealthy: %s", event.PeerName, event.Reason)
+	case EventSwitch:
+		summary = fmt.Sprintf("BGP primary switched from %s to %s: %s", event.OldPrimary, event.NewPrimary, event.Reason)
+		details["old_primary"] = event.OldPrimary
+		details["new_primary"] = event.NewPrimary
+	case EventType("test"):
+		summary = "Lagbuster test notification"
+	default:
+		summary = fmt.Sprintf("Lagbuster %s: %s", event.Type, event.Reason)
+		if event.PeerName != "" {
+			summary = fmt.Sprintf("Lagbuster %s on %s: %s", event.Type, event.PeerName, event.Reason)
+		}
+	}
+	if event.PeerName != "" {
+		details["peer"] = event.PeerName
+		details["latency"] = fmt.Sprintf("%.2fms", event.Latency)
+		details["baseline"] = fmt.Sprintf("%.2fms", event.Baseline)
+	}
+	if event.Reason != "" {
+		details["reason"] = event.Reason
+	}
+
+	// PagerDuty caps summaries at 1024 characters
+	if len(summary) > 1024 {
+		summary = summary[:1021] + "..."
+	}
+
+	return &pagerDutyPayload{
+		Summary:       summary,
+		Source:        p.config.Source,
+		Severity:      severity,
+		Timestamp:     event.Timestamp.UTC().Format(time.RFC3339),
+		Component:     event.PeerName,
+		CustomDetails: details,
+	}
+}
+
+func (p *PagerDutyChannel) post(pdEvent pagerDutyEvent) error {
+	jsonData, err := json.Marshal(pdEvent)
+	if err != nil {
+		return fmt.Errorf("marshaling pagerduty event: %w", err)
+	}
+
+	resp, err := p.client.Post(p.url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("posting to pagerduty: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("pagerduty returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
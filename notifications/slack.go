@@ -5,27 +5,36 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // SlackConfig holds Slack notification configuration
 type SlackConfig struct {
-	Enabled    bool        `yaml:"enabled"`
-	WebhookURL string      `yaml:"webhook_url"`
-	Events     []EventType `yaml:"event_types"`
+	Enabled    bool                 `yaml:"enabled" json:"enabled"`
+	WebhookURL string               `yaml:"webhook_url" json:"webhook_url"`
+	Events     []EventType          `yaml:"event_types" json:"event_types"`
+	Groups     []string             `yaml:"groups" json:"groups"`       // Peer notification groups to accept, empty = all
+	Templates  map[EventType]string `yaml:"templates" json:"templates"` // text/template messages by event type, replacing the built-in attachment
 }
 
 // SlackChannel implements Slack notifications
 type SlackChannel struct {
-	config SlackConfig
-	client *http.Client
+	config    SlackConfig
+	client    *http.Client
+	times     *TimeFormatter
+	templates messageTemplates
 }
 
-// NewSlackChannel creates a new Slack notification channel
-func NewSlackChannel(config SlackConfig) *SlackChannel {
+// NewSlackChannel creates a new Slack notification channel. Templates that don't
+// parse are skipped (ValidateTemplates rejects them at config load).
+func NewSlackChannel(config SlackConfig, times *TimeFormatter) *SlackChannel {
+	templates, _ := parseTemplates("slack.templates", config.Templates, times)
 	return &SlackChannel{
-		config: config,
-		client: &http.Client{Timeout: 10 * time.Second},
+		config:    config,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		times:     times,
+		templates: templates,
 	}
 }
 
@@ -39,6 +48,11 @@ func (s *SlackChannel) IsEnabled() bool {
 	return s.config.Enabled
 }
 
+// AcceptsGroup returns whether this channel receives events for peers in the given group
+func (s *SlackChannel) AcceptsGroup(group string) bool {
+	return groupAllowed(s.config.Groups, group)
+}
+
 // ShouldNotify returns whether this channel should notify for the given event type
 func (s *SlackChannel) ShouldNotify(eventType EventType) bool {
 	for _, et := range s.config.Events {
@@ -51,7 +65,10 @@ func (s *SlackChannel) ShouldNotify(eventType EventType) bool {
 
 // Send sends a Slack notification
 func (s *SlackChannel) Send(event Event) error {
-	payload := s.formatMessage(event)
+	payload, err := s.formatMessage(event)
+	if err != nil {
+		return err
+	}
 
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
@@ -91,7 +108,20 @@ type slackAttachmentField struct {
 	Short bool   `json:"short"`
 }
 
-func (s *SlackChannel) formatMessage(event Event) slackPayload {
+// formatMessage renders the configured template for the event as plain message text,
+// falling back to the built-in attachment
+func (s *SlackChannel) formatMessage(event Event) (slackPayload, error) {
+	text, ok, err := s.templates.render(event)
+	if err != nil {
+		return slackPayload{}, err
+	}
+	if ok {
+		return slackPayload{Text: text}, nil
+	}
+	return s.builtinMessage(event), nil
+}
+
+func (s *SlackChannel) builtinMessage(event Event) slackPayload {
 	var color string
 	var title string
 	var fields []slackAttachmentField
@@ -139,11 +169,30 @@ func (s *SlackChannel) formatMessage(event Event) slackPayload {
 		color = "#808080"
 		title = "🛑 Lagbuster Stopped"
 
+	case EventBirdRollback:
+		color = "danger"
+		title = "⏪ Bird Reload Failed - Rolled Back"
+		fields = []slackAttachmentField{
+			{Title: "Details", Value: event.Reason, Short: false},
+		}
+
+	case EventDigest:
+		color = "warning"
+		title = fmt.Sprintf("📋 Notification Digest: %s", event.Reason)
+		fields = []slackAttachmentField{
+			{Title: "Events", Value: strings.Join(digestLines(event, s.times), "\n"), Short: false},
+		}
+
 	default:
 		color = "#808080"
 		title = fmt.Sprintf("Event: %s", event.Type)
 	}
 
+	// Slack renders ts in each reader's own zone; show the configured zone explicitly too
+	fields = append([]slackAttachmentField{
+		{Title: "Time", Value: s.times.Format(event.Timestamp), Short: true},
+	}, fields...)
+
 	return slackPayload{
 		Attachments: []slackAttachment{
 			{
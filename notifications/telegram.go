@@ -5,28 +5,37 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // TelegramConfig holds Telegram notification configuration
 type TelegramConfig struct {
-	Enabled  bool        `yaml:"enabled"`
-	BotToken string      `yaml:"bot_token"`
-	ChatID   string      `yaml:"chat_id"`
-	Events   []EventType `yaml:"event_types"`
+	Enabled   bool                 `yaml:"enabled" json:"enabled"`
+	BotToken  string               `yaml:"bot_token" json:"bot_token"`
+	ChatID    string               `yaml:"chat_id" json:"chat_id"`
+	Events    []EventType          `yaml:"event_types" json:"event_types"`
+	Groups    []string             `yaml:"groups" json:"groups"`       // Peer notification groups to accept, empty = all
+	Templates map[EventType]string `yaml:"templates" json:"templates"` // text/template messages (Telegram HTML) by event type, replacing the built-in text
 }
 
 // TelegramChannel implements Telegram notifications
 type TelegramChannel struct {
-	config TelegramConfig
-	client *http.Client
+	config    TelegramConfig
+	client    *http.Client
+	times     *TimeFormatter
+	templates messageTemplates
 }
 
-// NewTelegramChannel creates a new Telegram notification channel
-func NewTelegramChannel(config TelegramConfig) *TelegramChannel {
+// NewTelegramChannel creates a new Telegram notification channel. Templates that
+// don't parse are skipped (ValidateTemplates rejects them at config load).
+func NewTelegramChannel(config TelegramConfig, times *TimeFormatter) *TelegramChannel {
+	templates, _ := parseTemplates("telegram.templates", config.Templates, times)
 	return &TelegramChannel{
-		config: config,
-		client: &http.Client{Timeout: 10 * time.Second},
+		config:    config,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		times:     times,
+		templates: templates,
 	}
 }
 
@@ -40,6 +49,11 @@ func (t *TelegramChannel) IsEnabled() bool {
 	return t.config.Enabled
 }
 
+// AcceptsGroup returns whether this channel receives events for peers in the given group
+func (t *TelegramChannel) AcceptsGroup(group string) bool {
+	return groupAllowed(t.config.Groups, group)
+}
+
 // ShouldNotify returns whether this channel should notify for the given event type
 func (t *TelegramChannel) ShouldNotify(eventType EventType) bool {
 	for _, et := range t.config.Events {
@@ -52,7 +66,10 @@ func (t *TelegramChannel) ShouldNotify(eventType EventType) bool {
 
 // Send sends a Telegram notification
 func (t *TelegramChannel) Send(event Event) error {
-	message := t.formatMessage(event)
+	message, err := t.formatMessage(event)
+	if err != nil {
+		return err
+	}
 
 	payload := map[string]interface{}{
 		"chat_id":    t.config.ChatID,
@@ -89,8 +106,21 @@ func (t *TelegramChannel) Send(event Event) error {
 	return nil
 }
 
-func (t *TelegramChannel) formatMessage(event Event) string {
-	timestamp := event.Timestamp.Format("2006-01-02 15:04:05")
+// formatMessage renders the configured template for the event, falling back to the
+// built-in message
+func (t *TelegramChannel) formatMessage(event Event) (string, error) {
+	text, ok, err := t.templates.render(event)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return text, nil
+	}
+	return t.builtinMessage(event), nil
+}
+
+func (t *TelegramChannel) builtinMessage(event Event) string {
+	timestamp := t.times.Format(event.Timestamp)
 
 	switch event.Type {
 	case EventSwitch:
@@ -137,6 +167,20 @@ The BGP path optimization service has started.`, timestamp)
 
 The BGP path optimization service has been stopped.`, timestamp)
 
+	case EventBirdRollback:
+		return fmt.Sprintf(`⏪ <b>Bird Reload Failed - Rolled Back</b>
+
+<b>Time:</b> %s
+<b>Details:</b> %s`, timestamp, event.Reason)
+
+	case EventDigest:
+		return fmt.Sprintf(`📋 <b>Notification Digest</b>
+
+<b>Time:</b> %s
+<b>Summary:</b> %s
+
+%s`, timestamp, event.Reason, strings.Join(digestLines(event, t.times), "\n"))
+
 	case "test":
 		return fmt.Sprintf(`🧪 <b>Test Notification</b>
 
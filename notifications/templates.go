@@ -0,0 +1,81 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// messageTemplates are a channel's parsed message templates, keyed by event type.
+// Event types without one use the channel's built-in format.
+type messageTemplates map[EventType]*template.Template
+
+// parseTemplates parses the text/template sources configured for a channel. Templates
+// get the Event as data and a "time" function that formats timestamps like the
+// built-in messages. Every template that parses is returned, with the first error.
+func parseTemplates(name string, sources map[EventType]string, times *TimeFormatter) (messageTemplates, error) {
+	funcs := template.FuncMap{"time": func(t time.Time) string { return times.Format(t) }}
+	templates := make(messageTemplates, len(sources))
+	var firstErr error
+	for eventType, source := range sources {
+		tmpl, err := template.New(name + "." + string(eventType)).Funcs(funcs).Parse(source)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("notifications.%s %s template: %w", name, eventType, err)
+			}
+			continue
+		}
+		templates[eventType] = tmpl
+	}
+	return templates, firstErr
+}
+
+// render executes the template for the event's type. ok is false when the channel
+// has no template for it.
+func (t messageTemplates) render(event Event) (text string, ok bool, err error) {
+	tmpl, ok := t[event.Type]
+	if !ok {
+		return "", false, nil
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", true, fmt.Errorf("rendering %s template: %w", event.Type, err)
+	}
+	return strings.TrimSpace(buf.String()), true, nil
+}
+
+// ValidateTemplates parses every configured message template and renders it against
+// a sample event, so a typo in a field name fails at startup rather than in an alert
+func ValidateTemplates(config MainConfig) error {
+	sample := Event{
+		Type:      EventUnhealthy,
+		PeerName:  "peer",
+		Reason:    "reason",
+		Timestamp: time.Now(),
+		Digest:    []Event{{Type: EventUnhealthy, PeerName: "peer", Timestamp: time.Now()}},
+	}
+	channels := []struct {
+		name    string
+		sources map[EventType]string
+	}{
+		{"email.templates", config.Email.Templates},
+		{"email.subject_templates", config.Email.SubjectTemplates},
+		{"slack.templates", config.Slack.Templates},
+		{"telegram.templates", config.Telegram.Templates},
+	}
+	for _, channel := range channels {
+		templates, err := parseTemplates(channel.name, channel.sources, nil)
+		if err != nil {
+			return err
+		}
+		for eventType, tmpl := range templates {
+			sample.Type = eventType
+			if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+				return fmt.Errorf("notifications.%s %s template: %w", channel.name, eventType, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package notifications
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimeFormat is the layout used when notifications.time_format is not set
+const DefaultTimeFormat = "2006-01-02 15:04:05"
+
+// TimeFormatter renders event timestamps consistently across channels
+type TimeFormatter struct {
+	location *time.Location
+	layout   string
+}
+
+// NewTimeFormatter resolves notifications.timezone (an IANA name such as "UTC" or
+// "Europe/Stockholm"; empty means the server's local zone) and notifications.time_format
+// (a Go time layout; empty means DefaultTimeFormat)
+func NewTimeFormatter(timezone, layout string) (*TimeFormatter, error) {
+	location := time.Local
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid notifications.timezone %q: %w", timezone, err)
+		}
+		location = loc
+	}
+
+	if layout == "" {
+		layout = DefaultTimeFormat
+	}
+
+	return &TimeFormatter{location: location, layout: layout}, nil
+}
+
+// Format renders t in the configured zone and layout. A nil formatter uses the defaults.
+func (f *TimeFormatter) Format(t time.Time) string {
+	if f == nil {
+		return t.Format(DefaultTimeFormat)
+	}
+	return t.In(f.location).Format(f.layout)
+}
@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// WebhookConfig holds generic JSON webhook configuration
+type WebhookConfig struct {
+	Enabled bool              `yaml:"enabled" json:"enabled"`
+	URL     string            `yaml:"url" json:"url"`
+	Headers map[string]string `yaml:"headers" json:"headers"` // Extra request headers, e.g. Authorization
+	Events  []EventType       `yaml:"event_types" json:"event_types"`
+	Groups  []string          `yaml:"groups" json:"groups"` // Peer notification groups to accept, empty = all
+}
+
+// WebhookChannel POSTs events as JSON to an arbitrary URL
+type WebhookChannel struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookChannel creates a new webhook notification channel
+func NewWebhookChannel(config WebhookConfig) *WebhookChannel {
+	return &WebhookChannel{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the channel name
+func (w *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// IsEnabled returns whether the channel is enabled
+func (w *WebhookChannel) IsEnabled() bool {
+	return w.config.Enabled
+}
+
+// AcceptsGroup returns whether this channel receives events for peers in the given group
+func (w *WebhookChannel) AcceptsGroup(group string) bool {
+	return groupAllowed(w.config.Groups, group)
+}
+
+// ShouldNotify returns whether this channel should notify for the given event type
+func (w *WebhookChannel) ShouldNotify(eventType EventType) bool {
+	for _, et := range w.config.Events {
+		if et == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Send POSTs the event as JSON; any non-2xx response is an error
+func (w *WebhookChannel) Send(event Event) error {
+	jsonData, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshaling webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.config.URL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("creating webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"lagbuster/notifications"
+	"math"
+	"net"
+	"time"
+)
+
+// One-way delay (OWD) probing
+//
+// Peers with an owd_responder configured get an extra UDP probe each cycle, sent to a
+// companion lagbuster instance running with -owd-responder. The responder stamps the
+// packet with its own receive time, which splits the round trip into a forward and a
+// reverse leg. This relies on both clocks being NTP-synchronized; clock offset shows up
+// directly as asymmetry. The ICMP latency used for health decisions is unaffected.
+
+var owdMagic = []byte("LBOW")
+
+const (
+	owdRequestSize = 12 // magic + client send time (unix ns)
+	owdReplySize   = 20 // request + responder receive time (unix ns)
+)
+
+// OWDResult holds the legs of a one-way delay measurement in milliseconds
+type OWDResult struct {
+	Forward float64 // client → responder
+	Reverse float64 // responder → client
+}
+
+// Asymmetry returns forward minus reverse delay; positive means the outbound path is slower
+func (r OWDResult) Asymmetry() float64 {
+	return r.Forward - r.Reverse
+}
+
+// measureOWD sends one timestamped UDP probe to the responder and splits the round trip
+func measureOWD(responder string) (OWDResult, error) {
+	conn, err := net.DialTimeout("udp", responder, 3*time.Second)
+	if err != nil {
+		return OWDResult{}, fmt.Errorf("dialing responder: %w", err)
+	}
+	defer conn.Close()
+
+	request := make([]byte, owdRequestSize)
+	copy(request, owdMagic)
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[4:], uint64(sent.UnixNano()))
+
+	conn.SetDeadline(sent.Add(3 * time.Second))
+	if _, err := conn.Write(request); err != nil {
+		return OWDResult{}, fmt.Errorf("sending probe: %w", err)
+	}
+
+	reply := make([]byte, owdReplySize)
+	n, err := conn.Read(reply)
+	received := time.Now()
+	if err != nil {
+		return OWDResult{}, fmt.Errorf("reading reply: %w", err)
+	}
+	if n != owdReplySize || !bytes.Equal(reply[:owdRequestSize], request) {
+		return OWDResult{}, fmt.Errorf("unexpected reply from responder")
+	}
+
+	remote := time.Unix(0, int64(binary.BigEndian.Uint64(reply[owdRequestSize:])))
+	return OWDResult{
+		Forward: float64(remote.Sub(sent)) / float64(time.Millisecond),
+		Reverse: float64(received.Sub(remote)) / float64(time.Millisecond),
+	}, nil
+}
+
+// runOWDResponder answers OWD probes on the given UDP address until the process exits
+func runOWDResponder(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", addr, err)
+	}
+	defer conn.Close()
+
+	logger.Info("OWD responder listening on %s", conn.LocalAddr())
+
+	buf := make([]byte, 64)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		receivedAt := time.Now()
+		if err != nil {
+			return fmt.Errorf("reading probe: %w", err)
+		}
+		if n != owdRequestSize || !bytes.Equal(buf[:4], owdMagic) {
+			continue
+		}
+
+		reply := make([]byte, owdReplySize)
+		copy(reply, buf[:owdRequestSize])
+		binary.BigEndian.PutUint64(reply[owdRequestSize:], uint64(receivedAt.UnixNano()))
+		if _, err := conn.WriteTo(reply, from); err != nil {
+			logger.Debug("Failed to answer OWD probe from %s: %v", from, err)
+		}
+	}
+}
+
+// checkPathAsymmetry records a peer's one-way delay measurement and fires a
+// path_asymmetry event when the asymmetry crosses thresholds.max_path_asymmetry
+func checkPathAsymmetry(state *AppState, peer *PeerState, result OWDResult, err error) {
+	if err != nil {
+		logger.Debug("OWD probe to %s (%s) failed: %v", peer.Config.Name, peer.Config.OWDResponder, err)
+		peer.PathAsymmetry = nil
+		return
+	}
+
+	asymmetry := result.Asymmetry()
+	peer.PathAsymmetry = &asymmetry
+
+	threshold := state.Config.Thresholds.MaxPathAsymmetry
+	if threshold <= 0 {
+		return
+	}
+
+	exceeded := math.Abs(asymmetry) > threshold
+	if exceeded == peer.AsymmetryExceeded {
+		return
+	}
+	peer.AsymmetryExceeded = exceeded
+
+	name := peer.Config.Name
+	if !exceeded {
+		logger.Info("Peer %s path asymmetry back within threshold: %.2fms", name, asymmetry)
+		return
+	}
+
+	reason := fmt.Sprintf("path asymmetry %.2fms exceeds %.2fms (forward=%.2fms, reverse=%.2fms)",
+		asymmetry, threshold, result.Forward, result.Reverse)
+	logger.Warn("Peer %s: %s", name, reason)
+	eventID := recordEvent(state, "path_asymmetry", &name, nil, nil, reason, nil)
+
+	if state.notifier != nil && !peerWarmingUp(state, peer, "path asymmetry") {
+		state.notifier.Notify(notifications.Event{
+			Type:      notifications.EventPathAsymmetry,
+			PeerName:  name,
+			Latency:   peer.CurrentLatency,
+			Baseline:  peerBaseline(peer.Config),
+			Reason:    reason,
+			Timestamp: time.Now(),
+			EventID:   eventID,
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "fmt"
+
+// pathCost folds a measurement's packet loss and jitter into its latency: each percent
+// of loss adds thresholds.loss_penalty_ms_per_percent and each ms of jitter adds
+// thresholds.jitter_penalty ms, so a fast but lossy or unstable path is judged against
+// the latency thresholds as if it were slower. Unanswered samples stay -1.
+func pathCost(latency float64, quality sampleQuality, thresholds ThresholdConfig) float64 {
+	if latency < 0 {
+		return latency
+	}
+	return latency + lossCost(quality, thresholds) + jitterCost(quality, thresholds)
+}
+
+// lossCost is the ms a measurement's packet loss adds to its path cost
+func lossCost(quality sampleQuality, thresholds ThresholdConfig) float64 {
+	if thresholds.LossPenalty <= 0 {
+		return 0
+	}
+	return quality.PacketLoss * 100 * thresholds.LossPenalty
+}
+
+// jitterCost is the ms a measurement's jitter adds to its path cost
+func jitterCost(quality sampleQuality, thresholds ThresholdConfig) float64 {
+	if thresholds.JitterPenalty <= 0 {
+		return 0
+	}
+	return quality.Jitter * thresholds.JitterPenalty
+}
+
+// pathCostNote describes the penalties in a path cost, empty when there are none
+func pathCostNote(latency float64, quality sampleQuality, thresholds ThresholdConfig) string {
+	loss, jitter := lossCost(quality, thresholds), jitterCost(quality, thresholds)
+	if latency < 0 || loss+jitter == 0 {
+		return ""
+	}
+	note := fmt.Sprintf(" (path cost: %.2fms latency", latency)
+	if loss > 0 {
+		note += fmt.Sprintf(" + %.2fms for %.0f%% loss", loss, quality.PacketLoss*100)
+	}
+	if jitter > 0 {
+		note += fmt.Sprintf(" + %.2fms for %.2fms jitter", jitter, quality.Jitter)
+	}
+	return note + ")"
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"sort"
+
+	"lagbuster/api"
+)
+
+// setPeerDisabled takes a peer out of ECMP for maintenance, or lets it back in. A
+// disabled peer is still probed, recorded and health-checked, but gets priority 99
+// whatever its health. The change is applied straight away rather than at the next
+// decision cycle, unless routing is frozen. The flag is not persisted: a restart
+// enables every peer again.
+func setPeerDisabled(state *AppState, name string, disabled bool) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	peer, ok := state.Peers[name]
+	if !ok {
+		return api.ErrPeerNotFound
+	}
+	if peer.Disabled == disabled {
+		return nil
+	}
+	peer.Disabled = disabled
+
+	eventType, reason := "peer_enabled", "enabled via API"
+	if disabled {
+		eventType, reason = "peer_disabled", "disabled via API"
+	}
+	if state.frozen.Load() {
+		reason += " (routing frozen: takes effect on unfreeze)"
+	}
+	logger.Warn("Peer %s %s", name, reason)
+	recordEvent(state, eventType, &name, nil, nil, reason, nil)
+
+	applyRouting(state)
+	updateAPIServerState(state)
+	return nil
+}
+
+// disabledPeers lists the disabled peers by name, for the decision trace
+func disabledPeers(state *AppState) []string {
+	var names []string
+	for name, peer := range state.Peers {
+		if peer.Disabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PriorityReport describes what each peer's priority is and should be
+type PriorityReport struct {
+	Frozen        bool                    `json:"frozen"`
+	Mode          string                  `json:"mode"` // "bird" or "exabgp"
+	Peers         map[string]PeerPriority `json:"peers"`
+	InSync        bool                    `json:"in_sync"`                   // Applied values match the computed ones
+	BirdFile      string                  `json:"bird_file,omitempty"`       // Priorities file checked in Bird mode
+	BirdFileMatch *bool                   `json:"bird_file_match,omitempty"` // Whether the live file defines the computed values
+	BirdFileError string                  `json:"bird_file_error,omitempty"`
+}
+
+// PeerPriority is one peer's entry in a PriorityReport
+type PeerPriority struct {
+	Computed     int    `json:"computed"`            // assignPriorities against current state
+	Effective    int    `json:"effective"`           // What the next apply will use (differs from computed while frozen)
+	Applied      *int   `json:"applied,omitempty"`   // Last successfully applied value
+	BirdFile     string `json:"bird_file,omitempty"` // Value currently defined in the Bird file
+	BirdVariable string `json:"bird_variable,omitempty"`
+}
+
+var birdDefinePattern = regexp.MustCompile(`(?m)^\s*define\s+(\w+)\s*=\s*([^;]+);`)
+
+// priorityReport compares computed, effective and applied priorities, and in Bird mode
+// the values actually present in the priorities file
+func priorityReport(state *AppState) PriorityReport {
+	state.mu.Lock()
+	computed := assignPriorities(state)
+	effective := priorityAssignment(state)
+	report := PriorityReport{
+		Frozen: state.frozen.Load(),
+		Mode:   "bird",
+		Peers:  make(map[string]PeerPriority, len(computed)),
+		InSync: state.appliedPriorities != nil,
+	}
+	for _, peerConfig := range state.Config.Peers {
+		name := peerConfig.Name
+		entry := PeerPriority{Computed: computed[name], Effective: effective[name]}
+		if applied, ok := state.appliedPriorities[name]; ok {
+			value := applied
+			entry.Applied = &value
+		}
+		if entry.Applied == nil || *entry.Applied != entry.Effective {
+			report.InSync = false
+		}
+		if !state.Config.ExaBGP.Enabled {
+			entry.BirdVariable = peerConfig.BirdVariable
+		}
+		report.Peers[name] = entry
+	}
+	birdConfig := state.Config.Bird
+	exabgpMode := state.Config.ExaBGP.Enabled
+	state.mu.Unlock()
+
+	if exabgpMode {
+		report.Mode = "exabgp"
+		return report
+	}
+
+	// Read the live file outside the state lock
+	report.BirdFile = birdConfig.PrioritiesFile
+	content, err := os.ReadFile(birdConfig.PrioritiesFile)
+	if err != nil {
+		report.BirdFileError = err.Error()
+		return report
+	}
+
+	defined := make(map[string]string)
+	for _, match := range birdDefinePattern.FindAllStringSubmatch(string(content), -1) {
+		defined[match[1]] = strings.TrimSpace(match[2])
+	}
+
+	match := true
+	for name, entry := range report.Peers {
+		entry.BirdFile = defined[entry.BirdVariable]
+		if entry.BirdFile != birdPriorityValue(birdConfig, entry.Effective) {
+			match = false
+		}
+		report.Peers[name] = entry
+	}
+	report.BirdFileMatch = &match
+
+	return report
+}
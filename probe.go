@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Probe result classes produced by classifying an exec'd ping
+const (
+	probeReachable = "reachable"
+	probeTimeout   = "timeout"
+	probeDNSError  = "dns_error"
+	probeOther     = "other"
+)
+
+// ProbeConfig controls how probes are sent and how their results are interpreted
+type ProbeConfig struct {
+	// How probes are sent: exec (default) runs the system ping binary, icmp sends echo
+	// requests natively without ping or output parsing (classifiers don't apply), tcp
+	// times a TCP handshake to Port for hosts that drop ICMP, http times the first byte
+	// of a GET to URL
+	Method string `yaml:"method" json:"method"`
+
+	// TCP port for method tcp, unless a peer sets probe_port
+	Port int `yaml:"port" json:"port"`
+
+	// TCP ports for method tcp, probed at once in place of Port, e.g. a BGP session and
+	// a service port; unless a peer sets probe_ports or probe_port. The latency is the
+	// time by which PortQuorum of them connected (0 = all); with fewer the probe fails.
+	Ports      []int `yaml:"ports" json:"ports"`
+	PortQuorum int   `yaml:"port_quorum" json:"port_quorum"`
+
+	// Local port, or "first-last" range, that tcp probes are sent from, for firewalls
+	// that only pass known source ports; unless a peer sets probe_source_port. Ports in
+	// use are skipped. Empty = any port the OS picks.
+	SourcePort string `yaml:"source_port" json:"source_port"`
+
+	// URL for method http, always the peer's own probe_url (set by peerProbeConfig)
+	URL string `yaml:"-" json:"-"`
+
+	// Address family, always the peer's own probe_family (set by peerProbeConfig)
+	Family string `yaml:"-" json:"-"`
+
+	// Extra regular expressions per class (reachable, timeout, dns_error, other), matched
+	// against ping output before the built-in rules. Use this for ping implementations
+	// with unusual wording or exit codes.
+	Classifiers map[string][]string `yaml:"classifiers" json:"classifiers"`
+
+	// Drop the first successful probe to each peer after startup or after its hostname
+	// resolves to different addresses; it tends to include ARP/neighbor discovery time
+	DiscardFirst bool `yaml:"discard_first" json:"discard_first"`
+
+	// What to do with hostnames that resolve only to IPv6 addresses: "probe" (default)
+	// pings them over IPv6 (ping -6, or ping6 on macOS); "error" fails the probe with a
+	// clear message, for deployments that mean to probe over IPv4 only. Peers with a
+	// probe_family are probed as configured regardless.
+	IPv6OnlyHosts string `yaml:"ipv6_only_hosts" json:"ipv6_only_hosts"`
+
+	// Minimum milliseconds between any two probes to the same target (peers can override
+	// with probe_min_spacing). Scheduled probes that would come sooner are rejected and
+	// logged; canaries, baselines and reference checks wait for their slot. 0 = no limit.
+	MinSpacing int `yaml:"min_spacing" json:"min_spacing"`
+
+	// IP TTL (hop limit) set on outgoing probes, 0 = the system default
+	TTL int `yaml:"ttl" json:"ttl"`
+
+	// Fire a ttl_change event when the median reply TTL over the measurement window moves
+	// by at least this many hops, a sign the path changed. 0 = disabled.
+	TTLChangeThreshold int `yaml:"ttl_change_threshold" json:"ttl_change_threshold"`
+}
+
+// Handling of IPv6-only hosts (probe.ipv6_only_hosts)
+const (
+	ipv6OnlyProbe = "probe"
+	ipv6OnlyError = "error"
+)
+
+// probeErrIPv6Only is the LastProbeError for IPv6-only hosts when they aren't probed
+const probeErrIPv6Only = "IPv6-only host but IPv4 probing configured"
+
+// Built-in output patterns, checked after any configured classifiers
+var defaultPingClassifiers = map[string][]string{
+	probeDNSError: {
+		`(?i)unknown host`,
+		`(?i)name or service not known`,
+		`(?i)cannot resolve`,
+		`(?i)temporary failure in name resolution`,
+		`(?i)no address associated with hostname`,
+	},
+	probeTimeout: {
+		`100(\.0)?% packet loss`,
+		`(?i)request timeout`,
+	},
+	probeOther: {
+		`(?i)unreachable`,
+		`(?i)operation not permitted`,
+	},
+}
+
+// Order in which classes are tried; reachable first so operators can rescue
+// replies from pings that exit non-zero despite answering
+var probeClassOrder = []string{probeReachable, probeDNSError, probeTimeout, probeOther}
+
+type classifierRule struct {
+	class   string
+	pattern *regexp.Regexp
+}
+
+// probeClassifier turns an exec'd ping's exit status and output into a probe class
+type probeClassifier struct {
+	rules []classifierRule
+}
+
+// newProbeClassifier compiles configured classifiers ahead of the built-in ones
+func newProbeClassifier(config ProbeConfig) (*probeClassifier, error) {
+	c := &probeClassifier{}
+
+	for class := range config.Classifiers {
+		if !isProbeClass(class) {
+			return nil, fmt.Errorf("probe.classifiers: unknown class %q (expected reachable, timeout, dns_error or other)", class)
+		}
+	}
+
+	for _, source := range []map[string][]string{config.Classifiers, defaultPingClassifiers} {
+		for _, class := range probeClassOrder {
+			for _, expr := range source[class] {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					return nil, fmt.Errorf("probe.classifiers.%s: invalid pattern %q: %w", class, expr, err)
+				}
+				c.rules = append(c.rules, classifierRule{class: class, pattern: re})
+			}
+		}
+	}
+
+	return c, nil
+}
+
+func isProbeClass(class string) bool {
+	for _, known := range probeClassOrder {
+		if class == known {
+			return true
+		}
+	}
+	return false
+}
+
+// classify returns the probe class for a finished ping command
+func (c *probeClassifier) classify(output string, err error) string {
+	for _, rule := range c.rules {
+		if rule.pattern.MatchString(output) {
+			return rule.class
+		}
+	}
+
+	if err == nil {
+		return probeReachable
+	}
+
+	// Fall back to conventional exit codes: iputils uses 1 for "no reply" and 2 for
+	// other errors, BSD/macOS uses 2 for "no reply" and 68 for unknown host
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case 1:
+			return probeTimeout
+		case 68:
+			return probeDNSError
+		}
+	}
+	return probeOther
+}
+
+// probeErrorMessage maps a failed probe class to the LastProbeError shown to operators
+func probeErrorMessage(class string, output string, err error) string {
+	switch class {
+	case probeTimeout:
+		return "timeout"
+	case probeDNSError:
+		return "dns lookup failed"
+	default:
+		if strings.Contains(strings.ToLower(output), "unreachable") {
+			return "destination unreachable"
+		}
+		if err != nil {
+			return fmt.Sprintf("ping failed: %v", err)
+		}
+		return "ping failed"
+	}
+}
+
+// validateIPv6OnlyHosts checks probe.ipv6_only_hosts
+func validateIPv6OnlyHosts(config ProbeConfig) error {
+	switch config.IPv6OnlyHosts {
+	case "", ipv6OnlyProbe, ipv6OnlyError:
+		return nil
+	default:
+		return fmt.Errorf("probe.ipv6_only_hosts must be probe or error, got %q", config.IPv6OnlyHosts)
+	}
+}
+
+// Address families a peer can be probed over (peers[].probe_family)
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+	familyAuto = "auto" // IPv6 when the host has an AAAA record, otherwise IPv4
+)
+
+// probeOverIPv6 reports whether a probe to host should use IPv6. Without a
+// probe_family that is only the case for IPv6-only hosts, as before per-peer families.
+func probeOverIPv6(ctx context.Context, host, family string) bool {
+	switch family {
+	case familyIPv4:
+		return false
+	case familyIPv6:
+		return true
+	case familyAuto:
+		return hasIPv6Addr(ctx, host)
+	default:
+		return isIPv6OnlyHost(ctx, host)
+	}
+}
+
+// hasIPv6Addr reports whether host is an IPv6 literal or a hostname with an AAAA
+// record. Lookup failures report false so the probe itself surfaces the DNS problem.
+func hasIPv6Addr(ctx context.Context, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() == nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isIPv6OnlyHost reports whether host is an IPv6 literal or a hostname with only AAAA
+// records. Lookup failures report false so ping itself surfaces the DNS problem.
+func isIPv6OnlyHost(ctx context.Context, host string) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4() == nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return false
+	}
+	for _, addr := range addrs {
+		if addr.IP.To4() != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshWarmup re-resolves the peer's hostname when probe.discard_first is set and
+// marks the peer cold again if its addresses changed. Lookup failures keep the
+// previous addresses; the ping itself will report the DNS problem.
+func refreshWarmup(config ProbeConfig, peer *PeerState) {
+	if !config.DiscardFirst {
+		return
+	}
+
+	addrs, err := net.LookupHost(peer.Config.Hostname)
+	if err != nil {
+		return
+	}
+	sort.Strings(addrs)
+	resolved := strings.Join(addrs, ",")
+
+	if peer.resolvedAddrs != "" && peer.resolvedAddrs != resolved {
+		logger.Debug("Peer %s now resolves to %s (was %s), discarding next probe", peer.Config.Name, resolved, peer.resolvedAddrs)
+		peer.warmedUp = false
+	}
+	peer.resolvedAddrs = resolved
+}
+
+// discardWarmupProbe reports whether a probe result is the peer's warmup probe and
+// should be dropped (the caller probes again). Failed probes are never discarded so
+// dead peers are noticed at once.
+func discardWarmupProbe(config ProbeConfig, peer *PeerState, latency float64) bool {
+	if !config.DiscardFirst || peer.warmedUp || latency < 0 {
+		return false
+	}
+	peer.warmedUp = true
+	logger.Debug("Peer %s: discarding warmup probe (%.2fms)", peer.Config.Name, latency)
+	return true
+}
+
+// validateProbeMethods checks probe.method, peers[].probe_method and probe_family, and
+// that every peer probed over TCP has a port
+func validateProbeMethods(config Config) error {
+	check := func(where, method string) error {
+		switch method {
+		case "", probeMethodExec, probeMethodICMP, probeMethodTCP, probeMethodHTTP:
+			return nil
+		default:
+			return fmt.Errorf("%s must be exec, icmp, tcp or http, got %q", where, method)
+		}
+	}
+	validPort := func(port int) bool { return port > 0 && port <= 65535 }
+	checkPorts := func(where string, ports []int, quorum int) error {
+		for _, port := range ports {
+			if !validPort(port) {
+				return fmt.Errorf("%s: port %d is not between 1 and 65535", where, port)
+			}
+		}
+		if quorum < 0 || quorum > len(ports) {
+			return fmt.Errorf("%s: quorum must be between 0 (all) and the number of ports, got %d", where, quorum)
+		}
+		return nil
+	}
+
+	if err := check("probe.method", config.Probe.Method); err != nil {
+		return err
+	}
+	if config.Probe.Port != 0 && !validPort(config.Probe.Port) {
+		return fmt.Errorf("probe.port must be between 1 and 65535")
+	}
+	if config.Probe.SourcePort != "" {
+		if _, _, err := parseSourcePorts(config.Probe.SourcePort); err != nil {
+			return fmt.Errorf("probe.source_port: %w", err)
+		}
+	}
+	if err := checkPorts("probe.ports", config.Probe.Ports, config.Probe.PortQuorum); err != nil {
+		return err
+	}
+	if config.Probe.Method == probeMethodTCP && len(config.Reference.Targets) > 0 && config.Probe.Port == 0 && len(config.Probe.Ports) == 0 {
+		return fmt.Errorf("probe.method tcp needs probe.port or probe.ports for the reference targets")
+	}
+	if config.Probe.Method == probeMethodHTTP && len(config.Reference.Targets) > 0 {
+		return fmt.Errorf("probe.method http can't probe reference targets, set probe_method per peer instead")
+	}
+	for _, peer := range config.Peers {
+		if err := check("peer "+peer.Name+": probe_method", peer.ProbeMethod); err != nil {
+			return err
+		}
+		switch peer.ProbeFamily {
+		case "", familyIPv4, familyIPv6, familyAuto:
+		default:
+			return fmt.Errorf("peer %s: probe_family must be ipv4, ipv6 or auto, got %q", peer.Name, peer.ProbeFamily)
+		}
+		probe := peerProbeConfig(config, peer)
+		if err := checkPorts("peer "+peer.Name+": probe_ports", probe.Ports, probe.PortQuorum); err != nil {
+			return err
+		}
+		if probe.Method == probeMethodTCP && len(probe.Ports) == 0 && !validPort(probe.Port) {
+			return fmt.Errorf("peer %s: tcp probing needs probe_port or probe_ports (or probe.port/probe.ports) between 1 and 65535", peer.Name)
+		}
+		if probe.SourcePort != "" {
+			if _, _, err := parseSourcePorts(probe.SourcePort); err != nil {
+				return fmt.Errorf("peer %s: probe_source_port: %w", peer.Name, err)
+			}
+		}
+		if probe.Method == probeMethodHTTP {
+			if err := validateProbeURL(peer); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// peerProbeConfig returns the probe settings for a peer: probe.* with its own
+// probe_method, probe_port, probe_ports, probe_source_port, probe_url and probe_family
+// applied
+func peerProbeConfig(config Config, peer PeerConfig) ProbeConfig {
+	probe := config.Probe
+	if peer.ProbeMethod != "" {
+		probe.Method = peer.ProbeMethod
+	}
+	// A peer's own port or port list replaces both global settings
+	if peer.ProbePort != 0 || len(peer.ProbePorts) > 0 {
+		probe.Port, probe.Ports, probe.PortQuorum = peer.ProbePort, peer.ProbePorts, peer.ProbePortQuorum
+	}
+	if peer.ProbeSourcePort != "" {
+		probe.SourcePort = peer.ProbeSourcePort
+	}
+	probe.URL = peer.ProbeURL
+	probe.Family = peer.ProbeFamily
+	return probe
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"lagbuster/stats"
+)
+
+// defaultMinSamplesForStats applies when damping.min_samples_for_stats is unset
+const defaultMinSamplesForStats = 3
+
+// sampleQuality is what one measurement shows beyond its mean latency
+type sampleQuality struct {
+	PacketLoss float64         // Fraction of the echoes that got no reply
+	Jitter     float64         // Standard deviation of the answered echoes' RTT in ms, 0 below damping.min_samples_for_stats
+	Ports      []tcpPortResult // Each port's handshake in the last echo, for tcp probes
+	Probes     []probeResult   // Each probe's result in the last echo, for peers with health_probes
+}
+
+// validateProbeCount checks damping.probe_count and damping.min_samples_for_stats
+func validateProbeCount(config DampingConfig) error {
+	if config.ProbeCount < 0 {
+		return fmt.Errorf("damping.probe_count must not be negative")
+	}
+	if config.MinSamplesForStats < 0 {
+		return fmt.Errorf("damping.min_samples_for_stats must not be negative")
+	}
+	return nil
+}
+
+// minSamplesForStats is how many answered samples a spread or percentile needs before
+// health criteria use it (damping.min_samples_for_stats)
+func minSamplesForStats(config DampingConfig) int {
+	if config.MinSamplesForStats > 0 {
+		return config.MinSamplesForStats
+	}
+	return defaultMinSamplesForStats
+}
+
+// measureLatency takes one measurement of a peer: damping.probe_count echoes, one after
+// another (respecting the probe spacing), averaged over the ones that were answered.
+// It returns the mean latency, the TTL of the last reply and the loss and jitter of the
+// echoes; when every echo is lost the latency is -1 with the last probe error.
+// With probe spacing the burst can take seconds, so it works from the caller's copy of
+// the config and must run without state.mu.
+func measureLatency(state *AppState, config Config, peer PeerConfig) (float64, int, sampleQuality, string) {
+	probe := peerProbeConfig(config, peer)
+	count := config.Damping.ProbeCount
+	if count <= 1 {
+		result, probes := sampleProbe(peer, probe, state.probeClassifier)
+		quality := sampleQuality{Ports: result.ports, Probes: probes}
+		if result.Latency < 0 {
+			quality.PacketLoss = 1
+		}
+		return result.Latency, result.ttl, quality, result.Error
+	}
+
+	var rtts []float64
+	var lastTTL int
+	var lastErr string
+	var lastPorts []tcpPortResult
+	var lastProbes []probeResult
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			// The first echo's slot was reserved by the caller
+			state.probeLimiter.wait(peer.Hostname, probeMinSpacing(config, peer))
+		}
+		result, probes := sampleProbe(peer, probe, state.probeClassifier)
+		lastPorts, lastProbes = result.ports, probes
+		if result.Latency < 0 {
+			lastErr = result.Error
+			continue
+		}
+		rtts = append(rtts, result.Latency)
+		lastTTL = result.ttl
+	}
+
+	replies := len(rtts)
+	quality := sampleQuality{PacketLoss: float64(count-replies) / float64(count), Ports: lastPorts, Probes: lastProbes}
+	if replies == 0 {
+		return -1, 0, quality, lastErr
+	}
+	if replies < count {
+		logger.Debug("Peer %s: %d of %d echoes lost (last: %s)", peer.Name, count-replies, count, lastErr)
+	}
+	mean, _, _ := stats.MeanStdDev(rtts, 1)
+	// Jitter over too few replies is noise: below damping.min_samples_for_stats it stays
+	// 0, so the jitter rule passes
+	_, quality.Jitter, _ = stats.MeanStdDev(rtts, minSamplesForStats(config.Damping))
+	return mean, lastTTL, quality, ""
+}
+
+// probeCountDuration estimates the worst case for one measurement, so a probe_count
+// whose echoes can't fit in the measurement interval can be warned about
+func probeCountDuration(config Config, peer PeerConfig) time.Duration {
+	count := config.Damping.ProbeCount
+	if count <= 1 {
+		return 0
+	}
+	perEcho := 3 * time.Second
+	if spacing := probeMinSpacing(config, peer); spacing > perEcho {
+		perEcho = spacing
+	}
+	return time.Duration(count) * perEcho
+}
+
+// latestQuality returns the loss and jitter of the peer's latest measurement
+func latestQuality(peer *PeerState) sampleQuality {
+	return sampleQuality{PacketLoss: peer.PacketLoss, Jitter: peer.Jitter}
+}
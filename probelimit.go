@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// probeLimiter enforces a minimum spacing between probes to the same target, across
+// every kind of probe (scheduled, warmup re-probe, canary, baseline, reference), so
+// upstreams that police ICMP never see more than one packet per spacing from us
+type probeLimiter struct {
+	mu   sync.Mutex
+	last map[string]time.Time // Target -> time of the last probe sent to it
+}
+
+func newProbeLimiter() *probeLimiter {
+	return &probeLimiter{last: make(map[string]time.Time)}
+}
+
+// allow reserves a probe to target if spacing has passed since the last one, and
+// otherwise reports when the next probe would be allowed
+func (l *probeLimiter) allow(target string, spacing time.Duration) (bool, time.Duration) {
+	if spacing <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if wait := l.last[target].Add(spacing).Sub(now); wait > 0 {
+		return false, wait
+	}
+	l.last[target] = now
+	return true, 0
+}
+
+// wait blocks until a probe to target is allowed and reserves it. Used by bursts whose
+// timing doesn't matter (canaries, startup baselines, reference checks).
+func (l *probeLimiter) wait(target string, spacing time.Duration) {
+	for {
+		ok, wait := l.allow(target, spacing)
+		if ok {
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// probeMinSpacing returns the minimum time between probes to a peer: its own
+// probe_min_spacing if set, otherwise probe.min_spacing
+func probeMinSpacing(config Config, peer PeerConfig) time.Duration {
+	if peer.ProbeMinSpacing > 0 {
+		return time.Duration(peer.ProbeMinSpacing) * time.Millisecond
+	}
+	return time.Duration(config.Probe.MinSpacing) * time.Millisecond
+}
+
+// allowPeerProbe checks a scheduled probe against the peer's spacing, logging rejections
+func allowPeerProbe(state *AppState, config Config, peer PeerConfig, what string) bool {
+	ok, wait := state.probeLimiter.allow(peer.Hostname, probeMinSpacing(config, peer))
+	if !ok {
+		logger.Warn("Rejected %s to %s (%s): next probe allowed in %s (probe min spacing)",
+			what, peer.Name, peer.Hostname, wait.Round(time.Millisecond))
+	}
+	return ok
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"lagbuster/api"
+)
+
+// ReferenceConfig lists independent targets used to tell a local outage from peer problems
+type ReferenceConfig struct {
+	Targets []string `yaml:"targets" json:"targets"` // Hosts that should always answer (e.g. upstream resolvers)
+	Quorum  int      `yaml:"quorum" json:"quorum"`   // Targets that must respond, 0 = majority
+}
+
+// referenceQuorum returns how many reference targets must respond
+func referenceQuorum(config ReferenceConfig) int {
+	if config.Quorum > 0 {
+		return config.Quorum
+	}
+	return len(config.Targets)/2 + 1
+}
+
+// validateReferences checks the quorum against the number of targets
+func validateReferences(config ReferenceConfig) error {
+	if len(config.Targets) == 0 {
+		if config.Quorum > 0 {
+			return fmt.Errorf("reference.quorum set without reference.targets")
+		}
+		return nil
+	}
+	if config.Quorum < 0 || config.Quorum > len(config.Targets) {
+		return fmt.Errorf("reference.quorum must be between 1 and %d (number of targets)", len(config.Targets))
+	}
+	return nil
+}
+
+// pingReferences pings every reference target and reports which ones replied, for
+// checkReferenceQuorum. The pings run without state.mu; the caller must not hold it.
+func pingReferences(state *AppState) []bool {
+	state.mu.Lock()
+	targets := state.Config.Reference.Targets
+	probe := state.Config.Probe
+	state.mu.Unlock()
+
+	var wg sync.WaitGroup
+	replies := make([]bool, len(targets))
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			state.probeLimiter.wait(target, time.Duration(probe.MinSpacing)*time.Millisecond)
+			latency, _ := pingHost(target, probe, state.probeClassifier)
+			replies[i] = latency >= 0
+		}(i, target)
+	}
+	wg.Wait()
+	return replies
+}
+
+// checkReferenceQuorum reports whether at least the quorum of reference targets replied
+// to pingReferences, i.e. whether the local network is up and peer measurements are
+// meaningful. Without reference targets it always reports true.
+func checkReferenceQuorum(state *AppState, replies []bool) bool {
+	targets := state.Config.Reference.Targets
+	if len(targets) == 0 {
+		return true
+	}
+
+	status := &api.ReferenceQuorumStatus{
+		Required: referenceQuorum(state.Config.Reference),
+		Total:    len(targets),
+	}
+	for i, ok := range replies {
+		if ok {
+			status.Responding++
+		} else {
+			status.Unreachable = append(status.Unreachable, targets[i])
+		}
+	}
+	status.OK = status.Responding >= status.Required
+
+	wasOK := state.referenceStatus == nil || state.referenceStatus.OK
+	state.referenceStatus = status
+
+	if wasOK && !status.OK {
+		reason := fmt.Sprintf("only %d of %d reference targets responding (quorum %d) - holding peer health", status.Responding, status.Total, status.Required)
+		logger.Warn("Reference quorum LOST: %s", reason)
+		recordEvent(state, "reference_quorum_lost", nil, nil, nil, reason, nil)
+	} else if !wasOK && status.OK {
+		reason := fmt.Sprintf("%d of %d reference targets responding", status.Responding, status.Total)
+		logger.Info("Reference quorum restored: %s", reason)
+		recordEvent(state, "reference_quorum_restored", nil, nil, nil, reason, nil)
+	}
+
+	return status.OK
+}
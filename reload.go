@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// reloadableSections are the config sections a SIGHUP reload applies; changes to the
+// others are reported and wait for a restart
+var reloadableSections = map[string]bool{"peers": true, "thresholds": true, "damping": true}
+
+// reloadConfig re-reads the configuration and applies its peers, thresholds and damping
+// without a restart, so measurement windows, damping counters and health survive. Added
+// peers are probed from the next tick, removed ones are dropped. A configuration that
+// fails to load or validate is rejected and the running one kept. Loading can take a
+// while for a remote source (retries with backoff), and so can measuring baselines for
+// added peers, so both happen before state.mu is taken; the lock is only held to swap
+// the new config in. The result reports whether probe intervals changed, so the caller
+// can reset its ticker.
+func reloadConfig(state *AppState, path string) bool {
+	next, err := loadConfig(path)
+	if err != nil {
+		logger.Error("Config reload rejected, keeping the running configuration: %v", err)
+		return false
+	}
+
+	// Measure baselines for added peers that have none (startup.missing_baseline: probe)
+	var added []PeerConfig
+	state.mu.Lock()
+	for _, peerConfig := range next.Peers {
+		if _, ok := state.Peers[peerConfig.Name]; !ok {
+			added = append(added, peerConfig)
+		}
+	}
+	state.mu.Unlock()
+	var measured map[string]float64
+	if next.Startup.MissingBaseline == missingBaselineProbe {
+		measured = measureMissingBaselines(state, next, added)
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	current := state.Config
+
+	// Dry-run can be forced by -dry-run or a missing birdc, and only changes with a restart
+	next.Mode.DryRun = current.Mode.DryRun
+
+	// Keep learned baselines (baseline.mode: adaptive) until the learner next runs
+	for i := range next.Peers {
+		if peer, ok := state.Peers[next.Peers[i].Name]; ok && peer.baselineLearned {
+			next.Peers[i].ExpectedBaseline = peer.Config.ExpectedBaseline
+		}
+	}
+
+	// Keep baselines measured at startup (startup.missing_baseline: probe) for peers that
+	// still have none configured; added peers without one take those measured above
+	for i := range next.Peers {
+		if next.Peers[i].ExpectedBaseline > 0 || next.Startup.MissingBaseline != missingBaselineProbe {
+			continue
+		}
+		if peer, ok := state.Peers[next.Peers[i].Name]; ok {
+			next.Peers[i].ExpectedBaseline = peer.Config.ExpectedBaseline
+		} else if baseline, ok := measured[next.Peers[i].Name]; ok {
+			next.Peers[i].ExpectedBaseline = baseline
+		}
+	}
+
+	var changed, ignored []string
+	currentValue, nextValue := reflect.ValueOf(current), reflect.ValueOf(next)
+	for i := 0; i < currentValue.NumField(); i++ {
+		if reflect.DeepEqual(currentValue.Field(i).Interface(), nextValue.Field(i).Interface()) {
+			continue
+		}
+		section := strings.Split(currentValue.Type().Field(i).Tag.Get("yaml"), ",")[0]
+		if reloadableSections[section] {
+			changed = append(changed, section)
+		} else {
+			ignored = append(ignored, section)
+		}
+	}
+	if len(ignored) > 0 {
+		logger.Warn("Config reload: changes to %s need a restart and were not applied", strings.Join(ignored, ", "))
+	}
+	if len(changed) == 0 {
+		logger.Info("Config reloaded: no runtime changes")
+		return false
+	}
+
+	details := reloadPeers(state, next)
+	state.Config.Peers = next.Peers
+	state.Config.Thresholds = next.Thresholds
+	state.Config.Damping = next.Damping
+	if !reflect.DeepEqual(current.Thresholds, next.Thresholds) {
+		details = append(details, "thresholds updated")
+	}
+	if !reflect.DeepEqual(current.Damping, next.Damping) {
+		details = append(details, "damping updated")
+	}
+
+	if state.notifier != nil {
+		state.notifier.SetPeerGroups(peerNotificationGroups(next.Peers))
+	}
+	if state.apiServer != nil {
+		state.apiServer.SetMeasurementConfig(next.Damping.MeasurementInterval, next.Damping.MeasurementWindow, ewmaAlpha(next.Damping))
+	}
+	updateAPIServerState(state)
+
+	summary := strings.Join(details, "; ")
+	if summary == "" {
+		summary = "peers reordered"
+	}
+	logger.Info("Config reloaded: %s", summary)
+	recordEvent(state, "config_reload", nil, nil, nil, summary, nil)
+
+	return schedulerTick(current) != schedulerTick(next) || current.Damping.MeasurementInterval != next.Damping.MeasurementInterval
+}
+
+// reloadPeers brings state.Peers in line with the reloaded peer list and describes what
+// changed. Existing peers keep their runtime state and take their new settings.
+func reloadPeers(state *AppState, next Config) []string {
+	var details []string
+	wanted := make(map[string]bool, len(next.Peers))
+	for _, peerConfig := range next.Peers {
+		wanted[peerConfig.Name] = true
+		peer, ok := state.Peers[peerConfig.Name]
+		if !ok {
+			state.Peers[peerConfig.Name] = newPeerState(peerConfig, next)
+			details = append(details, "added peer "+peerConfig.Name)
+			continue
+		}
+		if reflect.DeepEqual(peer.Config, peerConfig) {
+			continue
+		}
+		if peer.Config.ExpectedBaseline != peerConfig.ExpectedBaseline {
+			details = append(details, fmt.Sprintf("peer %s baseline %.2fms -> %.2fms", peerConfig.Name, peer.Config.ExpectedBaseline, peerConfig.ExpectedBaseline))
+		} else {
+			details = append(details, "peer "+peerConfig.Name+" updated")
+		}
+		peer.Config = peerConfig
+	}
+
+	for name := range state.Peers {
+		if wanted[name] {
+			continue
+		}
+		delete(state.Peers, name)
+		delete(state.appliedPriorities, name)
+		details = append(details, "removed peer "+name)
+	}
+	return details
+}
+
+// peerNotificationGroups maps each peer with a notification_group to its group
+func peerNotificationGroups(peers []PeerConfig) map[string]string {
+	groups := make(map[string]string)
+	for _, peer := range peers {
+		if peer.NotificationGroup != "" {
+			groups[peer.Name] = peer.NotificationGroup
+		}
+	}
+	return groups
+}
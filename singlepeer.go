@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// Single-peer handling (mode.single_peer)
+const (
+	singlePeerKeep     = "keep"     // Default: health is still tracked and notified, but routing only follows BGP
+	singlePeerWithdraw = "withdraw" // Treat the lone peer like any other: unhealthy means priority 99
+)
+
+// validateSinglePeer checks mode.single_peer
+func validateSinglePeer(config ModeConfig) error {
+	switch config.SinglePeer {
+	case "", singlePeerKeep, singlePeerWithdraw:
+		return nil
+	default:
+		return fmt.Errorf("mode.single_peer must be keep or withdraw, got %q", config.SinglePeer)
+	}
+}
+
+// singlePeerMode reports whether only one peer is configured and it is kept in use
+// regardless of health. With nothing to fail over to, taking the only path out of
+// ECMP for being slow helps no one; the peer is still probed, evaluated, recorded and
+// notified on, but its priority only follows its BGP session.
+func singlePeerMode(state *AppState) bool {
+	return len(state.Peers) == 1 && state.Config.Mode.SinglePeer != singlePeerWithdraw
+}
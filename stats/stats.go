@@ -0,0 +1,112 @@
+// Package stats holds the latency smoothing used by the decision engine, shared with
+// the API so previews match what the engine computes
+package stats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultEWMAAlpha weighs the newest sample when no alpha is configured
+const DefaultEWMAAlpha = 0.3
+
+// EWMA folds sample into the running average prev. The first sample (prev < 0) starts
+// the average; failed samples (sample < 0) leave it unchanged.
+func EWMA(prev, sample, alpha float64) float64 {
+	if sample < 0 {
+		return prev
+	}
+	if prev < 0 {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*prev
+}
+
+// Percentile returns the p-th percentile (0-100) of the answered samples using nearest
+// rank, or -1 when none were answered. samples is not modified.
+func Percentile(samples []float64, p float64) float64 {
+	answered := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		if s >= 0 {
+			answered = append(answered, s)
+		}
+	}
+	if len(answered) == 0 {
+		return -1
+	}
+
+	sort.Float64s(answered)
+	rank := int(math.Ceil(p / 100 * float64(len(answered))))
+	if rank < 1 {
+		rank = 1
+	}
+	return answered[rank-1]
+}
+
+// MeanStdDev returns the mean and population standard deviation of the answered samples
+// and how many there were. Both are 0 when fewer than minSamples (at least 1) were
+// answered, so tiny windows never yield NaN or a wild spread.
+func MeanStdDev(samples []float64, minSamples int) (mean, stddev float64, n int) {
+	var sum float64
+	for _, s := range samples {
+		if s >= 0 {
+			sum += s
+			n++
+		}
+	}
+	if n == 0 || n < minSamples {
+		return 0, 0, n
+	}
+	mean = sum / float64(n)
+
+	var squares float64
+	for _, s := range samples {
+		if s >= 0 {
+			squares += (s - mean) * (s - mean)
+		}
+	}
+	return mean, math.Sqrt(squares / float64(n)), n
+}
+
+// ParsePercentile parses a "pNN" statistic name such as p50 or p95
+func ParsePercentile(name string) (float64, error) {
+	if !strings.HasPrefix(name, "p") {
+		return 0, fmt.Errorf("invalid percentile %q", name)
+	}
+	p, err := strconv.ParseFloat(name[1:], 64)
+	if err != nil || p <= 0 || p > 100 {
+		return 0, fmt.Errorf("invalid percentile %q (expected p1 to p100)", name)
+	}
+	return p, nil
+}
+
+// EWMASeries returns the running EWMA after each sample, -1 until the first answered one
+func EWMASeries(samples []float64, alpha float64) []float64 {
+	out := make([]float64, len(samples))
+	avg := -1.0
+	for i, s := range samples {
+		avg = EWMA(avg, s, alpha)
+		out[i] = avg
+	}
+	return out
+}
+
+// PercentileSeries returns the p-th percentile of the trailing window of samples (the
+// engine's measurement window) at each point, -1 where none were answered
+func PercentileSeries(samples []float64, p float64, window int) []float64 {
+	if window < 1 {
+		window = 1
+	}
+	out := make([]float64, len(samples))
+	for i := range samples {
+		start := i + 1 - window
+		if start < 0 {
+			start = 0
+		}
+		out[i] = Percentile(samples[start:i+1], p)
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SyslogConfig configures the optional syslog event sink (Unix only)
+type SyslogConfig struct {
+	Enabled  bool   `yaml:"enabled" json:"enabled"`
+	Network  string `yaml:"network" json:"network"`   // "udp", "tcp" or empty for the local syslog socket
+	Address  string `yaml:"address" json:"address"`   // host:port for remote syslog
+	Facility string `yaml:"facility" json:"facility"` // daemon (default), local0-local7, user
+	Tag      string `yaml:"tag" json:"tag"`           // Default "lagbuster"
+}
+
+// Severities used for events, independent of the platform's syslog package
+type eventSeverity int
+
+const (
+	severityError eventSeverity = iota
+	severityWarning
+	severityNotice
+	severityInfo
+)
+
+// eventSeverityFor maps an event to a syslog severity
+func eventSeverityFor(eventType string, newHealth *bool) eventSeverity {
+	switch eventType {
+	case "health_change":
+		if newHealth != nil && !*newHealth {
+			return severityWarning
+		}
+		return severityNotice
+	case "db_recovery", "reference_quorum_lost", "bird_config_error", "bird_rollback":
+		return severityError
+	case "freeze", "path_asymmetry", "monitoring_gap", "peer_disabled":
+		return severityWarning
+	case "unfreeze", "peer_reset", "peer_enabled", "canary_failed", "reference_quorum_restored", "restart", "shutdown", "config_reload":
+		return severityNotice
+	default:
+		return severityInfo
+	}
+}
+
+// formatSyslogEvent renders an event as a single key=value line
+func formatSyslogEvent(eventType string, peerName *string, newHealth *bool, reason string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "event=%s", eventType)
+	if peerName != nil {
+		fmt.Fprintf(&sb, " peer=%s", *peerName)
+	}
+	if newHealth != nil {
+		fmt.Fprintf(&sb, " healthy=%t", *newHealth)
+	}
+	if reason != "" {
+		fmt.Fprintf(&sb, " reason=%s", strconv.Quote(reason))
+	}
+	return sb.String()
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package main
+
+import "fmt"
+
+// syslogSink is unavailable on platforms without log/syslog
+type syslogSink struct{}
+
+func openSyslogSink(config SyslogConfig) (*syslogSink, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
+
+func (s *syslogSink) emit(eventType string, peerName *string, newHealth *bool, reason string) {}
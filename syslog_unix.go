@@ -0,0 +1,69 @@
+//go:build !windows && !plan9
+
+package main
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"daemon": syslog.LOG_DAEMON,
+	"user":   syslog.LOG_USER,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// syslogSink sends event lines to syslog
+type syslogSink struct {
+	writer *syslog.Writer
+}
+
+// openSyslogSink connects to the configured syslog daemon
+func openSyslogSink(config SyslogConfig) (*syslogSink, error) {
+	facility := syslog.LOG_DAEMON
+	if config.Facility != "" {
+		f, ok := syslogFacilities[config.Facility]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog facility %q", config.Facility)
+		}
+		facility = f
+	}
+
+	tag := config.Tag
+	if tag == "" {
+		tag = "lagbuster"
+	}
+
+	writer, err := syslog.Dial(config.Network, config.Address, facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to syslog: %w", err)
+	}
+	return &syslogSink{writer: writer}, nil
+}
+
+// emit writes one event at the severity matching its type
+func (s *syslogSink) emit(eventType string, peerName *string, newHealth *bool, reason string) {
+	line := formatSyslogEvent(eventType, peerName, newHealth, reason)
+
+	var err error
+	switch eventSeverityFor(eventType, newHealth) {
+	case severityError:
+		err = s.writer.Err(line)
+	case severityWarning:
+		err = s.writer.Warning(line)
+	case severityNotice:
+		err = s.writer.Notice(line)
+	default:
+		err = s.writer.Info(line)
+	}
+	if err != nil {
+		logger.Debug("Failed to write event to syslog: %v", err)
+	}
+}
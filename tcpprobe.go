@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// tcpProbeTimeout matches the ping timeout
+const tcpProbeTimeout = 3 * time.Second
+
+// tcpPortResult is the outcome of one port's handshake in a tcp probe
+type tcpPortResult struct {
+	Port    int     `json:"port"`
+	Latency float64 `json:"latency_ms"`      // Handshake time, -1 when it failed
+	Error   string  `json:"error,omitempty"` // Why it failed
+}
+
+// tcpPing opens TCP connections to each of ports on host at once and returns the
+// handshake time in milliseconds by which quorum of them had connected (0 = all), the
+// result of every port, and, when too few connected, -1 and the reason. With a single
+// port that is simply its handshake time. The address is resolved first so DNS time
+// isn't counted, and connections are closed as soon as they are established.
+// sourcePort, when set, is the local port or "first-last" range to dial from (see
+// dialFromSourcePort).
+func tcpPing(ctx context.Context, host string, useIPv6 bool, ports []int, quorum int, sourcePort string) (float64, []tcpPortResult, string) {
+	ip, err := resolveProbeAddr(ctx, host, useIPv6)
+	if err != nil {
+		logger.Debug("TCP probe to %s: %v", host, err)
+		return -1, nil, "dns lookup failed"
+	}
+
+	results := make([]tcpPortResult, len(ports))
+	var wg sync.WaitGroup
+	for i, port := range ports {
+		wg.Add(1)
+		go func(i, port int) {
+			defer wg.Done()
+			latency, probeErr := tcpHandshake(ctx, ip, port, sourcePort)
+			if probeErr != "" {
+				logger.Debug("TCP probe to %s port %d: %s", host, port, probeErr)
+			}
+			results[i] = tcpPortResult{Port: port, Latency: latency, Error: probeErr}
+		}(i, port)
+	}
+	wg.Wait()
+
+	if quorum <= 0 || quorum > len(ports) {
+		quorum = len(ports)
+	}
+	var connected []float64
+	var failed []string
+	for _, result := range results {
+		if result.Latency < 0 {
+			failed = append(failed, fmt.Sprintf("%d: %s", result.Port, result.Error))
+			continue
+		}
+		connected = append(connected, result.Latency)
+	}
+	if len(connected) < quorum {
+		if len(ports) == 1 {
+			return -1, results, results[0].Error
+		}
+		return -1, results, fmt.Sprintf("%d of %d ports connected, need %d (%s)", len(connected), len(ports), quorum, strings.Join(failed, ", "))
+	}
+	sort.Float64s(connected)
+	return connected[quorum-1], results, ""
+}
+
+// tcpHandshake times one TCP connection to ip:port, returning -1 and the reason it
+// failed
+func tcpHandshake(ctx context.Context, ip net.IP, port int, sourcePort string) (float64, string) {
+	address := net.JoinHostPort(ip.String(), strconv.Itoa(port))
+	var conn net.Conn
+	var rtt time.Duration
+	var err error
+	if sourcePort == "" {
+		dialer := net.Dialer{Timeout: tcpProbeTimeout}
+		start := time.Now()
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+		rtt = time.Since(start)
+	} else {
+		conn, rtt, err = dialFromSourcePort(ctx, address, sourcePort)
+	}
+	if err != nil {
+		logger.Debug("TCP connect to %s: %v", address, err)
+		var netErr net.Error
+		switch {
+		case errors.As(err, &netErr) && netErr.Timeout():
+			return -1, "timeout"
+		case errors.Is(err, syscall.ECONNREFUSED):
+			return -1, "connection refused"
+		case errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL):
+			return -1, "no free source port"
+		default:
+			return -1, fmt.Sprintf("connect failed: %v", err)
+		}
+	}
+	conn.Close()
+
+	return float64(rtt.Microseconds()) / 1000, ""
+}
+
+// tcpProbePorts returns the ports a tcp probe connects to: probe.ports if set,
+// otherwise probe.port
+func tcpProbePorts(probe ProbeConfig) []int {
+	if len(probe.Ports) > 0 {
+		return probe.Ports
+	}
+	return []int{probe.Port}
+}
+
+// tcpPortsNote lists a tcp probe's ports and quorum for logs and the Bird file comments
+func tcpPortsNote(probe ProbeConfig) string {
+	ports := tcpProbePorts(probe)
+	names := make([]string, len(ports))
+	for i, port := range ports {
+		names[i] = strconv.Itoa(port)
+	}
+	note := strings.Join(names, ",")
+	if len(ports) > 1 && probe.PortQuorum > 0 && probe.PortQuorum < len(ports) {
+		note += fmt.Sprintf(" (%d of %d)", probe.PortQuorum, len(ports))
+	}
+	return note
+}
+
+// dialFromSourcePort dials address from each local port in the sourcePort range in
+// turn until one is free, returning the connection and the handshake time of the
+// attempt that connected. A port still held by another socket, or by this prober's
+// previous connection to the same address, fails to bind or connect straight away, so
+// trying the next one costs no timeout. The connection is reset on close rather than
+// left in TIME_WAIT, so the same port is usable again by the next probe.
+func dialFromSourcePort(ctx context.Context, address, sourcePort string) (net.Conn, time.Duration, error) {
+	first, last, err := parseSourcePorts(sourcePort)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for local := first; ; local++ {
+		dialer := net.Dialer{Timeout: tcpProbeTimeout, LocalAddr: &net.TCPAddr{Port: local}}
+		start := time.Now()
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		rtt := time.Since(start)
+		if err == nil {
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+			return conn, rtt, nil
+		}
+		busy := errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)
+		if !busy || local == last {
+			return nil, rtt, err
+		}
+		logger.Debug("TCP probe to %s: source port %d in use, trying %d", address, local, local+1)
+	}
+}
+
+// parseSourcePorts parses a probe source port: a single port or a "first-last" range
+func parseSourcePorts(spec string) (int, int, error) {
+	firstText, lastText, isRange := strings.Cut(spec, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(firstText))
+	if err != nil {
+		return 0, 0, fmt.Errorf("source port %q is not a port or first-last range", spec)
+	}
+	last := first
+	if isRange {
+		if last, err = strconv.Atoi(strings.TrimSpace(lastText)); err != nil {
+			return 0, 0, fmt.Errorf("source port %q is not a port or first-last range", spec)
+		}
+	}
+	if first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("source port %q must be between 1 and 65535, first no higher than last", spec)
+	}
+	return first, last, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimeBaseline replaces a peer's expected_baseline during a daily window, for paths
+// that are reliably slower at peak hours
+type TimeBaseline struct {
+	From     string  `yaml:"from" json:"from"`         // "HH:MM" server local time, inclusive
+	To       string  `yaml:"to" json:"to"`             // "HH:MM", exclusive; earlier than from wraps past midnight
+	Baseline float64 `yaml:"baseline" json:"baseline"` // Milliseconds
+}
+
+// validateTimeBaselines checks every configured peers[].baseline_windows
+func validateTimeBaselines(config Config) error {
+	for _, peer := range config.Peers {
+		for _, window := range peer.BaselineWindows {
+			from, err := clockMinutes(window.From)
+			if err != nil {
+				return fmt.Errorf("peer %s: baseline_windows from: %w", peer.Name, err)
+			}
+			to, err := clockMinutes(window.To)
+			if err != nil {
+				return fmt.Errorf("peer %s: baseline_windows to: %w", peer.Name, err)
+			}
+			if from == to {
+				return fmt.Errorf("peer %s: baseline window %s-%s is empty", peer.Name, window.From, window.To)
+			}
+			if window.Baseline <= 0 {
+				return fmt.Errorf("peer %s: baseline window %s-%s needs a positive baseline", peer.Name, window.From, window.To)
+			}
+		}
+	}
+	return nil
+}
+
+// clockMinutes parses "HH:MM" into minutes past midnight
+func clockMinutes(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// activeBaseline returns the baseline a peer is judged against at now: the first of its
+// baseline_windows that covers the local time of day, otherwise expected_baseline. The
+// window is returned as "HH:MM-HH:MM", empty outside any window.
+func activeBaseline(peer PeerConfig, now time.Time) (float64, string) {
+	minute := now.Hour()*60 + now.Minute()
+	for _, window := range peer.BaselineWindows {
+		from, errFrom := clockMinutes(window.From)
+		to, errTo := clockMinutes(window.To)
+		if errFrom != nil || errTo != nil {
+			continue // Rejected by validateTimeBaselines
+		}
+		var inside bool
+		if from < to {
+			inside = minute >= from && minute < to
+		} else {
+			inside = minute >= from || minute < to
+		}
+		if inside {
+			return window.Baseline, window.From + "-" + window.To
+		}
+	}
+	return peer.ExpectedBaseline, ""
+}
+
+// peerBaseline returns the baseline a peer is judged against right now
+func peerBaseline(peer PeerConfig) float64 {
+	baseline, _ := activeBaseline(peer, time.Now())
+	return baseline
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// traceRecord is one decision cycle in a -trace file (one JSON object per line).
+// Inputs are the fresh samples the cycle evaluated; outputs are the resulting health
+// and priority of every peer.
+type traceRecord struct {
+	Cycle    int                    `json:"cycle"`
+	Time     time.Time              `json:"time"`
+	Frozen   bool                   `json:"frozen"`
+	Disabled []string               `json:"disabled,omitempty"` // Peers disabled via the API
+	Inputs   map[string]traceInput  `json:"inputs"`
+	Outputs  map[string]traceOutput `json:"outputs"`
+	Events   []traceEvent           `json:"events,omitempty"`
+	Held     bool                   `json:"held,omitempty"` // Health evaluation skipped (reference quorum lost)
+}
+
+type traceInput struct {
+	Latency    float64 `json:"latency"`
+	ProbeError string  `json:"probe_error,omitempty"`
+	PacketLoss float64 `json:"packet_loss,omitempty"`
+	Jitter     float64 `json:"jitter,omitempty"`
+	BGPUp      bool    `json:"bgp_up"`
+	Baseline   float64 `json:"baseline,omitempty"` // Baseline in effect (baseline_windows, baseline.mode: adaptive)
+}
+
+type traceOutput struct {
+	Healthy  bool `json:"healthy"`
+	Priority int  `json:"priority"`
+}
+
+type traceEvent struct {
+	Type string `json:"type"`
+	Peer string `json:"peer,omitempty"`
+}
+
+// decisionTracer collects the record for the cycle in progress and, when tracing live,
+// appends it to the trace file
+type decisionTracer struct {
+	file    *os.File
+	cycle   int
+	current *traceRecord
+}
+
+// openDecisionTrace appends decision records to path
+func openDecisionTrace(path string) (*decisionTracer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace file: %w", err)
+	}
+	return &decisionTracer{file: file}, nil
+}
+
+// begin captures the inputs of a decision cycle, before health is evaluated
+func (t *decisionTracer) begin(state *AppState) {
+	t.cycle++
+	t.current = &traceRecord{
+		Cycle:    t.cycle,
+		Time:     time.Now(),
+		Frozen:   state.frozen.Load(),
+		Disabled: disabledPeers(state),
+		Inputs:   make(map[string]traceInput),
+	}
+	for name, peer := range state.Peers {
+		if !peer.freshSample {
+			continue
+		}
+		baseline, _ := activeBaseline(peer.Config, t.current.Time)
+		t.current.Inputs[name] = traceInput{
+			Latency:    peer.CurrentLatency,
+			ProbeError: peer.LastProbeError,
+			PacketLoss: peer.PacketLoss,
+			Jitter:     peer.Jitter,
+			BGPUp:      peer.BGPSessionUp,
+			Baseline:   baseline,
+		}
+	}
+}
+
+// hold notes that the cycle in progress skipped health evaluation
+func (t *decisionTracer) hold() {
+	if t.current != nil {
+		t.current.Held = true
+	}
+}
+
+// event notes an event recorded during the cycle in progress
+func (t *decisionTracer) event(eventType string, peerName *string) {
+	if t.current == nil {
+		return
+	}
+	event := traceEvent{Type: eventType}
+	if peerName != nil {
+		event.Peer = *peerName
+	}
+	t.current.Events = append(t.current.Events, event)
+}
+
+// end captures the outputs of the cycle and writes the record when tracing live
+func (t *decisionTracer) end(state *AppState, priorities map[string]int) *traceRecord {
+	record := t.current
+	t.current = nil
+	if record == nil {
+		return nil
+	}
+
+	record.Outputs = make(map[string]traceOutput, len(state.Peers))
+	for name, peer := range state.Peers {
+		record.Outputs[name] = traceOutput{Healthy: peer.IsHealthy, Priority: priorities[name]}
+	}
+
+	if t.file != nil {
+		data, err := json.Marshal(record)
+		if err == nil {
+			_, err = t.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			logger.Error("Failed to write decision trace: %v", err)
+		}
+	}
+
+	return record
+}
+
+// runReplay feeds the inputs recorded in a trace through the decision logic and checks
+// that every cycle produces the recorded health, priorities and events. Use the config the
+// trace was recorded with. Each peer is judged against the baseline recorded for the
+// cycle, so time-of-day windows and learned baselines apply as they did live. Canary
+// probes and the recent-flap check need the network or database and the post-recovery
+// hold depends on wall-clock time, so they are disabled, and peer resets made through
+// the API are not recorded; traces involving any of these may diverge.
+func runReplay(config Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening trace: %w", err)
+	}
+	defer file.Close()
+
+	config.Damping.CanaryCount = 0
+	config.Damping.MinActiveHold = 0
+	config.Mode.DryRun = true
+	state := initializeState(config)
+	state.trace = &decisionTracer{}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	cycles, mismatches := 0, 0
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var want traceRecord
+		if err := json.Unmarshal(scanner.Bytes(), &want); err != nil {
+			return fmt.Errorf("trace line %d: %w", cycles+1, err)
+		}
+		cycles++
+
+		// Restore the recorded inputs
+		state.frozen.Store(want.Frozen)
+		for _, peer := range state.Peers {
+			peer.Disabled = false
+		}
+		for _, name := range want.Disabled {
+			if peer, ok := state.Peers[name]; ok {
+				peer.Disabled = true
+			}
+		}
+		for name, input := range want.Inputs {
+			peer, ok := state.Peers[name]
+			if !ok {
+				return fmt.Errorf("cycle %d: peer %s is not in the configuration", want.Cycle, name)
+			}
+			peer.CurrentLatency = input.Latency
+			peer.LastProbeError = input.ProbeError
+			peer.PacketLoss = input.PacketLoss
+			peer.Jitter = input.Jitter
+			peer.BGPSessionUp = input.BGPUp
+			if input.Baseline > 0 {
+				// The recorded baseline stands in for windows evaluated at replay time;
+				// older traces don't record one and keep the configured baselines
+				peer.Config.ExpectedBaseline = input.Baseline
+				peer.Config.BaselineWindows = nil
+			}
+			peer.freshSample = true
+			addSample(peer, input.Latency, latestQuality(peer), config.Damping.MeasurementWindow)
+			updateSmoothedLatency(config.Damping, peer)
+		}
+
+		state.trace.begin(state)
+
+		// Reference quorum checks need the network; carry their recorded outcome over
+		for _, event := range want.Events {
+			if strings.HasPrefix(event.Type, "reference_quorum") {
+				state.trace.event(event.Type, nil)
+			}
+		}
+
+		if want.Held {
+			state.trace.hold()
+			for _, peer := range state.Peers {
+				peer.freshSample = false
+			}
+		} else {
+			evaluatePeerHealth(state)
+		}
+		priorities := priorityAssignment(state)
+		state.appliedPriorities = priorities
+		got := state.trace.end(state, priorities)
+
+		for _, diff := range diffTraceRecords(want, *got) {
+			logger.Error("cycle %d: %s", want.Cycle, diff)
+			mismatches++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading trace: %w", err)
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d mismatches in %d cycles", mismatches, cycles)
+	}
+	logger.Info("Replayed %d cycles from %s: all decisions match", cycles, path)
+	return nil
+}
+
+// diffTraceRecords describes how a replayed cycle differs from the recorded one
+func diffTraceRecords(want, got traceRecord) []string {
+	var diffs []string
+
+	names := make([]string, 0, len(want.Outputs))
+	for name := range want.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if w, g := want.Outputs[name], got.Outputs[name]; w != g {
+			diffs = append(diffs, fmt.Sprintf("peer %s: recorded healthy=%t priority=%d, replayed healthy=%t priority=%d",
+				name, w.Healthy, w.Priority, g.Healthy, g.Priority))
+		}
+	}
+
+	if !reflect.DeepEqual(sortedTraceEvents(want.Events), sortedTraceEvents(got.Events)) {
+		diffs = append(diffs, fmt.Sprintf("recorded events %v, replayed %v", want.Events, got.Events))
+	}
+
+	return diffs
+}
+
+// sortedTraceEvents orders events so peers evaluated in map order compare equal
+func sortedTraceEvents(events []traceEvent) []traceEvent {
+	sorted := append([]traceEvent{}, events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Peer != sorted[j].Peer {
+			return sorted[i].Peer < sorted[j].Peer
+		}
+		return sorted[i].Type < sorted[j].Type
+	})
+	if len(sorted) == 0 {
+		return nil
+	}
+	return sorted
+}
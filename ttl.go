@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// replyTTLPattern finds the reply TTL in ping output (hlim= on BSD ping6)
+var replyTTLPattern = regexp.MustCompile(`(?i)\b(?:ttl|hlim)=(\d+)`)
+
+// replyTTL returns the TTL of the reply in ping output, 0 if it isn't shown
+func replyTTL(output string) int {
+	matches := replyTTLPattern.FindStringSubmatch(output)
+	if len(matches) < 2 {
+		return 0
+	}
+	ttl, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+	return ttl
+}
+
+// validateTTL checks probe.ttl and probe.ttl_change_threshold
+func validateTTL(config ProbeConfig) error {
+	if config.TTL < 0 || config.TTL > 255 {
+		return fmt.Errorf("probe.ttl must be between 1 and 255 (0 = system default)")
+	}
+	if config.TTLChangeThreshold < 0 {
+		return fmt.Errorf("probe.ttl_change_threshold must not be negative")
+	}
+	return nil
+}
+
+// trackTTL follows the reply TTL a peer's probes come back with. The return path's hop
+// count shows up as a TTL change even when latency stays flat, so a shift of the window's
+// median TTL by probe.ttl_change_threshold or more from the last settled value fires a
+// ttl_change event as a lightweight reroute signal.
+func trackTTL(state *AppState, peer *PeerState, ttl int) {
+	threshold := state.Config.Probe.TTLChangeThreshold
+	if ttl <= 0 || threshold <= 0 {
+		return
+	}
+
+	peer.ttls = append(peer.ttls, ttl)
+	if window := state.Config.Damping.MeasurementWindow; len(peer.ttls) > window {
+		peer.ttls = peer.ttls[len(peer.ttls)-window:]
+	}
+
+	sorted := append([]int(nil), peer.ttls...)
+	sort.Ints(sorted)
+	median := sorted[len(sorted)/2]
+
+	if peer.settledTTL == 0 {
+		peer.settledTTL = median
+		return
+	}
+
+	shift := median - peer.settledTTL
+	if shift < 0 {
+		shift = -shift
+	}
+	if shift < threshold {
+		return
+	}
+
+	name := peer.Config.Name
+	reason := fmt.Sprintf("reply TTL changed from %d to %d (%+d hops), the path to the peer likely changed", peer.settledTTL, median, median-peer.settledTTL)
+	logger.Warn("Peer %s: %s", name, reason)
+	metadata := fmt.Sprintf(`{"old_ttl":%d,"new_ttl":%d}`, peer.settledTTL, median)
+	recordEvent(state, "ttl_change", &name, nil, nil, reason, &metadata)
+	peer.settledTTL = median
+}
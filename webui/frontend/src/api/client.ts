@@ -26,10 +26,14 @@ export async function getPeers(): Promise<StatusResponse['peers']> {
 
 export async function getMetrics(
   peer: string,
-  range: TimeRange
+  range: TimeRange,
+  normalOnly = false,
+  smoothing = 'raw'
 ): Promise<MetricsResponse> {
   const res = await fetch(
-    `${API_BASE}/api/metrics?peer=${encodeURIComponent(peer)}&range=${range}`
+    `${API_BASE}/api/metrics?peer=${encodeURIComponent(peer)}&range=${range}` +
+      (normalOnly ? '&normal_only=true' : '') +
+      (smoothing !== 'raw' ? `&smoothing=${encodeURIComponent(smoothing)}` : '')
   );
   if (!res.ok) {
     throw new Error(`Failed to fetch metrics: ${res.statusText}`);
@@ -45,6 +49,26 @@ export async function getEvents(range: TimeRange): Promise<EventsResponse> {
   return res.json();
 }
 
+// Latest events from the server's in-memory ring (no database query), newest first
+export async function getRecentEvents(): Promise<{ events: EventsResponse['events'] }> {
+  const res = await fetch(`${API_BASE}/api/events/recent`);
+  if (!res.ok) {
+    throw new Error(`Failed to fetch recent events: ${res.statusText}`);
+  }
+  return res.json();
+}
+
+// Tail of the server's log file (needs logging.file and api.expose_logs), oldest first
+export async function getLogs(lines = 500, level?: string): Promise<{ lines: string[] }> {
+  const res = await fetch(
+    `${API_BASE}/api/logs?lines=${lines}` + (level ? `&level=${encodeURIComponent(level)}` : '')
+  );
+  if (!res.ok) {
+    throw new Error(`Failed to fetch logs: ${res.statusText}`);
+  }
+  return res.json();
+}
+
 export function connectWebSocket(
   onMessage: (data: WebSocketMessage) => void,
   onError?: (error: Event) => void,
@@ -142,7 +166,15 @@ export async function updateNotificationSettings(
   }
 }
 
-export async function testNotification(channel: string): Promise<void> {
+export interface NotificationTestResult {
+  channel: string;
+  ok: boolean;
+  latency_ms: number;
+  error?: string;
+  response?: string;
+}
+
+export async function testNotification(channel: string): Promise<NotificationTestResult[]> {
   const res = await fetch(`${API_BASE}/api/settings/notifications/test`, {
     method: 'POST',
     headers: {
@@ -150,7 +182,19 @@ export async function testNotification(channel: string): Promise<void> {
     },
     body: JSON.stringify({ channel }),
   });
-  if (!res.ok) {
-    throw new Error(`Failed to send test notification: ${res.statusText}`);
+  // A 502 still carries the per-channel results
+  const body = await res.json().catch(() => ({}));
+  if (!res.ok && !body.results) {
+    throw new Error(`Failed to send test notification: ${body.error || res.statusText}`);
+  }
+  const results: NotificationTestResult[] = body.results ?? [];
+  const failed = results.filter(r => !r.ok);
+  if (failed.length > 0) {
+    throw new Error(
+      failed
+        .map(r => `${r.channel}: ${r.response || r.error || 'failed'}`)
+        .join('; ')
+    );
   }
+  return results;
 }
@@ -3,12 +3,22 @@ export interface PeerStatus {
   hostname: string;
   latency: number;
   baseline: number;
+  baseline_window?: string; // "HH:MM-HH:MM" when a peers[].baseline_windows entry sets the baseline
   degradation: number;
+  degradation_ratio: number;
   is_healthy: boolean;
   consecutive_healthy_count: number;
   consecutive_unhealthy_count: number;
   bgp_session_up: boolean;
   bgp_session_state: string;
+  disabled: boolean; // taken out of ECMP by an operator (POST /api/peers/{name}/disable)
+  last_probe_error?: string;
+  packet_loss: number; // fraction of the latest measurement's echoes lost (damping.probe_count)
+  expected_loss: number; // fraction the peer normally loses (peers[].expected_loss)
+  jitter: number; // stddev of the latest measurement's echo RTTs in ms
+  smoothed_latency: number; // EWMA of answered samples (damping.ewma_alpha), -1 until the first reply
+  path_asymmetry_ms?: number;
+  hold_remaining_seconds?: number; // post-recovery hold (damping.min_active_hold)
 }
 
 export interface StatusResponse {
@@ -16,18 +26,55 @@ export interface StatusResponse {
   unhealthy_peer_count: number;
   uptime_seconds: number;
   measurement_interval: number;
+  frozen: boolean;
+  standby?: boolean; // warm standby following another instance (ha.role)
+  reference_quorum?: ReferenceQuorumStatus;
+  db_write_lag_ms?: number; // latest measurement write duration
   peers: { [key: string]: PeerStatus };
 }
 
+export interface ReferenceQuorumStatus {
+  ok: boolean;
+  responding: number;
+  required: number;
+  total: number;
+  unreachable?: string[];
+}
+
 export interface MetricPoint {
   timestamp: string;
-  latency: number;
+  latency: number | null; // null on gap markers
   is_healthy: boolean;
+  state?: 'normal' | 'frozen' | 'dry-run' | 'standby'; // operational state when measured
+  gap?: boolean; // no measurements were recorded around this point
+  gap_seconds?: number;
+  smoothed?: number; // smoothed latency when requested with a smoothing mode
+  ttl?: number; // reply TTL when known
+  min?: number; // bucketed points (resolution other than raw): lowest answered latency
+  max?: number; // bucketed points: highest answered latency
+  samples?: number; // bucketed points: measurements in the bucket
+}
+
+export interface NotificationRecord {
+  id: number;
+  timestamp: string;
+  channel: string;
+  event_id?: number; // the event it notified about
+  status: 'sent' | 'failed' | 'rate_limited';
+  message: string;
+  error?: string;
+}
+
+export interface NotificationHistoryResponse {
+  range: string;
+  notifications: NotificationRecord[];
 }
 
 export interface MetricsResponse {
   peer: string;
   range: string;
+  smoothing?: string; // raw, ewma or pNN
+  resolution?: string; // raw, or the bucket size (7d and 30d default to 15m and 1h)
   points: MetricPoint[];
 }
 
@@ -49,8 +96,13 @@ export interface EventsResponse {
 }
 
 export interface WebSocketMessage {
-  type: 'status_update' | 'event';
-  data: StatusResponse | Event;
+  type: 'status_update' | 'event' | 'heartbeat';
+  data: StatusResponse | Event | Heartbeat;
+}
+
+// Sent after every decision cycle (used by a warm standby to check the active instance)
+export interface Heartbeat {
+  timestamp: string;
 }
 
 export type TimeRange = '1h' | '24h' | '7d' | '30d';
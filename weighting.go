@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// Health weighting schemes (damping.health_weighting)
+const (
+	weightingConsecutive = "consecutive" // Default: consecutive unhealthy/healthy counts
+	weightingLinear      = "linear"      // Sample i of n (oldest first) weighs i
+	weightingExponential = "exponential" // Each older sample weighs health_weight_decay times the next
+)
+
+// Defaults for the weighted schemes
+const (
+	defaultHealthWeightDecay    = 0.7
+	defaultHealthWeightDegrade  = 0.5
+	defaultHealthWeightRecovery = 0.8
+)
+
+// validateHealthWeighting checks damping.health_weighting and its tuning knobs
+func validateHealthWeighting(config DampingConfig) error {
+	switch config.HealthWeighting {
+	case "", weightingConsecutive, weightingLinear, weightingExponential:
+	default:
+		return fmt.Errorf("damping.health_weighting must be consecutive, linear or exponential, got %q", config.HealthWeighting)
+	}
+	if config.HealthWeightDecay < 0 || config.HealthWeightDecay >= 1 {
+		return fmt.Errorf("damping.health_weight_decay must be between 0 and 1 (exclusive)")
+	}
+	if config.HealthWeightDegrade < 0 || config.HealthWeightDegrade > 1 {
+		return fmt.Errorf("damping.health_weight_degrade must be between 0 and 1")
+	}
+	if config.HealthWeightRecovery < 0 || config.HealthWeightRecovery > 1 {
+		return fmt.Errorf("damping.health_weight_recovery must be between 0 and 1")
+	}
+	return nil
+}
+
+// healthWeighted reports whether health is decided from the weighted window
+func healthWeighted(config DampingConfig) bool {
+	return config.HealthWeighting == weightingLinear || config.HealthWeighting == weightingExponential
+}
+
+// weightedHealthyShare returns the weighted fraction of healthy samples in the peer's
+// measurement window, with recent samples counting more, and the number of samples
+func weightedHealthyShare(state *AppState, peer *PeerState) (float64, int) {
+	samples := peer.Measurements
+	if len(samples) == 0 {
+		return 1, 0
+	}
+
+	damping := state.Config.Damping
+	decay := damping.HealthWeightDecay
+	if decay == 0 {
+		decay = defaultHealthWeightDecay
+	}
+
+	var healthy, total float64
+	for i, latency := range samples {
+		var weight float64
+		if damping.HealthWeighting == weightingExponential {
+			weight = math.Pow(decay, float64(len(samples)-1-i))
+		} else {
+			weight = float64(i + 1)
+		}
+		total += weight
+		var quality sampleQuality
+		if i < len(peer.losses) {
+			quality = sampleQuality{PacketLoss: peer.losses[i], Jitter: peer.jitters[i]}
+		}
+		if ok, _ := isPeerHealthy(latency, quality, peer.Config, state.Config.Thresholds, peer.IsHealthy); ok {
+			healthy += weight
+		}
+	}
+
+	return healthy / total, len(samples)
+}
+
+// weightedHealthDecision decides whether a peer should degrade or recover under the
+// weighted schemes. A healthy peer degrades once the weighted unhealthy share reaches
+// health_weight_degrade; an unhealthy peer recovers once the weighted healthy share
+// reaches health_weight_recovery and its latest sample is healthy. Degrading waits for
+// at least consecutive_unhealthy_count samples so a single early spike can't decide alone.
+func weightedHealthDecision(state *AppState, peer *PeerState) (degrade, restore bool, share float64) {
+	damping := state.Config.Damping
+	degradeAt := damping.HealthWeightDegrade
+	if degradeAt == 0 {
+		degradeAt = defaultHealthWeightDegrade
+	}
+	recoverAt := damping.HealthWeightRecovery
+	if recoverAt == 0 {
+		recoverAt = defaultHealthWeightRecovery
+	}
+
+	share, samples := weightedHealthyShare(state, peer)
+	degrade = samples >= damping.ConsecutiveUnhealthyCount && 1-share >= degradeAt
+	restore = peer.ConsecutiveHealthyCount > 0 && share >= recoverAt
+	return degrade, restore, share
+}